@@ -0,0 +1,258 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// HostStatus describes a host of a MultiHostPool
+type HostStatus struct {
+	Addr string
+	// ActiveCount is the number of connections to the host currently allocated by its pool
+	ActiveCount int
+	// Latency is the moving average of the round trip times of the health checks, zero until the host was checked
+	Latency time.Duration
+	// Ejected reports whether the host failed its last health check, or the last dial when health checks are enabled
+	Ejected bool
+	// Circuit is the state of the circuit breaker of the host, always closed without ClientOptions.CircuitBreaker
+	Circuit CircuitState
+}
+
+// BalancingStrategy selects the host of each connection borrowed from a MultiHostPool
+type BalancingStrategy interface {
+	// Pick returns the index of the selected host among candidates, which is never empty
+	Pick(candidates []HostStatus) int
+}
+
+type randomStrategy struct{}
+
+// NewRandomStrategy returns a strategy selecting the hosts at random, which is the default one
+func NewRandomStrategy() BalancingStrategy {
+	return randomStrategy{}
+}
+
+func (randomStrategy) Pick(candidates []HostStatus) int {
+	return rand.Intn(len(candidates))
+}
+
+type roundRobinStrategy struct {
+	next uint32
+}
+
+// NewRoundRobinStrategy returns a strategy selecting the hosts in turn
+func NewRoundRobinStrategy() BalancingStrategy {
+	return &roundRobinStrategy{}
+}
+
+func (s *roundRobinStrategy) Pick(candidates []HostStatus) int {
+	return int((atomic.AddUint32(&s.next, 1) - 1) % uint32(len(candidates)))
+}
+
+type leastActiveStrategy struct{}
+
+// NewLeastActiveStrategy returns a strategy selecting the host with the fewest active connections, ties being broken at random
+func NewLeastActiveStrategy() BalancingStrategy {
+	return leastActiveStrategy{}
+}
+
+func (leastActiveStrategy) Pick(candidates []HostStatus) int {
+	offset := rand.Intn(len(candidates))
+	selected := offset
+	for n := 1; n < len(candidates); n++ {
+		i := (offset + n) % len(candidates)
+		if candidates[i].ActiveCount < candidates[selected].ActiveCount {
+			selected = i
+		}
+	}
+	return selected
+}
+
+type latencyWeightedStrategy struct{}
+
+// NewLatencyWeightedStrategy returns a strategy selecting the hosts at random, with a probability inversely proportional
+// to their latency. It requires the health checks, the hosts being selected uniformly until their latency is measured.
+func NewLatencyWeightedStrategy() BalancingStrategy {
+	return latencyWeightedStrategy{}
+}
+
+func (latencyWeightedStrategy) Pick(candidates []HostStatus) int {
+	var fastest time.Duration
+	for _, candidate := range candidates {
+		if candidate.Latency > 0 && (fastest == 0 || candidate.Latency < fastest) {
+			fastest = candidate.Latency
+		}
+	}
+	if fastest == 0 {
+		return rand.Intn(len(candidates))
+	}
+	weights := make([]float64, len(candidates))
+	total := 0.0
+	for i, candidate := range candidates {
+		latency := candidate.Latency
+		if latency <= 0 {
+			// give the hosts not measured yet a chance, as if they were the fastest
+			latency = fastest
+		}
+		weights[i] = float64(fastest) / float64(latency)
+		total += weights[i]
+	}
+	r := rand.Float64() * total
+	for i, weight := range weights {
+		if r < weight {
+			return i
+		}
+		r -= weight
+	}
+	return len(candidates) - 1
+}
+
+// hostHealth is the health of a host of a MultiHostPool, guarded by the lock of the pool
+type hostHealth struct {
+	ejected bool
+	latency time.Duration
+	// breaker is nil without ClientOptions.CircuitBreaker
+	breaker *CircuitBreaker
+	// counters of the failures of the pool of the host
+	counters *poolCounters
+}
+
+// Weight of the last round trip time in the moving average of the latency
+const latencySmoothing = 0.2
+
+// Hosts returns the status of every host of the pool
+func (p *MultiHostPool) Hosts() []HostStatus {
+	p.Lock()
+	defer p.Unlock()
+	statuses := make([]HostStatus, 0, len(p.hosts))
+	for _, host := range p.hosts {
+		statuses = append(statuses, p.hostStatus(host))
+	}
+	return statuses
+}
+
+// hostHealth returns the health of host. The lock must be held.
+func (p *MultiHostPool) hostHealth(host string) *hostHealth {
+	if p.health == nil {
+		p.health = map[string]*hostHealth{}
+	}
+	health, found := p.health[host]
+	if !found {
+		health = &hostHealth{counters: &poolCounters{}}
+		if p.options.CircuitBreaker != nil {
+			health.breaker = NewCircuitBreaker(host, *p.options.CircuitBreaker)
+		}
+		p.health[host] = health
+	}
+	return health
+}
+
+// circuitsOpen tells whether the circuit breaker of every host is open
+func (p *MultiHostPool) circuitsOpen() bool {
+	p.Lock()
+	defer p.Unlock()
+	for _, host := range p.hosts {
+		if p.hostStatus(host).Circuit != CircuitOpen {
+			return false
+		}
+	}
+	return len(p.hosts) > 0
+}
+
+// hostStatus returns the status of host. The lock must be held.
+func (p *MultiHostPool) hostStatus(host string) HostStatus {
+	health := p.hostHealth(host)
+	status := HostStatus{Addr: host, Latency: health.latency, Ejected: health.ejected}
+	if health.breaker != nil {
+		status.Circuit = health.breaker.State()
+	}
+	if pool, found := p.pools[host]; found {
+		status.ActiveCount = pool.ActiveCount()
+	}
+	return status
+}
+
+// ejectHost ejects a host which could not be dialed, until a health check succeeds.
+// Without health checks, hosts are never ejected as nothing would re-admit them.
+func (p *MultiHostPool) ejectHost(host string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.stop != nil {
+		p.hostHealth(host).ejected = true
+	}
+}
+
+// healthCheck checks every host each interval, until stop is closed
+func (p *MultiHostPool) healthCheck(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.checkHosts(interval)
+		}
+	}
+}
+
+// checkHosts sends PING to every host concurrently, ejecting the hosts which fail to answer within timeout,
+// and re-admitting the ones which do
+func (p *MultiHostPool) checkHosts(timeout time.Duration) {
+	p.Lock()
+	if p.stop == nil {
+		p.Unlock()
+		return
+	}
+	pools := make(map[string]*redis.Pool, len(p.hosts))
+	for _, host := range p.hosts {
+		pools[host] = p.hostPool(host)
+	}
+	p.Unlock()
+
+	var wg sync.WaitGroup
+	for host, pool := range pools {
+		wg.Add(1)
+		go func(host string, pool *redis.Pool) {
+			defer wg.Done()
+			rtt, err := pingHost(pool, timeout)
+			p.Lock()
+			defer p.Unlock()
+			health := p.hostHealth(host)
+			health.ejected = err != nil
+			if err != nil {
+				return
+			}
+			if health.latency == 0 {
+				health.latency = rtt
+			} else {
+				health.latency += time.Duration(latencySmoothing * float64(rtt-health.latency))
+			}
+		}(host, pool)
+	}
+	wg.Wait()
+}
+
+// pingHost returns the round trip time of a PING sent on a connection of pool
+func pingHost(pool *redis.Pool, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	start := time.Now()
+	if _, err = redis.DoWithTimeout(conn, timeout, "PING"); err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	if rtt <= 0 {
+		rtt = time.Nanosecond
+	}
+	return rtt, nil
+}
@@ -0,0 +1,168 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalancingStrategies(t *testing.T) {
+	candidates := []HostStatus{
+		{Addr: "a", ActiveCount: 3, Latency: 100 * time.Millisecond},
+		{Addr: "b", ActiveCount: 1, Latency: time.Millisecond},
+		{Addr: "c", ActiveCount: 2, Latency: 0},
+	}
+	tests := []struct {
+		name     string
+		strategy BalancingStrategy
+		// check is called with the number of times each host was picked over 1000 picks
+		check func(t *testing.T, picks []int)
+	}{
+		{"random", NewRandomStrategy(), func(t *testing.T, picks []int) {
+			for _, n := range picks {
+				assert.True(t, n > 0)
+			}
+		}},
+		{"round robin", NewRoundRobinStrategy(), func(t *testing.T, picks []int) {
+			assert.Equal(t, []int{334, 333, 333}, picks)
+		}},
+		{"least active", NewLeastActiveStrategy(), func(t *testing.T, picks []int) {
+			assert.Equal(t, []int{0, 1000, 0}, picks)
+		}},
+		{"latency weighted", NewLatencyWeightedStrategy(), func(t *testing.T, picks []int) {
+			// a is 100 times slower than b, while c is not measured yet and weighs like b
+			assert.True(t, picks[0] < 50)
+			assert.True(t, picks[1] > 400)
+			assert.True(t, picks[2] > 400)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picks := make([]int, len(candidates))
+			for n := 0; n < 1000; n++ {
+				picks[tt.strategy.Pick(candidates)]++
+			}
+			tt.check(t, picks)
+		})
+	}
+}
+
+// deadAddr returns the address of a closed listener, refusing connections
+func deadAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func pongHandler(args []string) interface{} {
+	return respStatus("PONG")
+}
+
+func TestMultiHostPool_BorrowRetry(t *testing.T) {
+	s := newRespServer(t, pongHandler)
+	defer s.close()
+	hosts := []string{deadAddr(t), s.addr()}
+
+	tests := []struct {
+		name    string
+		retries int
+		wantErr bool
+	}{
+		{"retry on healthy host", 1, false},
+		{"no retry", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := NewClientOptions().SetStrategy(NewRoundRobinStrategy()).SetHealthCheck(0, tt.retries)
+			p := NewMultiHostPoolWithOptions(hosts, *options)
+			defer p.Close()
+			failed := false
+			for n := 0; n < 4; n++ {
+				conn, err := p.GetContext(context.Background())
+				if err != nil {
+					failed = true
+					continue
+				}
+				_, err = conn.Do("PING")
+				assert.Nil(t, err)
+				conn.Close()
+			}
+			assert.Equal(t, tt.wantErr, failed)
+			// without health checks the dead host is never ejected
+			assert.False(t, p.Hosts()[0].Ejected)
+		})
+	}
+}
+
+func TestMultiHostPool_Eject(t *testing.T) {
+	s := newRespServer(t, pongHandler)
+	defer s.close()
+	options := NewClientOptions().SetHealthCheck(time.Hour, 0).SetPoolLimits(1, 1, false)
+
+	p := NewMultiHostPoolWithOptions([]string{s.addr()}, *options)
+	defer p.Close()
+	conn, err := p.GetContext(context.Background())
+	assert.Nil(t, err)
+	defer conn.Close()
+	_, err = p.GetContext(context.Background())
+	assert.Equal(t, redis.ErrPoolExhausted, err)
+	assert.False(t, p.Hosts()[0].Ejected, "busy host not ejected")
+
+	dead := NewMultiHostPoolWithOptions([]string{deadAddr(t)}, *options)
+	defer dead.Close()
+	_, err = dead.GetContext(context.Background())
+	assert.NotNil(t, err)
+	assert.True(t, dead.Hosts()[0].Ejected, "unreachable host ejected")
+}
+
+// waitFor polls condition until it holds, failing the test after a second
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMultiHostPool_HealthCheck(t *testing.T) {
+	s := newRespServer(t, pongHandler)
+	defer s.close()
+	dead := deadAddr(t)
+	options := NewClientOptions().SetStrategy(NewLatencyWeightedStrategy()).SetHealthCheck(10*time.Millisecond, 0)
+	p := NewMultiHostPoolWithOptions([]string{dead, s.addr()}, *options)
+	defer p.Close()
+
+	waitFor(t, func() bool {
+		hosts := p.Hosts()
+		return hosts[0].Ejected && hosts[1].Latency > 0
+	})
+	assert.False(t, p.Hosts()[1].Ejected)
+	// the ejected host is never selected while another one is available
+	for n := 0; n < 10; n++ {
+		conn, err := p.GetContext(context.Background())
+		assert.Nil(t, err)
+		conn.Close()
+	}
+
+	// the host is re-admitted once it answers again
+	ln, err := net.Listen("tcp", dead)
+	if err != nil {
+		t.Skipf("can not listen again on %s: %v", dead, err)
+	}
+	revived := startRespServer(ln, pongHandler)
+	defer revived.close()
+	waitFor(t, func() bool {
+		return !p.Hosts()[0].Ejected
+	})
+
+	assert.Nil(t, p.Close())
+	assert.Nil(t, p.Close())
+}
@@ -0,0 +1,336 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriterClosed is returned when writing to a BufferedWriter which was closed
+var ErrWriterClosed = errors.New("buffered writer closed")
+
+// ErrBufferFull is returned, and reported to the error handler, for the samples dropped because the buffer was full
+var ErrBufferFull = errors.New("buffered writer buffer full: sample dropped")
+
+// OverflowPolicy tells what a BufferedWriter does with a new sample when its buffer is full
+type OverflowPolicy int
+
+const (
+	// BlockOnOverflow blocks the writer until the buffer has room for the sample
+	BlockOnOverflow OverflowPolicy = iota
+	// DropNewestOnOverflow drops the new sample, and returns ErrBufferFull to the writer
+	DropNewestOnOverflow
+	// DropOldestOnOverflow drops the oldest buffered sample to make room for the new one
+	DropOldestOnOverflow
+)
+
+// BufferedWriterOptions are the options of a BufferedWriter
+type BufferedWriterOptions struct {
+	// BatchSize is the maximum number of samples sent with a single TS.MADD
+	BatchSize int
+	// FlushInterval is the maximum time a sample waits in the buffer before being sent
+	FlushInterval time.Duration
+	// BufferSize is the maximum number of samples waiting to be sent
+	BufferSize int
+	// OverflowPolicy tells what to do with new samples when the buffer is full
+	OverflowPolicy OverflowPolicy
+	// MaxRetries is the number of times a batch is sent again after a transient failure, such as a connection error
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled on each following retry
+	RetryBackoff time.Duration
+	// ErrorHandler, when set, is called with each sample which could not be written and the reason
+	ErrorHandler func(sample Sample, err error)
+}
+
+func NewBufferedWriterOptions() *BufferedWriterOptions {
+	return &BufferedWriterOptions{
+		BatchSize:      500,
+		FlushInterval:  time.Second,
+		BufferSize:     10000,
+		OverflowPolicy: BlockOnOverflow,
+		MaxRetries:     3,
+		RetryBackoff:   100 * time.Millisecond,
+		ErrorHandler:   nil,
+	}
+}
+
+// DefaultBufferedWriterOptions are the default options of a BufferedWriter
+var DefaultBufferedWriterOptions = *NewBufferedWriterOptions()
+
+// SetBatchSize sets the maximum number of samples sent with a single TS.MADD
+func (options *BufferedWriterOptions) SetBatchSize(batchSize int) *BufferedWriterOptions {
+	options.BatchSize = batchSize
+	return options
+}
+
+// SetFlushInterval sets the maximum time a sample waits in the buffer before being sent
+func (options *BufferedWriterOptions) SetFlushInterval(flushInterval time.Duration) *BufferedWriterOptions {
+	options.FlushInterval = flushInterval
+	return options
+}
+
+// SetBufferSize sets the maximum number of samples waiting to be sent, and what to do with new samples once it is reached
+func (options *BufferedWriterOptions) SetBufferSize(bufferSize int, policy OverflowPolicy) *BufferedWriterOptions {
+	options.BufferSize = bufferSize
+	options.OverflowPolicy = policy
+	return options
+}
+
+// SetRetries sets the number of retries after a transient failure, and the wait before the first one
+func (options *BufferedWriterOptions) SetRetries(maxRetries int, backoff time.Duration) *BufferedWriterOptions {
+	options.MaxRetries = maxRetries
+	options.RetryBackoff = backoff
+	return options
+}
+
+// SetErrorHandler sets the function called with each sample which could not be written
+func (options *BufferedWriterOptions) SetErrorHandler(handler func(sample Sample, err error)) *BufferedWriterOptions {
+	options.ErrorHandler = handler
+	return options
+}
+
+// BufferedWriterStats are the counters of a BufferedWriter
+type BufferedWriterStats struct {
+	// Queued is the number of samples accepted into the buffer
+	Queued uint64
+	// Flushed is the number of samples written to the server
+	Flushed uint64
+	// Dropped is the number of samples dropped because the buffer was full
+	Dropped uint64
+	// Errored is the number of samples which could not be written
+	Errored uint64
+	// Retries is the number of batches sent again after a transient failure
+	Retries uint64
+	// Pending is the number of samples currently waiting in the buffer
+	Pending int
+}
+
+// BufferedWriter coalesces the samples written by many goroutines into TS.MADD batches,
+// sent by a background goroutine once a batch is full or the flush interval elapsed.
+// Samples which can not be written are reported to the ErrorHandler, the writers are not blocked waiting for them.
+type BufferedWriter struct {
+	// counters are first, so that they are 64-bit aligned for the atomic operations
+	queued  uint64
+	flushed uint64
+	dropped uint64
+	errored uint64
+	retries uint64
+
+	client  *Client
+	options BufferedWriterOptions
+	samples chan Sample
+	flushes chan chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+	// mu is held for reading while sending to samples, and for writing when closing it
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewBufferedWriter creates a BufferedWriter writing through client, and starts its background goroutine.
+// Close must be called to flush the buffered samples and stop it.
+func NewBufferedWriter(client *Client, options BufferedWriterOptions) *BufferedWriter {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBufferedWriterOptions.BatchSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultBufferedWriterOptions.FlushInterval
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultBufferedWriterOptions.BufferSize
+	}
+	w := &BufferedWriter{
+		client:  client,
+		options: options,
+		samples: make(chan Sample, options.BufferSize),
+		flushes: make(chan chan struct{}),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add buffers a new sample
+func (w *BufferedWriter) Add(key string, timestamp int64, value float64) error {
+	return w.Write(Sample{Key: key, DataPoint: DataPoint{Timestamp: timestamp, Value: value}})
+}
+
+// Write buffers the given samples, applying the overflow policy when the buffer is full
+func (w *BufferedWriter) Write(samples ...Sample) error {
+	return w.WriteCtx(context.Background(), samples...)
+}
+
+// WriteCtx is like Write, giving up when ctx is done while blocked on a full buffer
+func (w *BufferedWriter) WriteCtx(ctx context.Context, samples ...Sample) (err error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	for _, sample := range samples {
+		if sampleErr := w.write(ctx, sample); sampleErr != nil {
+			if sampleErr != ErrBufferFull {
+				return sampleErr
+			}
+			err = sampleErr
+		}
+	}
+	return
+}
+
+func (w *BufferedWriter) write(ctx context.Context, sample Sample) error {
+	for {
+		select {
+		case w.samples <- sample:
+			atomic.AddUint64(&w.queued, 1)
+			return nil
+		default:
+		}
+		switch w.options.OverflowPolicy {
+		case DropNewestOnOverflow:
+			w.drop(sample)
+			return ErrBufferFull
+		case DropOldestOnOverflow:
+			select {
+			case oldest := <-w.samples:
+				w.drop(oldest)
+			default:
+			}
+		default:
+			select {
+			case w.samples <- sample:
+				atomic.AddUint64(&w.queued, 1)
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (w *BufferedWriter) drop(sample Sample) {
+	atomic.AddUint64(&w.dropped, 1)
+	if w.options.ErrorHandler != nil {
+		w.options.ErrorHandler(sample, ErrBufferFull)
+	}
+}
+
+// Flush sends the buffered samples, and returns once they were written or reported as failed
+func (w *BufferedWriter) Flush() error {
+	flushed := make(chan struct{})
+	select {
+	case w.flushes <- flushed:
+	case <-w.done:
+		return ErrWriterClosed
+	}
+	<-flushed
+	return nil
+}
+
+// Close stops accepting samples, sends the buffered ones, and waits for the background goroutine to stop
+func (w *BufferedWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		<-w.done
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.closing)
+	<-w.done
+	return nil
+}
+
+// Stats returns the counters of the writer
+func (w *BufferedWriter) Stats() BufferedWriterStats {
+	return BufferedWriterStats{
+		Queued:  atomic.LoadUint64(&w.queued),
+		Flushed: atomic.LoadUint64(&w.flushed),
+		Dropped: atomic.LoadUint64(&w.dropped),
+		Errored: atomic.LoadUint64(&w.errored),
+		Retries: atomic.LoadUint64(&w.retries),
+		Pending: len(w.samples),
+	}
+}
+
+func (w *BufferedWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.options.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]Sample, 0, w.options.BatchSize)
+	for {
+		select {
+		case sample := <-w.samples:
+			batch = append(batch, sample)
+			if len(batch) >= w.options.BatchSize {
+				batch = w.flush(batch)
+			}
+		case <-ticker.C:
+			batch = w.flush(batch)
+		case flushed := <-w.flushes:
+			batch = w.drain(batch)
+			close(flushed)
+		case <-w.closing:
+			// no writer sends anymore once closing, so the buffer is drained for good
+			w.drain(batch)
+			return
+		}
+	}
+}
+
+// drain sends the pending batch along with every sample currently buffered
+func (w *BufferedWriter) drain(batch []Sample) []Sample {
+	for {
+		select {
+		case sample := <-w.samples:
+			batch = append(batch, sample)
+			if len(batch) >= w.options.BatchSize {
+				batch = w.flush(batch)
+			}
+		default:
+			return w.flush(batch)
+		}
+	}
+}
+
+// flush writes the batch with TS.MADD, retrying after transient failures, and returns it emptied
+func (w *BufferedWriter) flush(batch []Sample) []Sample {
+	if len(batch) == 0 {
+		return batch
+	}
+	backoff := w.options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		results, err := w.client.MultiAddWithResults(batch...)
+		if err == nil {
+			for _, result := range results {
+				if result.Err == nil {
+					atomic.AddUint64(&w.flushed, 1)
+					continue
+				}
+				w.fail(result.Sample.Sample, result.Err)
+			}
+			break
+		}
+		if attempt >= w.options.MaxRetries || !isTransientError(err) {
+			for _, sample := range batch {
+				w.fail(sample, err)
+			}
+			break
+		}
+		atomic.AddUint64(&w.retries, 1)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return batch[:0]
+}
+
+func (w *BufferedWriter) fail(sample Sample, err error) {
+	atomic.AddUint64(&w.errored, 1)
+	if w.options.ErrorHandler != nil {
+		w.options.ErrorHandler(sample, err)
+	}
+}
@@ -0,0 +1,203 @@
+package redis_timeseries_go
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// maddRecorder records the size of each TS.MADD batch, and replies with the handler result
+type maddRecorder struct {
+	mu      sync.Mutex
+	batches []int
+	reply   func(args []interface{}) (interface{}, error)
+}
+
+func (r *maddRecorder) handle(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd != MADD_CMD {
+		return nil, errors.New("unexpected command " + cmd)
+	}
+	r.mu.Lock()
+	r.batches = append(r.batches, len(args)/3)
+	r.mu.Unlock()
+	if r.reply != nil {
+		return r.reply(args)
+	}
+	return okMultiAddReply(args), nil
+}
+
+func (r *maddRecorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int{}, r.batches...)
+}
+
+func okMultiAddReply(args []interface{}) []interface{} {
+	replies := make([]interface{}, 0, len(args)/3)
+	for i := 1; i < len(args); i += 3 {
+		replies = append(replies, args[i])
+	}
+	return replies
+}
+
+func testSamples(n int) []Sample {
+	samples := make([]Sample, n)
+	for i := range samples {
+		samples[i] = Sample{Key: "key", DataPoint: DataPoint{Timestamp: int64(i + 1), Value: float64(i)}}
+	}
+	return samples
+}
+
+func TestBufferedWriter_Batching(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		samples   int
+		want      []int
+	}{
+		{"single batch", 10, 5, []int{5}},
+		{"full batches", 2, 4, []int{2, 2}},
+		{"partial last batch", 2, 5, []int{2, 2, 1}},
+		{"no sample", 2, 0, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &maddRecorder{}
+			c := &Client{Pool: &stubPool{handler: recorder.handle}, Name: "test"}
+			w := NewBufferedWriter(c, *NewBufferedWriterOptions().SetBatchSize(tt.batchSize).SetFlushInterval(time.Hour))
+			assert.Nil(t, w.Write(testSamples(tt.samples)...))
+			assert.Nil(t, w.Close())
+			assert.Equal(t, tt.want, recorder.sizes())
+			stats := w.Stats()
+			assert.Equal(t, uint64(tt.samples), stats.Queued)
+			assert.Equal(t, uint64(tt.samples), stats.Flushed)
+			assert.Equal(t, 0, stats.Pending)
+			assert.Equal(t, ErrWriterClosed, w.Add("key", 1, 1))
+			assert.Equal(t, ErrWriterClosed, w.Flush())
+			assert.Nil(t, w.Close())
+		})
+	}
+}
+
+func TestBufferedWriter_FlushInterval(t *testing.T) {
+	recorder := &maddRecorder{}
+	c := &Client{Pool: &stubPool{handler: recorder.handle}, Name: "test"}
+	w := NewBufferedWriter(c, *NewBufferedWriterOptions().SetFlushInterval(5 * time.Millisecond))
+	defer w.Close()
+	assert.Nil(t, w.Add("key", 1, 1))
+	deadline := time.Now().Add(time.Second)
+	for w.Stats().Flushed == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, []int{1}, recorder.sizes())
+
+	assert.Nil(t, w.Add("key", 2, 1))
+	assert.Nil(t, w.Flush())
+	assert.Equal(t, uint64(2), w.Stats().Flushed)
+}
+
+func TestBufferedWriter_Overflow(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      OverflowPolicy
+		wantErr     error
+		wantDropped int64
+		wantFlushed []int64
+	}{
+		{"drop newest", DropNewestOnOverflow, ErrBufferFull, 3, []int64{1, 2}},
+		{"drop oldest", DropOldestOnOverflow, nil, 2, []int64{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sending := make(chan struct{}, 1)
+			release := make(chan struct{})
+			var mu sync.Mutex
+			var flushed []int64
+			recorder := &maddRecorder{reply: func(args []interface{}) (interface{}, error) {
+				sending <- struct{}{}
+				<-release
+				mu.Lock()
+				defer mu.Unlock()
+				for i := 1; i < len(args); i += 3 {
+					flushed = append(flushed, args[i].(int64))
+				}
+				return okMultiAddReply(args), nil
+			}}
+			var dropped []int64
+			options := NewBufferedWriterOptions().SetBatchSize(1).SetBufferSize(1, tt.policy).
+				SetErrorHandler(func(sample Sample, err error) {
+					assert.Equal(t, ErrBufferFull, err)
+					dropped = append(dropped, sample.DataPoint.Timestamp)
+				})
+			c := &Client{Pool: &stubPool{handler: recorder.handle}, Name: "test"}
+			w := NewBufferedWriter(c, *options)
+			samples := testSamples(3)
+			// the first sample is being sent, while the second one fills the buffer
+			assert.Nil(t, w.Write(samples[0]))
+			<-sending
+			assert.Nil(t, w.Write(samples[1]))
+			assert.Equal(t, tt.wantErr, w.Write(samples[2]))
+			assert.Equal(t, []int64{tt.wantDropped}, dropped)
+			close(release)
+			assert.Nil(t, w.Close())
+			assert.Equal(t, tt.wantFlushed, flushed)
+			assert.Equal(t, uint64(1), w.Stats().Dropped)
+		})
+	}
+}
+
+func TestBufferedWriter_Errors(t *testing.T) {
+	reset := &net.OpError{Op: "write", Net: "tcp", Err: errors.New("connection reset")}
+	tests := []struct {
+		name        string
+		replies     []interface{}
+		wantErr     error
+		wantFlushed uint64
+		wantErrored uint64
+		wantRetries uint64
+	}{
+		{"transient failure retried", []interface{}{reset, nil}, nil, 2, 0, 1},
+		{"transient failures exhausted", []interface{}{reset, reset}, reset, 0, 2, 1},
+		{"open circuit not retried", []interface{}{ErrCircuitOpen}, ErrCircuitOpen, 0, 2, 0},
+		{"server error not retried", []interface{}{redis.Error("ERR wrong number of arguments for 'TS.MADD' command")}, redis.Error("ERR wrong number of arguments for 'TS.MADD' command"), 0, 2, 0},
+		{"sample error", []interface{}{[]interface{}{int64(1), redis.Error("ERR TSDB: the key does not exist")}}, ErrKeyNotFound, 1, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			recorder := &maddRecorder{reply: func(args []interface{}) (interface{}, error) {
+				reply := tt.replies[calls]
+				calls++
+				switch reply := reply.(type) {
+				case nil:
+					return okMultiAddReply(args), nil
+				case error:
+					return nil, reply
+				default:
+					return reply, nil
+				}
+			}}
+			var errs []error
+			options := NewBufferedWriterOptions().SetRetries(1, time.Millisecond).SetErrorHandler(func(sample Sample, err error) {
+				errs = append(errs, err)
+			})
+			c := &Client{Pool: &stubPool{handler: recorder.handle}, Name: "test"}
+			w := NewBufferedWriter(c, *options)
+			assert.Nil(t, w.Write(testSamples(2)...))
+			assert.Nil(t, w.Close())
+			stats := w.Stats()
+			assert.Equal(t, tt.wantFlushed, stats.Flushed)
+			assert.Equal(t, tt.wantErrored, stats.Errored)
+			assert.Equal(t, tt.wantRetries, stats.Retries)
+			assert.Len(t, errs, int(tt.wantErrored))
+			for _, err := range errs {
+				assert.True(t, errors.Is(err, tt.wantErr) || err.Error() == tt.wantErr.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrUnsupportedByServer is returned, when Client.CheckCapabilities is set, by the commands using an option
+// the RedisTimeSeries module of the server does not support. The error is an *UnsupportedByServerError.
+var ErrUnsupportedByServer = errors.New("option not supported by the server")
+
+// ErrModuleNotLoaded is returned when detecting the capabilities of a server without the RedisTimeSeries module
+var ErrModuleNotLoaded = errors.New("RedisTimeSeries module not loaded")
+
+// Feature is a command or command option only supported from a given RedisTimeSeries version
+type Feature string
+
+const (
+	FeatureChunkSize       Feature = "CHUNK_SIZE"
+	FeatureDuplicatePolicy Feature = "DUPLICATE_POLICY"
+	FeatureReverseRange    Feature = "TS.REVRANGE"
+	FeatureDeleteRange     Feature = "TS.DEL"
+	FeatureFilterByTs      Feature = "FILTER_BY_TS"
+	FeatureFilterByValue   Feature = "FILTER_BY_VALUE"
+	FeatureAlign           Feature = "ALIGN"
+	FeatureGroupBy         Feature = "GROUPBY"
+	FeatureSelectedLabels  Feature = "SELECTED_LABELS"
+)
+
+// FeatureVersions are the RedisTimeSeries versions introducing the features, as reported by MODULE LIST
+var FeatureVersions = map[Feature]int64{
+	FeatureChunkSize:       10400,
+	FeatureDuplicatePolicy: 10400,
+	FeatureReverseRange:    10400,
+	FeatureDeleteRange:     10600,
+	FeatureFilterByTs:      10600,
+	FeatureFilterByValue:   10600,
+	FeatureAlign:           10600,
+	FeatureGroupBy:         10600,
+	FeatureSelectedLabels:  10600,
+}
+
+// formatModuleVersion formats a version reported by MODULE LIST, such as 10410, as 1.4.10
+func formatModuleVersion(version int64) string {
+	return fmt.Sprintf("%d.%d.%d", version/10000, version/100%100, version%100)
+}
+
+// UnsupportedByServerError names the option which is not supported by the server, and the version introducing it
+type UnsupportedByServerError struct {
+	Feature         Feature
+	RequiredVersion int64
+	ServerVersion   int64
+}
+
+func (e *UnsupportedByServerError) Error() string {
+	return fmt.Sprintf("%s requires RedisTimeSeries %s, the server runs %s",
+		e.Feature, formatModuleVersion(e.RequiredVersion), formatModuleVersion(e.ServerVersion))
+}
+
+// Unwrap returns ErrUnsupportedByServer
+func (e *UnsupportedByServerError) Unwrap() error {
+	return ErrUnsupportedByServer
+}
+
+// ServerCapabilities are the version of the RedisTimeSeries module of the server, and the features it supports
+type ServerCapabilities struct {
+	// ModuleVersion is the version reported by MODULE LIST, such as 10410 for 1.4.10
+	ModuleVersion int64
+	Features      map[Feature]bool
+}
+
+// NewServerCapabilities returns the capabilities of the given module version
+func NewServerCapabilities(moduleVersion int64) ServerCapabilities {
+	capabilities := ServerCapabilities{ModuleVersion: moduleVersion, Features: map[Feature]bool{}}
+	for feature, version := range FeatureVersions {
+		capabilities.Features[feature] = moduleVersion >= version
+	}
+	return capabilities
+}
+
+// Supports tells whether the server supports feature
+func (c ServerCapabilities) Supports(feature Feature) bool {
+	return c.Features[feature]
+}
+
+// check returns an *UnsupportedByServerError for the first feature which is not supported
+func (c ServerCapabilities) check(features []Feature) error {
+	for _, feature := range features {
+		if !c.Supports(feature) {
+			return &UnsupportedByServerError{Feature: feature, RequiredVersion: FeatureVersions[feature], ServerVersion: c.ModuleVersion}
+		}
+	}
+	return nil
+}
+
+// capabilitiesCache holds the capabilities of the server once detected
+type capabilitiesCache struct {
+	mu           sync.Mutex
+	capabilities *ServerCapabilities
+	// detection is the detection in flight, if any
+	detection *capabilitiesDetection
+}
+
+// capabilitiesDetection is a MODULE LIST in flight, which the concurrent callers wait for
+type capabilitiesDetection struct {
+	done         chan struct{}
+	capabilities ServerCapabilities
+	err          error
+}
+
+// ServerCapabilities returns the capabilities of the server, detected with MODULE LIST on the first call and cached
+func (client *Client) ServerCapabilities() (ServerCapabilities, error) {
+	return client.ServerCapabilitiesCtx(context.Background())
+}
+
+// ServerCapabilitiesCtx is like ServerCapabilities, honoring the deadline and cancellation of ctx.
+// Concurrent calls share a single detection, and return as soon as their own ctx is done.
+// A failed detection is not cached, so that it is attempted again by the next call.
+func (client *Client) ServerCapabilitiesCtx(ctx context.Context) (ServerCapabilities, error) {
+	cache := &client.capabilities
+	for {
+		cache.mu.Lock()
+		if cache.capabilities != nil {
+			capabilities := *cache.capabilities
+			cache.mu.Unlock()
+			return capabilities, nil
+		}
+		detection := cache.detection
+		if detection == nil {
+			detection = &capabilitiesDetection{done: make(chan struct{})}
+			cache.detection = detection
+			cache.mu.Unlock()
+			return client.detectCapabilities(ctx, detection)
+		}
+		cache.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ServerCapabilities{}, ctx.Err()
+		case <-detection.done:
+		}
+		// a detection abandoned by the caller which started it is attempted again
+		if detection.err != context.Canceled && detection.err != context.DeadlineExceeded {
+			return detection.capabilities, detection.err
+		}
+	}
+}
+
+// detectCapabilities runs the detection, outside of the lock, and publishes its outcome to the waiting callers
+func (client *Client) detectCapabilities(ctx context.Context, detection *capabilitiesDetection) (ServerCapabilities, error) {
+	version, err := client.moduleVersion(ctx)
+	cache := &client.capabilities
+	cache.mu.Lock()
+	if err != nil {
+		detection.err = err
+	} else {
+		detection.capabilities = NewServerCapabilities(version)
+		cache.capabilities = &detection.capabilities
+	}
+	cache.detection = nil
+	cache.mu.Unlock()
+	close(detection.done)
+	return detection.capabilities, detection.err
+}
+
+// moduleVersion returns the version of the timeseries module listed by MODULE LIST
+func (client *Client) moduleVersion(ctx context.Context) (int64, error) {
+	modules, err := redis.Values(client.do(ctx, "", "MODULE", "LIST"))
+	if err != nil {
+		return 0, err
+	}
+	for _, module := range modules {
+		properties, err := redis.Values(module, nil)
+		if err != nil {
+			return 0, err
+		}
+		var name string
+		var version int64
+		for i := 0; i+1 < len(properties); i += 2 {
+			switch property, _ := redis.String(properties[i], nil); property {
+			case "name":
+				name, _ = redis.String(properties[i+1], nil)
+			case "ver":
+				version, _ = redis.Int64(properties[i+1], nil)
+			}
+		}
+		if name == "timeseries" {
+			return version, nil
+		}
+	}
+	return 0, ErrModuleNotLoaded
+}
+
+// checkSupported fails with an *UnsupportedByServerError when the command uses a feature the server does not support,
+// if the client checks the capabilities
+func (client *Client) checkSupported(ctx context.Context, cmd string, args []interface{}) error {
+	if !client.CheckCapabilities {
+		return nil
+	}
+	features := requiredFeatures(cmd, args)
+	if len(features) == 0 {
+		return nil
+	}
+	capabilities, err := client.ServerCapabilitiesCtx(ctx)
+	if err != nil {
+		return err
+	}
+	return capabilities.check(features)
+}
+
+// checkQueued marks the queued commands using a feature the server does not support as failed,
+// and returns an error when the capabilities can not be detected
+func (client *Client) checkQueued(ctx context.Context, cmds []queuedCommand) error {
+	if !client.CheckCapabilities {
+		return nil
+	}
+	for i, cmd := range cmds {
+		if cmd.err != nil || cmd.skip {
+			continue
+		}
+		features := requiredFeatures(cmd.name, cmd.args)
+		if len(features) == 0 {
+			continue
+		}
+		capabilities, err := client.ServerCapabilitiesCtx(ctx)
+		if err != nil {
+			return err
+		}
+		cmds[i].err = capabilities.check(features)
+	}
+	return nil
+}
+
+// requiredFeatures returns the features used by a command, as serialized by the Client.
+// The options of the commands are looked up among the arguments, skipping the keys, labels and filters.
+func requiredFeatures(cmd string, args []interface{}) []Feature {
+	var features []Feature
+	options := []interface{}{}
+	switch cmd {
+	case CREATE_CMD, ALTER_CMD, INCRBY_CMD, DECRBY_CMD:
+		options = argsUntil(args, 1, "LABELS")
+	case ADD_CMD:
+		options = argsUntil(args, 3, "LABELS")
+	case RANGE_CMD, REVRANGE_CMD:
+		options = argsUntil(args, 3, "")
+	case MRANGE_CMD, MREVRANGE_CMD:
+		options = argsUntil(args, 2, "FILTER")
+		for _, arg := range args[2+len(options):] {
+			if arg == "GROUPBY" {
+				features = append(features, FeatureGroupBy)
+			}
+		}
+	case MGET_CMD:
+		options = argsUntil(args, 0, "FILTER")
+	case TS_DEL_CMD:
+		features = append(features, FeatureDeleteRange)
+	}
+	if cmd == REVRANGE_CMD || cmd == MREVRANGE_CMD {
+		features = append(features, FeatureReverseRange)
+	}
+	for _, option := range options {
+		switch option {
+		case "CHUNK_SIZE":
+			features = append(features, FeatureChunkSize)
+		case "DUPLICATE_POLICY", "ON_DUPLICATE":
+			features = append(features, FeatureDuplicatePolicy)
+		case "FILTER_BY_TS":
+			features = append(features, FeatureFilterByTs)
+		case "FILTER_BY_VALUE":
+			features = append(features, FeatureFilterByValue)
+		case "ALIGN":
+			features = append(features, FeatureAlign)
+		case "SELECTED_LABELS":
+			features = append(features, FeatureSelectedLabels)
+		}
+	}
+	return features
+}
+
+// argsUntil returns the arguments from start up to the end keyword, or up to the last one for an empty end
+func argsUntil(args []interface{}, start int, end string) []interface{} {
+	if start > len(args) {
+		return args[len(args):]
+	}
+	for i := start; i < len(args); i++ {
+		if end != "" && args[i] == end {
+			return args[start:i]
+		}
+	}
+	return args[start:]
+}
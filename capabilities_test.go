@@ -0,0 +1,163 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RedisTimeSeries/redistimeseries-go/tstest"
+	"github.com/stretchr/testify/assert"
+)
+
+// moduleListHandler replies to MODULE LIST with the given modules, counting the calls
+func moduleListHandler(calls *int, modules ...interface{}) func(cmd string, args ...interface{}) (interface{}, error) {
+	return func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "MODULE" {
+			*calls++
+			return modules, nil
+		}
+		return "OK", nil
+	}
+}
+
+func TestRequiredFeatures(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		args []interface{}
+		want []Feature
+	}{
+		{"create", CREATE_CMD, []interface{}{"key", "CHUNK_SIZE", 128, "DUPLICATE_POLICY", "last"},
+			[]Feature{FeatureChunkSize, FeatureDuplicatePolicy}},
+		{"labels skipped", CREATE_CMD, []interface{}{"key", "LABELS", "ALIGN", "CHUNK_SIZE"}, nil},
+		{"add on duplicate", ADD_CMD, []interface{}{"key", 1, 1.0, "ON_DUPLICATE", "max"}, []Feature{FeatureDuplicatePolicy}},
+		{"key named like an option", ADD_CMD, []interface{}{"ALIGN", 1, 1.0}, nil},
+		{"range", RANGE_CMD, []interface{}{"key", 0, 10, "FILTER_BY_TS", 1, "FILTER_BY_VALUE", 0, 1, "ALIGN", "-"},
+			[]Feature{FeatureFilterByTs, FeatureFilterByValue, FeatureAlign}},
+		{"reverse range", REVRANGE_CMD, []interface{}{"key", 0, 10}, []Feature{FeatureReverseRange}},
+		{"multi range", MRANGE_CMD, []interface{}{0, 10, "SELECTED_LABELS", "a", "FILTER", "ALIGN=1", "GROUPBY", "a", "REDUCE", "sum"},
+			[]Feature{FeatureGroupBy, FeatureSelectedLabels}},
+		{"filters skipped", MREVRANGE_CMD, []interface{}{0, 10, "FILTER", "a=ALIGN"}, []Feature{FeatureReverseRange}},
+		{"delete range", TS_DEL_CMD, []interface{}{"key", 0, 10}, []Feature{FeatureDeleteRange}},
+		{"get", GET_CMD, []interface{}{"ALIGN"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, requiredFeatures(tt.cmd, tt.args))
+		})
+	}
+}
+
+func TestUnsupportedByServerError(t *testing.T) {
+	err := NewServerCapabilities(10410).check([]Feature{FeatureChunkSize, FeatureAlign})
+	assert.EqualError(t, err, "ALIGN requires RedisTimeSeries 1.6.0, the server runs 1.4.10")
+	assert.True(t, errors.Is(err, ErrUnsupportedByServer))
+	var unsupported *UnsupportedByServerError
+	assert.True(t, errors.As(err, &unsupported))
+	assert.Equal(t, FeatureAlign, unsupported.Feature)
+	assert.NoError(t, NewServerCapabilities(10600).check([]Feature{FeatureChunkSize, FeatureAlign}))
+}
+
+func TestClient_ServerCapabilities(t *testing.T) {
+	calls := 0
+	client := NewClientFromConnPool(&stubPool{moduleListHandler(&calls,
+		[]interface{}{[]byte("name"), []byte("search"), []byte("ver"), int64(20006)},
+		[]interface{}{[]byte("name"), []byte("timeseries"), []byte("ver"), int64(10600)},
+	)}, "capabilities")
+	for i := 0; i < 2; i++ {
+		capabilities, err := client.ServerCapabilities()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10600), capabilities.ModuleVersion)
+		assert.True(t, capabilities.Supports(FeatureGroupBy))
+	}
+	assert.Equal(t, 1, calls, "capabilities cached")
+
+	calls = 0
+	client = NewClientFromConnPool(&stubPool{moduleListHandler(&calls)}, "capabilities")
+	for i := 0; i < 2; i++ {
+		_, err := client.ServerCapabilities()
+		assert.Equal(t, ErrModuleNotLoaded, err)
+	}
+	assert.Equal(t, 2, calls, "failed detection not cached")
+}
+
+func TestClient_ServerCapabilities_Concurrent(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	module := []interface{}{"name", "timeseries", "ver", int64(10600)}
+	client := NewClientFromConnPool(&stubPool{func(cmd string, args ...interface{}) (interface{}, error) {
+		// the first detection hangs until released
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+		}
+		return []interface{}{module}, nil
+	}}, "capabilities")
+
+	detecting, cancelDetecting := context.WithCancel(context.Background())
+	detected := make(chan error, 1)
+	go func() {
+		_, err := client.ServerCapabilitiesCtx(detecting)
+		detected <- err
+	}()
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 1 })
+
+	// a waiter returns once its own ctx expires, while the detection is in flight
+	expired, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.ServerCapabilitiesCtx(expired)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// a waiter attempts the detection again when the caller which started it gives up
+	waiting := make(chan error, 1)
+	go func() {
+		capabilities, err := client.ServerCapabilitiesCtx(context.Background())
+		assert.Equal(t, int64(10600), capabilities.ModuleVersion)
+		waiting <- err
+	}()
+	cancelDetecting()
+	assert.Equal(t, context.Canceled, <-detected)
+	assert.NoError(t, <-waiting)
+	close(release)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	_, err = client.ServerCapabilities()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "capabilities cached")
+}
+
+func TestClient_CheckCapabilities(t *testing.T) {
+	server := tstest.NewServer()
+	defer server.Close()
+	client := NewClient(server.Addr(), "unchecked", nil)
+	_, err := client.Add("series", 1, 1)
+	assert.NoError(t, err)
+
+	options := *NewRangeOptions().SetAggregation(AvgAggregation, 10).SetAlign(5)
+	_, err = client.RangeWithOptions("series", 0, 10, options)
+	assert.Error(t, err, "rejected by the server")
+	assert.False(t, errors.Is(err, ErrUnsupportedByServer))
+
+	client.CheckCapabilities = true
+	_, err = client.RangeWithOptions("series", 0, 10, options)
+	assert.True(t, errors.Is(err, ErrUnsupportedByServer))
+	_, err = client.RangeWithOptions("series", 0, 10, DefaultRangeOptions)
+	assert.NoError(t, err)
+
+	pipeline := client.Pipeline()
+	pipeline.RangeWithOptions("series", 0, 10, options)
+	pipeline.Get("series")
+	results, err := pipeline.Exec()
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(results[0].Err, ErrUnsupportedByServer))
+	assert.NoError(t, results[1].Err)
+
+	tx, err := client.Tx()
+	assert.NoError(t, err)
+	tx.RangeWithOptions("series", 0, 10, options)
+	_, err = tx.Exec()
+	var txErr *TxError
+	assert.True(t, errors.As(err, &txErr))
+	assert.True(t, errors.Is(txErr.Err, ErrUnsupportedByServer))
+}
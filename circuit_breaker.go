@@ -0,0 +1,353 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrCircuitOpen is returned when borrowing a connection while the circuit breaker of the host is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every call fast with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets a few probing calls through, closing the circuit when they succeed and opening it again otherwise
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerOptions are the options of the circuit breakers guarding the hosts.
+// A call is the use of a borrowed connection, from the borrow until the connection is closed. It fails when the connection
+// can not be dialed, or reports a transient error such as a timeout or a LOADING or BUSY reply, or when one of its
+// commands is slower than SlowCallDuration. Other server errors, such as ErrKeyNotFound, are not failures.
+type CircuitBreakerOptions struct {
+	// Window is the period over which the failures are counted, the counts being reset once it elapses
+	Window time.Duration
+	// MinRequests is the number of calls within Window below which the breaker never trips
+	MinRequests int
+	// ErrorRate is the fraction of failed calls within Window, between 0 and 1, tripping the breaker
+	ErrorRate float64
+	// SlowCallDuration, when not zero, makes the calls issuing a slower command count as failures
+	SlowCallDuration time.Duration
+	// OpenTimeout is how long the breaker stays open before half-opening
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of calls let through while half-open, which must all succeed to close the breaker
+	HalfOpenProbes int
+	// OnStateChange, when set, is called on every transition of a breaker, with the name of the breaker which is the host address
+	OnStateChange func(name string, from, to CircuitState)
+}
+
+func NewCircuitBreakerOptions() *CircuitBreakerOptions {
+	return &CircuitBreakerOptions{
+		Window:           10 * time.Second,
+		MinRequests:      20,
+		ErrorRate:        0.5,
+		SlowCallDuration: 0,
+		OpenTimeout:      5 * time.Second,
+		HalfOpenProbes:   1,
+		OnStateChange:    nil,
+	}
+}
+
+// DefaultCircuitBreakerOptions trip when half of at least 20 calls fail within 10 seconds, and probe again after 5 seconds
+var DefaultCircuitBreakerOptions = *NewCircuitBreakerOptions()
+
+// SetTrip sets the error rate of the calls within window tripping the breaker, once minRequests calls were counted
+func (options *CircuitBreakerOptions) SetTrip(errorRate float64, minRequests int, window time.Duration) *CircuitBreakerOptions {
+	options.ErrorRate = errorRate
+	options.MinRequests = minRequests
+	options.Window = window
+	return options
+}
+
+// SetSlowCallDuration sets the duration above which a command makes its call count as failed
+func (options *CircuitBreakerOptions) SetSlowCallDuration(duration time.Duration) *CircuitBreakerOptions {
+	options.SlowCallDuration = duration
+	return options
+}
+
+// SetHalfOpen sets how long the breaker stays open, and the number of calls probing the host once half-open
+func (options *CircuitBreakerOptions) SetHalfOpen(openTimeout time.Duration, probes int) *CircuitBreakerOptions {
+	options.OpenTimeout = openTimeout
+	options.HalfOpenProbes = probes
+	return options
+}
+
+// SetOnStateChange sets the function called on every transition of a breaker
+func (options *CircuitBreakerOptions) SetOnStateChange(onStateChange func(name string, from, to CircuitState)) *CircuitBreakerOptions {
+	options.OnStateChange = onStateChange
+	return options
+}
+
+// CircuitCounts are the calls counted by a CircuitBreaker in its current window
+type CircuitCounts struct {
+	Requests int
+	Failures int
+}
+
+// CircuitBreaker stops the calls to a host once too many of them fail, until probing calls succeed again
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	name        string
+	options     CircuitBreakerOptions
+	state       CircuitState
+	openedAt    time.Time
+	windowStart time.Time
+	counts      CircuitCounts
+	// probes are the calls let through, and successes the ones which succeeded, since the breaker half-opened
+	probes    int
+	successes int
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker, named after the host it guards
+func NewCircuitBreaker(name string, options CircuitBreakerOptions) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:        name,
+		options:     options,
+		windowStart: time.Now(),
+	}
+}
+
+// State returns the state of the breaker, which is half-open once an open breaker reached its OpenTimeout
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.options.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// Counts returns the calls counted in the current window
+func (b *CircuitBreaker) Counts() CircuitCounts {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.counts
+}
+
+// allow reports whether a call may proceed, failing with ErrCircuitOpen otherwise.
+// Every allowed call must be followed by a single record of its outcome, or a release.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	from := b.state
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.options.OpenTimeout {
+		b.state, b.probes, b.successes = CircuitHalfOpen, 0, 0
+	}
+	err := error(nil)
+	switch b.state {
+	case CircuitOpen:
+		err = ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probes >= b.options.HalfOpenProbes {
+			err = ErrCircuitOpen
+		} else {
+			b.probes++
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+	return err
+}
+
+// release gives back the slot of an allowed call without recording an outcome, as when it was cancelled
+// before reaching the host
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen && b.probes > b.successes {
+		b.probes--
+	}
+}
+
+// record counts the outcome of an allowed call, tripping or closing the breaker accordingly
+func (b *CircuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	from := b.state
+	now := time.Now()
+	switch b.state {
+	case CircuitClosed:
+		if now.Sub(b.windowStart) >= b.options.Window {
+			b.windowStart, b.counts = now, CircuitCounts{}
+		}
+		b.counts.Requests++
+		if failed {
+			b.counts.Failures++
+		}
+		if b.counts.Requests >= b.options.MinRequests &&
+			float64(b.counts.Failures) >= b.options.ErrorRate*float64(b.counts.Requests) && b.counts.Failures > 0 {
+			b.state, b.openedAt = CircuitOpen, now
+		}
+	case CircuitHalfOpen:
+		if failed {
+			b.state, b.openedAt = CircuitOpen, now
+			break
+		}
+		b.successes++
+		if b.successes >= b.options.HalfOpenProbes {
+			b.state, b.windowStart, b.counts = CircuitClosed, now, CircuitCounts{}
+		}
+	}
+	// the calls allowed before the breaker opened are not counted once it did
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+func (b *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && b.options.OnStateChange != nil {
+		b.options.OnStateChange(b.name, from, to)
+	}
+}
+
+// borrow borrows a connection from pool when the breaker allows it, and wraps it so that the outcome of the call
+// is recorded when the connection is closed
+func (b *CircuitBreaker) borrow(ctx context.Context, pool ConnPool) (redis.Conn, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			// a cancelled borrow tells nothing about the host
+			b.release()
+		} else {
+			b.record(true)
+		}
+		return nil, err
+	}
+	return &circuitConn{Conn: conn, breaker: b}, nil
+}
+
+// circuitConn records whether the commands issued on a borrowed connection failed, and reports it to the breaker on Close
+type circuitConn struct {
+	redis.Conn
+	breaker *CircuitBreaker
+	failed  bool
+	closed  bool
+}
+
+func (c *circuitConn) observe(err error, start time.Time) {
+	slow := c.breaker.options.SlowCallDuration
+	if isTransientError(err) || (slow > 0 && time.Since(start) > slow) {
+		c.failed = true
+	}
+}
+
+func (c *circuitConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	start := time.Now()
+	reply, err := c.Conn.Do(cmd, args...)
+	c.observe(err, start)
+	return reply, err
+}
+
+func (c *circuitConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	start := time.Now()
+	reply, err := redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+	c.observe(err, start)
+	return reply, err
+}
+
+func (c *circuitConn) Send(cmd string, args ...interface{}) error {
+	err := c.Conn.Send(cmd, args...)
+	c.observe(err, time.Now())
+	return err
+}
+
+func (c *circuitConn) Flush() error {
+	err := c.Conn.Flush()
+	c.observe(err, time.Now())
+	return err
+}
+
+func (c *circuitConn) Receive() (interface{}, error) {
+	start := time.Now()
+	reply, err := c.Conn.Receive()
+	c.observe(err, start)
+	return reply, err
+}
+
+func (c *circuitConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	start := time.Now()
+	reply, err := redis.ReceiveWithTimeout(c.Conn, timeout)
+	c.observe(err, start)
+	return reply, err
+}
+
+func (c *circuitConn) abort() {
+	abortConn(c.Conn)
+}
+
+func (c *circuitConn) Close() error {
+	if !c.closed {
+		c.closed = true
+		c.breaker.record(c.failed)
+	}
+	return c.Conn.Close()
+}
+
+// CircuitBreakerPool guards a ConnPool with a CircuitBreaker, failing fast with ErrCircuitOpen while it is open.
+// It only exposes the ConnPool interface of the pool it wraps, so sharded pools should not be wrapped.
+// MultiHostPool guards each host with its own breaker when ClientOptions.CircuitBreaker is set.
+// The statistics of the wrapped pool are reported by ConnStats.
+type CircuitBreakerPool struct {
+	ConnPool
+	breaker *CircuitBreaker
+}
+
+// NewCircuitBreakerPool wraps pool with a circuit breaker named name
+func NewCircuitBreakerPool(pool ConnPool, name string, options CircuitBreakerOptions) *CircuitBreakerPool {
+	return &CircuitBreakerPool{ConnPool: pool, breaker: NewCircuitBreaker(name, options)}
+}
+
+// Breaker returns the circuit breaker of the pool, exposing its state
+func (p *CircuitBreakerPool) Breaker() *CircuitBreaker {
+	return p.breaker
+}
+
+// ConnStats returns the statistics of the wrapped pool, which are empty when it does not implement StatsConnPool
+func (p *CircuitBreakerPool) ConnStats() ConnPoolStats {
+	if pool, ok := p.ConnPool.(StatsConnPool); ok {
+		return pool.ConnStats()
+	}
+	return ConnPoolStats{Hosts: map[string]PoolStats{}}
+}
+
+// Close closes the wrapped pool
+func (p *CircuitBreakerPool) Close() error {
+	return p.ConnPool.Close()
+}
+
+func (p *CircuitBreakerPool) Get() redis.Conn {
+	conn, err := p.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return conn
+}
+
+func (p *CircuitBreakerPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	return p.breaker.borrow(ctx, p.ConnPool)
+}
+
+var _ StatsConnPool = (*CircuitBreakerPool)(nil)
@@ -0,0 +1,167 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var mu sync.Mutex
+	var transitions []string
+	options := NewCircuitBreakerOptions().SetTrip(0.5, 4, time.Minute).SetHalfOpen(20*time.Millisecond, 1).
+		SetOnStateChange(func(name string, from, to CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, name+": "+from.String()+" -> "+to.String())
+		})
+	b := NewCircuitBreaker("host", *options)
+
+	// the breaker does not trip before MinRequests calls
+	for _, failed := range []bool{true, true, false} {
+		assert.Nil(t, b.allow())
+		b.record(failed)
+	}
+	assert.Equal(t, CircuitClosed, b.State())
+	assert.Equal(t, CircuitCounts{Requests: 3, Failures: 2}, b.Counts())
+	assert.Nil(t, b.allow())
+	b.record(false)
+	assert.Equal(t, CircuitOpen, b.State())
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	// a single probe is let through once half-open, and its failure opens the breaker again
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	assert.Nil(t, b.allow())
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+	b.record(true)
+	assert.Equal(t, CircuitOpen, b.State())
+
+	// a successful probe closes it
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, b.allow())
+	b.record(false)
+	assert.Equal(t, CircuitClosed, b.State())
+	assert.Equal(t, CircuitCounts{}, b.Counts())
+
+	assert.Equal(t, []string{
+		"host: closed -> open",
+		"host: open -> half-open",
+		"host: half-open -> open",
+		"host: open -> half-open",
+		"host: half-open -> closed",
+	}, transitions)
+}
+
+func TestCircuitBreaker_CancelledBorrow(t *testing.T) {
+	b := NewCircuitBreaker("host", *NewCircuitBreakerOptions().SetTrip(1, 1, time.Minute).SetHalfOpen(time.Millisecond, 1))
+	assert.Nil(t, b.allow())
+	b.record(true)
+	time.Sleep(time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+
+	// the cancelled borrow neither closes the breaker nor keeps the probe slot
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stub := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) { return int64(1), nil }}
+	_, err := b.borrow(ctx, stub)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	conn, err := b.borrow(context.Background(), stub)
+	assert.Nil(t, err)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	conn.Close()
+	assert.Equal(t, CircuitClosed, b.State())
+}
+
+func TestCircuitBreakerPool_ConnStats(t *testing.T) {
+	s := newRespServer(t, pongHandler)
+	defer s.close()
+	c := NewClientWithOptions(s.addr(), "test", *NewClientOptions().SetCircuitBreaker(NewCircuitBreakerOptions()))
+	defer c.Pool.Close()
+	_, ok := c.Pool.(*CircuitBreakerPool)
+	assert.True(t, ok)
+	conn := c.Pool.Get()
+	_, err := conn.Do("PING")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, poolStats(c.Pool)[s.addr()].ActiveCount)
+	conn.Close()
+	assert.Equal(t, 1, poolStats(c.Pool)[s.addr()].IdleCount)
+	assert.Equal(t, ConnPoolStats{Hosts: map[string]PoolStats{}}, NewCircuitBreakerPool(&stubPool{}, "stub", DefaultCircuitBreakerOptions).ConnStats())
+}
+
+func TestCircuitBreakerPool(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *CircuitBreakerOptions
+		err      error
+		delay    time.Duration
+		wantOpen bool
+	}{
+		{"timeouts", NewCircuitBreakerOptions().SetTrip(0.5, 2, time.Minute), &net.OpError{Op: "read", Net: "tcp", Err: errors.New("i/o timeout")}, 0, true},
+		{"busy", NewCircuitBreakerOptions().SetTrip(0.5, 2, time.Minute), redis.Error("BUSY Redis is busy running a script"), 0, true},
+		{"server errors", NewCircuitBreakerOptions().SetTrip(0.5, 2, time.Minute), redis.Error("ERR TSDB: the key does not exist"), 0, false},
+		{"slow calls", NewCircuitBreakerOptions().SetTrip(0.5, 2, time.Minute).SetSlowCallDuration(time.Millisecond), nil, 5 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			stub := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+				calls++
+				time.Sleep(tt.delay)
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return int64(1), nil
+			}}
+			pool := NewCircuitBreakerPool(stub, "stub", *tt.options)
+			c := &Client{Pool: pool, Name: "test"}
+			for n := 0; n < 2; n++ {
+				c.Add("a", 1, 1) //nolint:errcheck
+			}
+			_, err := c.AddCtx(context.Background(), "a", 1, 1)
+			if tt.wantOpen {
+				assert.Equal(t, ErrCircuitOpen, err)
+				assert.Equal(t, CircuitOpen, pool.Breaker().State())
+				assert.Equal(t, 2, calls)
+			} else {
+				assert.NotEqual(t, ErrCircuitOpen, err)
+				assert.Equal(t, CircuitClosed, pool.Breaker().State())
+				assert.Equal(t, 3, calls)
+			}
+		})
+	}
+}
+
+func TestMultiHostPool_CircuitBreaker(t *testing.T) {
+	s := newRespServer(t, pongHandler)
+	defer s.close()
+	breaker := NewCircuitBreakerOptions().SetTrip(1, 1, time.Minute).SetHalfOpen(time.Hour, 1)
+	options := NewClientOptions().SetStrategy(NewRoundRobinStrategy()).SetHealthCheck(0, 0).SetCircuitBreaker(breaker)
+
+	p := NewMultiHostPoolWithOptions([]string{deadAddr(t), s.addr()}, *options)
+	defer p.Close()
+	// the first borrow fails on the dead host and trips its breaker, which keeps it from being selected again
+	_, err := p.GetContext(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, CircuitOpen, p.Hosts()[0].Circuit)
+	for n := 0; n < 4; n++ {
+		conn, err := p.GetContext(context.Background())
+		assert.Nil(t, err)
+		conn.Close()
+	}
+	assert.Equal(t, CircuitClosed, p.Hosts()[1].Circuit)
+
+	dead := NewMultiHostPoolWithOptions([]string{deadAddr(t)}, *options)
+	defer dead.Close()
+	_, err = dead.GetContext(context.Background())
+	assert.NotNil(t, err)
+	_, err = dead.GetContext(context.Background())
+	assert.Equal(t, ErrCircuitOpen, err)
+}
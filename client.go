@@ -1,14 +1,14 @@
 package redis_timeseries_go
 
 import (
+	"context"
 	"strings"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
-// TODO: refactor this hard limit and revise client locking
-// Client Max Connections
+// Client Max Connections, the default MaxIdle of ClientOptions
 var maxConns = 500
 
 // NewClient creates a new client connecting to the redis host, and using the given name as key prefix.
@@ -38,6 +38,15 @@ func NewClientFromPool(pool *redis.Pool, name string) *Client {
 	return ret
 }
 
+// NewClientFromConnPool creates a new Client with the given ConnPool implementation, such as a ClusterPool or a SentinelPool, and client name
+func NewClientFromConnPool(pool ConnPool, name string) *Client {
+	ret := &Client{
+		Pool: pool,
+		Name: name,
+	}
+	return ret
+}
+
 // CreateKey create a new time-series
 // Deprecated: This function has been deprecated, use CreateKeyWithOptions instead
 func (client *Client) CreateKey(key string, retentionTime time.Duration) (err error) {
@@ -49,29 +58,37 @@ func (client *Client) CreateKey(key string, retentionTime time.Duration) (err er
 }
 
 func (client *Client) CreateKeyWithOptions(key string, options CreateOptions) (err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.CreateKeyWithOptionsCtx(context.Background(), key, options)
+}
 
+// CreateKeyWithOptionsCtx - Create a new time-series, honoring the deadline and cancellation of ctx
+func (client *Client) CreateKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) (err error) {
+	ns := client.namespace()
+	key, options = ns.key(key), ns.createOptions(options)
 	args := []interface{}{key}
 	args, err = options.SerializeSeriesOptions(CREATE_CMD, args)
 	if err != nil {
 		return
 	}
-	_, err = conn.Do(CREATE_CMD, args...)
+	_, err = client.do(ctx, key, CREATE_CMD, args...)
 	return err
 }
 
 // Update the retention, labels of an existing key. The parameters are the same as TS.CREATE.
 func (client *Client) AlterKeyWithOptions(key string, options CreateOptions) (err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.AlterKeyWithOptionsCtx(context.Background(), key, options)
+}
 
+// AlterKeyWithOptionsCtx - Update the retention, labels of an existing key, honoring the deadline and cancellation of ctx
+func (client *Client) AlterKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) (err error) {
+	ns := client.namespace()
+	key, options = ns.key(key), ns.alterOptions(options)
 	args := []interface{}{key}
 	args, err = options.SerializeSeriesOptions(ALTER_CMD, args)
 	if err != nil {
 		return
 	}
-	_, err = conn.Do(ALTER_CMD, args...)
+	_, err = client.do(ctx, key, ALTER_CMD, args...)
 	return err
 }
 
@@ -81,9 +98,14 @@ func (client *Client) AlterKeyWithOptions(key string, options CreateOptions) (er
 // timestamp - time of value
 // value - value
 func (client *Client) Add(key string, timestamp int64, value float64) (storedTimestamp int64, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	return redis.Int64(conn.Do(ADD_CMD, key, timestamp, floatToStr(value)))
+	return client.AddCtx(context.Background(), key, timestamp, value)
+}
+
+// AddCtx - Append (or create and append) a new sample to the series, honoring the deadline and cancellation of ctx
+func (client *Client) AddCtx(ctx context.Context, key string, timestamp int64, value float64) (storedTimestamp int64, err error) {
+	ns := client.namespace()
+	key = ns.key(key)
+	return redis.Int64(client.do(ctx, key, ADD_CMD, ns.labelArgs([]interface{}{key, timestamp, floatToStr(value)})...))
 }
 
 // AddAutoTs - Append (or create and append) a new sample to the series, with DB automatic timestamp (using the system clock)
@@ -91,9 +113,15 @@ func (client *Client) Add(key string, timestamp int64, value float64) (storedTim
 // key - time series key name
 // value - value
 func (client *Client) AddAutoTs(key string, value float64) (storedTimestamp int64, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	return redis.Int64(conn.Do(ADD_CMD, key, "*", floatToStr(value)))
+	return client.AddAutoTsCtx(context.Background(), key, value)
+}
+
+// AddAutoTsCtx - Append (or create and append) a new sample to the series, with DB automatic timestamp,
+// honoring the deadline and cancellation of ctx
+func (client *Client) AddAutoTsCtx(ctx context.Context, key string, value float64) (storedTimestamp int64, err error) {
+	ns := client.namespace()
+	key = ns.key(key)
+	return redis.Int64(client.do(ctx, key, ADD_CMD, ns.labelArgs([]interface{}{key, "*", floatToStr(value)})...))
 }
 
 // AddWithOptions - Append (or create and append) a new sample to the series, with the specified CreateOptions
@@ -103,15 +131,20 @@ func (client *Client) AddAutoTs(key string, value float64) (storedTimestamp int6
 // value - value
 // options - define options for create key on add
 func (client *Client) AddWithOptions(key string, timestamp int64, value float64, options CreateOptions) (storedTimestamp int64, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.AddWithOptionsCtx(context.Background(), key, timestamp, value, options)
+}
 
+// AddWithOptionsCtx - Append (or create and append) a new sample to the series, with the specified CreateOptions,
+// honoring the deadline and cancellation of ctx
+func (client *Client) AddWithOptionsCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (storedTimestamp int64, err error) {
+	ns := client.namespace()
+	key, options = ns.key(key), ns.createOptions(options)
 	args := []interface{}{key, timestamp, floatToStr(value)}
 	args, err = options.SerializeSeriesOptions(ADD_CMD, args)
 	if err != nil {
 		return
 	}
-	return redis.Int64(conn.Do(ADD_CMD, args...))
+	return redis.Int64(client.do(ctx, key, ADD_CMD, args...))
 }
 
 // AddAutoTsWithOptions - Append (or create and append) a new sample to the series, with the specified CreateOptions and DB automatic timestamp (using the system clock)
@@ -120,14 +153,20 @@ func (client *Client) AddWithOptions(key string, timestamp int64, value float64,
 // value - value
 // options - define options for create key on add
 func (client *Client) AddAutoTsWithOptions(key string, value float64, options CreateOptions) (storedTimestamp int64, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.AddAutoTsWithOptionsCtx(context.Background(), key, value, options)
+}
+
+// AddAutoTsWithOptionsCtx - Append (or create and append) a new sample to the series, with the specified CreateOptions
+// and DB automatic timestamp, honoring the deadline and cancellation of ctx
+func (client *Client) AddAutoTsWithOptionsCtx(ctx context.Context, key string, value float64, options CreateOptions) (storedTimestamp int64, err error) {
+	ns := client.namespace()
+	key, options = ns.key(key), ns.createOptions(options)
 	args := []interface{}{key, "*", floatToStr(value)}
 	args, err = options.SerializeSeriesOptions(ADD_CMD, args)
 	if err != nil {
 		return
 	}
-	return redis.Int64(conn.Do(ADD_CMD, args...))
+	return redis.Int64(client.do(ctx, key, ADD_CMD, args...))
 }
 
 // AddWithRetention - append a new value to the series with a duration
@@ -149,9 +188,13 @@ func (client *Client) AddWithRetention(key string, timestamp int64, value float6
 // args:
 // key - time series key name
 func (client *Client) DeleteSerie(key string) (err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	_, err = conn.Do(DEL_CMD, key)
+	return client.DeleteSerieCtx(context.Background(), key)
+}
+
+// DeleteSerieCtx - deletes series given the time series key name, honoring the deadline and cancellation of ctx
+func (client *Client) DeleteSerieCtx(ctx context.Context, key string) (err error) {
+	key = client.namespace().key(key)
+	_, err = client.do(ctx, key, DEL_CMD, key)
 	return err
 }
 
@@ -162,9 +205,13 @@ func (client *Client) DeleteSerie(key string) (err error) {
 //  fromTimestamp - start of range. You can use TimeRangeMinimum to express the minimum possible timestamp.
 //  toTimestamp - end of range. You can use TimeRangeFull or TimeRangeMaximum to express the maximum possible timestamp.
 func (client *Client) DeleteRange(key string, fromTimestamp int64, toTimestamp int64) (totalDeletedSamples int64, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	totalDeletedSamples, err = redis.Int64(conn.Do(TS_DEL_CMD, key, fromTimestamp, toTimestamp))
+	return client.DeleteRangeCtx(context.Background(), key, fromTimestamp, toTimestamp)
+}
+
+// DeleteRangeCtx - Delete data points for a given timeseries and interval range, honoring the deadline and cancellation of ctx
+func (client *Client) DeleteRangeCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64) (totalDeletedSamples int64, err error) {
+	key = client.namespace().key(key)
+	totalDeletedSamples, err = redis.Int64(client.do(ctx, key, TS_DEL_CMD, key, fromTimestamp, toTimestamp))
 	return
 }
 
@@ -175,9 +222,14 @@ func (client *Client) DeleteRange(key string, fromTimestamp int64, toTimestamp i
 // bucketSizeMSec - Time bucket for aggregation in milliseconds
 // destinationKey - key name for destination time series
 func (client *Client) CreateRule(sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) (err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	_, err = conn.Do(CREATERULE_CMD, sourceKey, destinationKey, "AGGREGATION", aggType, bucketSizeMSec)
+	return client.CreateRuleCtx(context.Background(), sourceKey, aggType, bucketSizeMSec, destinationKey)
+}
+
+// CreateRuleCtx - create a compaction rule, honoring the deadline and cancellation of ctx
+func (client *Client) CreateRuleCtx(ctx context.Context, sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) (err error) {
+	ns := client.namespace()
+	sourceKey, destinationKey = ns.key(sourceKey), ns.key(destinationKey)
+	_, err = client.do(ctx, sourceKey, CREATERULE_CMD, sourceKey, destinationKey, "AGGREGATION", aggType, bucketSizeMSec)
 	return err
 }
 
@@ -186,9 +238,14 @@ func (client *Client) CreateRule(sourceKey string, aggType AggregationType, buck
 // sourceKey - key name for source time series
 // destinationKey - key name for destination time series
 func (client *Client) DeleteRule(sourceKey string, destinationKey string) (err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	_, err = conn.Do(DELETERULE_CMD, sourceKey, destinationKey)
+	return client.DeleteRuleCtx(context.Background(), sourceKey, destinationKey)
+}
+
+// DeleteRuleCtx - delete a compaction rule, honoring the deadline and cancellation of ctx
+func (client *Client) DeleteRuleCtx(ctx context.Context, sourceKey string, destinationKey string) (err error) {
+	ns := client.namespace()
+	sourceKey, destinationKey = ns.key(sourceKey), ns.key(destinationKey)
+	_, err = client.do(ctx, sourceKey, DELETERULE_CMD, sourceKey, destinationKey)
 	return err
 }
 
@@ -225,7 +282,12 @@ func (client *Client) AggRange(key string, fromTimestamp int64, toTimestamp int6
 // toTimestamp - end of range. You can use TimeRangeFull or TimeRangeMaximum to express the maximum possible timestamp.
 // rangeOptions - RangeOptions options. You can use the default DefaultRangeOptions
 func (client *Client) RangeWithOptions(key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) (dataPoints []DataPoint, err error) {
-	return client.rangeWithOptions(RANGE_CMD, key, fromTimestamp, toTimestamp, rangeOptions)
+	return client.rangeWithOptions(context.Background(), RANGE_CMD, key, fromTimestamp, toTimestamp, rangeOptions)
+}
+
+// RangeWithOptionsCtx - Query a timestamp range on a specific time-series, honoring the deadline and cancellation of ctx
+func (client *Client) RangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) (dataPoints []DataPoint, err error) {
+	return client.rangeWithOptions(ctx, RANGE_CMD, key, fromTimestamp, toTimestamp, rangeOptions)
 }
 
 // ReverseRangeWithOptions - Query a timestamp range on a specific time-series in reverse order
@@ -235,26 +297,42 @@ func (client *Client) RangeWithOptions(key string, fromTimestamp int64, toTimest
 // toTimestamp - end of range. You can use TimeRangeFull or TimeRangeMaximum to express the maximum possible timestamp.
 // rangeOptions - RangeOptions options. You can use the default DefaultRangeOptions
 func (client *Client) ReverseRangeWithOptions(key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) (dataPoints []DataPoint, err error) {
-	return client.rangeWithOptions(REVRANGE_CMD, key, fromTimestamp, toTimestamp, rangeOptions)
+	return client.rangeWithOptions(context.Background(), REVRANGE_CMD, key, fromTimestamp, toTimestamp, rangeOptions)
+}
+
+// ReverseRangeWithOptionsCtx - Query a timestamp range on a specific time-series in reverse order,
+// honoring the deadline and cancellation of ctx
+func (client *Client) ReverseRangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) (dataPoints []DataPoint, err error) {
+	return client.rangeWithOptions(ctx, REVRANGE_CMD, key, fromTimestamp, toTimestamp, rangeOptions)
 }
 
 // rangeWithOptions - Query a timestamp range on a specific time-series in some order
 // args:
+// ctx - context bounding the query
 // command - range command to run
 // key - time-series key name
 // fromTimestamp - start of range. You can use TimeRangeMinimum to express the minimum possible timestamp.
 // toTimestamp - end of range. You can use TimeRangeFull or TimeRangeMaximum to express the maximum possible timestamp.
 // rangeOptions - RangeOptions options. You can use the default DefaultRangeOptions
-func (client *Client) rangeWithOptions(command string, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) (dataPoints []DataPoint, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+func (client *Client) rangeWithOptions(ctx context.Context, command string, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) (dataPoints []DataPoint, err error) {
 	var reply interface{}
+	key = client.namespace().key(key)
+	emulated, err := client.emulatedQuery(ctx, command, rangeOptions.multiRangeOptions())
+	if err != nil {
+		return nil, err
+	}
+	if emulated != nil {
+		command, rangeOptions = emulated.command(command), emulated.rangeOptions(rangeOptions)
+	}
 	args := createRangeCmdArguments(key, fromTimestamp, toTimestamp, rangeOptions)
-	reply, err = conn.Do(command, args...)
+	reply, err = client.do(ctx, key, command, args...)
 	if err != nil {
 		return
 	}
 	dataPoints, err = ParseDataPoints(reply)
+	if err == nil && emulated != nil {
+		dataPoints, err = emulated.apply(dataPoints)
+	}
 	return
 }
 
@@ -280,7 +358,13 @@ func (client *Client) AggMultiRange(fromTimestamp int64, toTimestamp int64, aggT
 // mrangeOptions - MultiRangeOptions options. You can use the default DefaultMultiRangeOptions
 // filters - list of filters e.g. "a=bb", "b!=aa"
 func (client *Client) MultiRangeWithOptions(fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) (ranges []Range, err error) {
-	return client.multiRangeWithOptions(MRANGE_CMD, fromTimestamp, toTimestamp, mrangeOptions, filters)
+	return client.multiRangeWithOptions(context.Background(), MRANGE_CMD, fromTimestamp, toTimestamp, mrangeOptions, filters)
+}
+
+// MultiRangeWithOptionsCtx - Query a timestamp range across multiple time-series by filters,
+// honoring the deadline and cancellation of ctx
+func (client *Client) MultiRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) (ranges []Range, err error) {
+	return client.multiRangeWithOptions(ctx, MRANGE_CMD, fromTimestamp, toTimestamp, mrangeOptions, filters)
 }
 
 // MultiReverseRangeWithOptions - Query a timestamp range across multiple time-series by filters, in reverse direction.
@@ -290,20 +374,42 @@ func (client *Client) MultiRangeWithOptions(fromTimestamp int64, toTimestamp int
 // mrangeOptions - MultiRangeOptions options. You can use the default DefaultMultiRangeOptions
 // filters - list of filters e.g. "a=bb", "b!=aa"
 func (client *Client) MultiReverseRangeWithOptions(fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) (ranges []Range, err error) {
-	return client.multiRangeWithOptions(MREVRANGE_CMD, fromTimestamp, toTimestamp, mrangeOptions, filters)
+	return client.multiRangeWithOptions(context.Background(), MREVRANGE_CMD, fromTimestamp, toTimestamp, mrangeOptions, filters)
 }
 
-func (client *Client) multiRangeWithOptions(cmd string, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters []string) (ranges []Range, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+// MultiReverseRangeWithOptionsCtx - Query a timestamp range across multiple time-series by filters, in reverse direction,
+// honoring the deadline and cancellation of ctx
+func (client *Client) MultiReverseRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) (ranges []Range, err error) {
+	return client.multiRangeWithOptions(ctx, MREVRANGE_CMD, fromTimestamp, toTimestamp, mrangeOptions, filters)
+}
+
+func (client *Client) multiRangeWithOptions(ctx context.Context, cmd string, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters []string) (ranges []Range, err error) {
 	var reply interface{}
-	args := createMultiRangeCmdArguments(fromTimestamp, toTimestamp, mrangeOptions, filters)
-	reply, err = conn.Do(cmd, args...)
+	ns := client.namespace()
+	emulated, err := client.emulatedQuery(ctx, cmd, mrangeOptions)
 	if err != nil {
-		return
+		return nil, err
 	}
-	ranges, err = ParseRanges(reply)
-	return
+	if emulated != nil {
+		cmd, mrangeOptions = emulated.command(cmd), emulated.multiRangeOptions(mrangeOptions)
+	}
+	args := createMultiRangeCmdArguments(fromTimestamp, toTimestamp, mrangeOptions, ns.filters(filters))
+	if sharded, ok := client.Pool.(ShardedConnPool); ok {
+		ranges, err = client.multiRangeSharded(ctx, sharded, cmd, args, mrangeOptions)
+	} else {
+		reply, err = client.do(ctx, "", cmd, args...)
+		if err != nil {
+			return
+		}
+		ranges, err = ParseRanges(reply)
+	}
+	if err == nil && emulated != nil {
+		ranges, err = emulated.applyRanges(ranges)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ns.stripRanges(ranges), nil
 }
 
 // Get - Get the last sample of a time-series.
@@ -311,9 +417,14 @@ func (client *Client) multiRangeWithOptions(cmd string, fromTimestamp int64, toT
 // key - time-series key name
 func (client *Client) Get(key string) (dataPoint *DataPoint,
 	err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	resp, err := conn.Do(GET_CMD, key)
+	return client.GetCtx(context.Background(), key)
+}
+
+// GetCtx - Get the last sample of a time-series, honoring the deadline and cancellation of ctx
+func (client *Client) GetCtx(ctx context.Context, key string) (dataPoint *DataPoint,
+	err error) {
+	key = client.namespace().key(key)
+	resp, err := client.do(ctx, key, GET_CMD, key)
 	if err != nil {
 		return nil, err
 	}
@@ -325,7 +436,13 @@ func (client *Client) Get(key string) (dataPoint *DataPoint,
 // args:
 // filters - list of filters e.g. "a=bb", "b!=aa"
 func (client *Client) MultiGet(filters ...string) (ranges []Range, err error) {
-	return client.MultiGetWithOptions(DefaultMultiGetOptions, filters...)
+	return client.MultiGetWithOptionsCtx(context.Background(), DefaultMultiGetOptions, filters...)
+}
+
+// MultiGetCtx - Get the last sample across multiple time-series, matching the specific filters,
+// honoring the deadline and cancellation of ctx
+func (client *Client) MultiGetCtx(ctx context.Context, filters ...string) (ranges []Range, err error) {
+	return client.MultiGetWithOptionsCtx(ctx, DefaultMultiGetOptions, filters...)
 }
 
 // MultiGetWithOptions - Get the last samples matching the specific filters.
@@ -333,93 +450,139 @@ func (client *Client) MultiGet(filters ...string) (ranges []Range, err error) {
 // multiGetOptions - MultiGetOptions options. You can use the default DefaultMultiGetOptions
 // filters - list of filters e.g. "a=bb", "b!=aa"
 func (client *Client) MultiGetWithOptions(multiGetOptions MultiGetOptions, filters ...string) (ranges []Range, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.MultiGetWithOptionsCtx(context.Background(), multiGetOptions, filters...)
+}
+
+// MultiGetWithOptionsCtx - Get the last samples matching the specific filters, honoring the deadline and cancellation of ctx
+func (client *Client) MultiGetWithOptionsCtx(ctx context.Context, multiGetOptions MultiGetOptions, filters ...string) (ranges []Range, err error) {
 	var reply interface{}
 	if len(filters) == 0 {
 		return
 	}
-	args := createMultiGetCmdArguments(multiGetOptions, filters)
-	reply, err = conn.Do(MGET_CMD, args...)
+	ns := client.namespace()
+	args := createMultiGetCmdArguments(multiGetOptions, ns.filters(filters))
+	if sharded, ok := client.Pool.(ShardedConnPool); ok {
+		ranges, err = client.multiGetSharded(ctx, sharded, args)
+	} else {
+		reply, err = client.do(ctx, "", MGET_CMD, args...)
+		if err != nil {
+			return
+		}
+		ranges, err = ParseRangesSingleDataPoint(reply)
+	}
 	if err != nil {
-		return
+		return nil, err
 	}
-	ranges, err = ParseRangesSingleDataPoint(reply)
-	return
+	return ns.stripRanges(ranges), nil
 }
 
 // Returns information and statistics on the time-series.
 // args:
 // key - time-series key name
 func (client *Client) Info(key string) (res KeyInfo, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	res, err = ParseInfo(conn.Do(INFO_CMD, key))
-	return res, err
+	return client.InfoCtx(context.Background(), key)
+}
+
+// InfoCtx returns information and statistics on the time-series, honoring the deadline and cancellation of ctx
+func (client *Client) InfoCtx(ctx context.Context, key string) (res KeyInfo, err error) {
+	ns := client.namespace()
+	key = ns.key(key)
+	res, err = ParseInfo(client.do(ctx, key, INFO_CMD, key))
+	if err != nil {
+		return res, err
+	}
+	return ns.stripInfo(res), nil
 }
 
 // Get all the keys matching the filter list.
 func (client *Client) QueryIndex(filters ...string) (keys []string, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.QueryIndexCtx(context.Background(), filters...)
+}
 
+// QueryIndexCtx gets all the keys matching the filter list, honoring the deadline and cancellation of ctx
+func (client *Client) QueryIndexCtx(ctx context.Context, filters ...string) (keys []string, err error) {
 	if len(filters) == 0 {
 		return
 	}
 
+	ns := client.namespace()
 	args := redis.Args{}
-	for _, filter := range filters {
+	for _, filter := range ns.filters(filters) {
 		args = args.Add(filter)
 	}
-	return redis.Strings(conn.Do(QUERYINDEX_CMD, args...))
+	if sharded, ok := client.Pool.(ShardedConnPool); ok {
+		keys, err = client.queryIndexSharded(ctx, sharded, args)
+	} else {
+		keys, err = redis.Strings(client.do(ctx, "", QUERYINDEX_CMD, args...))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ns.stripKeys(keys), nil
 }
 
 // Creates a new sample that increments the latest sample's value
 func (client *Client) IncrBy(key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.IncrByCtx(context.Background(), key, timestamp, value, options)
+}
 
+// IncrByCtx creates a new sample that increments the latest sample's value, honoring the deadline and cancellation of ctx
+func (client *Client) IncrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	ns := client.namespace()
+	key, options = ns.key(key), ns.createOptions(options)
 	args, err := AddCounterArgs(key, timestamp, value, options)
 	if err != nil {
 		return -1, err
 	}
-	return redis.Int64(conn.Do(INCRBY_CMD, args...))
+	return redis.Int64(client.do(ctx, key, INCRBY_CMD, args...))
 }
 
 // Creates a new sample that increments the latest sample's value with an auto timestamp
 func (client *Client) IncrByAutoTs(key string, value float64, options CreateOptions) (int64, error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.IncrByAutoTsCtx(context.Background(), key, value, options)
+}
 
+// IncrByAutoTsCtx creates a new sample that increments the latest sample's value with an auto timestamp, honoring the deadline and cancellation of ctx
+func (client *Client) IncrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error) {
+	ns := client.namespace()
+	key, options = ns.key(key), ns.createOptions(options)
 	args, err := AddCounterArgs(key, -1, value, options)
 	if err != nil {
 		return -1, err
 	}
-	return redis.Int64(conn.Do(INCRBY_CMD, args...))
+	return redis.Int64(client.do(ctx, key, INCRBY_CMD, args...))
 }
 
 // Creates a new sample that decrements the latest sample's value
 func (client *Client) DecrBy(key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.DecrByCtx(context.Background(), key, timestamp, value, options)
+}
 
+// DecrByCtx creates a new sample that decrements the latest sample's value, honoring the deadline and cancellation of ctx
+func (client *Client) DecrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	ns := client.namespace()
+	key, options = ns.key(key), ns.createOptions(options)
 	args, err := AddCounterArgs(key, timestamp, value, options)
 	if err != nil {
 		return -1, err
 	}
-	return redis.Int64(conn.Do(DECRBY_CMD, args...))
+	return redis.Int64(client.do(ctx, key, DECRBY_CMD, args...))
 }
 
 // Creates a new sample that decrements the latest sample's value with auto timestamp
 func (client *Client) DecrByAutoTs(key string, value float64, options CreateOptions) (int64, error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.DecrByAutoTsCtx(context.Background(), key, value, options)
+}
 
+// DecrByAutoTsCtx creates a new sample that decrements the latest sample's value with an auto timestamp, honoring the deadline and cancellation of ctx
+func (client *Client) DecrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error) {
+	ns := client.namespace()
+	key, options = ns.key(key), ns.createOptions(options)
 	args, err := AddCounterArgs(key, -1, value, options)
 	if err != nil {
 		return -1, err
 	}
-	return redis.Int64(conn.Do(DECRBY_CMD, args...))
+	return redis.Int64(client.do(ctx, key, DECRBY_CMD, args...))
 }
 
 // Add counter args for command TS.INCRBY/TS.DECRBY
@@ -433,17 +596,67 @@ func AddCounterArgs(key string, timestamp int64, value float64, options CreateOp
 }
 
 // Append new samples to a list of series.
+// The failure of a single sample is reported as an error element of timestamps, classified like the errors of the other methods.
 func (client *Client) MultiAdd(samples ...Sample) (timestamps []interface{}, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
+	return client.MultiAddCtx(context.Background(), samples...)
+}
 
+// MultiAddCtx appends new samples to a list of series, honoring the deadline and cancellation of ctx
+func (client *Client) MultiAddCtx(ctx context.Context, samples ...Sample) (timestamps []interface{}, err error) {
 	if len(samples) == 0 {
 		return
 	}
+	samples = client.namespace().samples(samples)
+
+	if sharded, ok := client.Pool.(ShardedConnPool); ok {
+		return client.multiAddSharded(ctx, sharded, samples)
+	}
+	return classifyReplies(redis.Values(client.do(ctx, "", MADD_CMD, multiAddArgs(samples)...)))
+}
 
+func multiAddArgs(samples []Sample) redis.Args {
 	args := redis.Args{}
 	for _, sample := range samples {
 		args = args.Add(sample.Key, sample.DataPoint.Timestamp, sample.DataPoint.Value)
 	}
-	return redis.Values(conn.Do(MADD_CMD, args...))
+	return args
+}
+
+// do issues a single command on a connection borrowed from the pool.
+// When the pool is a ShardedConnPool and key is not empty, the connection is taken from the node owning key.
+// Both borrowing the connection and waiting for the reply honor the deadline and cancellation of ctx.
+// Server errors are classified, so that they can be matched against the ErrXxx errors.
+// Idempotent commands are retried according to the RetryPolicy of the client.
+func (client *Client) do(ctx context.Context, key string, cmd string, args ...interface{}) (reply interface{}, err error) {
+	if err = client.checkSupported(ctx, cmd, args); err != nil {
+		return nil, err
+	}
+	err = client.retry(ctx, cmd, args, func() error {
+		conn, err := client.getConn(ctx, key, cmd)
+		if err != nil {
+			failedCommand(ctx, client.Hooks, "", key, cmd, len(args), err)
+			return err
+		}
+		reply, err = client.doHooked(ctx, conn, "", key, cmd, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// getConn borrows a connection suitable for issuing cmd on key. Both may be empty.
+func (client *Client) getConn(ctx context.Context, key string, cmd string) (redis.Conn, error) {
+	if sharded, ok := client.Pool.(ShardedConnPool); ok && key != "" {
+		node, err := sharded.NodeForKeyContext(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return getNodeContext(ctx, sharded, node)
+	}
+	if readOnly, ok := client.Pool.(ReadOnlyConnPool); ok && readOnlyCommands[cmd] {
+		return getReadOnlyContext(ctx, readOnly)
+	}
+	return getContext(ctx, client.Pool)
 }
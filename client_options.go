@@ -0,0 +1,298 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// PoolLimits are the connection limits of the pool of a single host
+type PoolLimits struct {
+	// MaxIdle is the maximum number of idle connections kept in the pool
+	MaxIdle int
+	// MaxActive is the maximum number of connections allocated by the pool at a given time. Zero means no limit.
+	MaxActive int
+}
+
+// CredentialsProvider returns the credentials used to authenticate a new connection.
+// It is invoked on each dial, so that rotated secrets are picked up by the new connections.
+// An empty username authenticates with the password only, as the default user.
+type CredentialsProvider func(ctx context.Context) (username string, password string, err error)
+
+// ClientOptions are the options used to dial the hosts and to pool their connections
+type ClientOptions struct {
+	// Network is the network of the host addresses, "tcp" when empty, or "unix" for socket paths
+	Network  string
+	AuthPass *string
+	// Username is the ACL user authenticated with AuthPass
+	Username string
+	// CredentialsProvider, when set, supersedes Username and AuthPass
+	CredentialsProvider CredentialsProvider
+	// TLSConfig, when set, dials the hosts with TLS. Its ServerName defaults to the dialed host.
+	TLSConfig *tls.Config
+	PoolLimits
+	// HostLimits overrides PoolLimits for the hosts it lists, by host:port address
+	HostLimits map[string]PoolLimits
+	// Wait makes borrowing a connection wait for one to be returned when MaxActive is reached, instead of failing
+	Wait bool
+	// IdleTimeout closes the connections which remained idle for longer. Zero keeps them open.
+	IdleTimeout time.Duration
+	// MaxConnLifetime closes the connections older than this duration when they are returned. Zero keeps them open.
+	MaxConnLifetime time.Duration
+	// TestOnBorrowInterval is the idle time after which a connection is checked with PING when borrowed.
+	// It defaults to one minute, so that the busy connections are not checked. Zero checks every borrowed connection,
+	// and a negative interval disables the check.
+	TestOnBorrowInterval time.Duration
+	ConnectTimeout       time.Duration
+	ReadTimeout          time.Duration
+	WriteTimeout         time.Duration
+	// Database is the database selected on every new connection
+	Database int
+	// ClientName is set with CLIENT SETNAME on every new connection
+	ClientName string
+	// DialOptions are appended to the options derived from the fields above
+	DialOptions []redis.DialOption
+	// Strategy selects the host of each connection borrowed from a MultiHostPool. Hosts are picked at random when nil.
+	Strategy BalancingStrategy
+	// HealthCheckInterval is the period of the PING sent in the background to every host of a MultiHostPool,
+	// ejecting the hosts which fail to answer within the interval, and re-admitting them once they do.
+	// Zero disables the health checks.
+	HealthCheckInterval time.Duration
+	// BorrowRetries is the number of other hosts a MultiHostPool tries when a connection to the selected host can not be dialed
+	BorrowRetries int
+	// CircuitBreaker, when set, guards each host with a circuit breaker failing fast with ErrCircuitOpen while it is open
+	CircuitBreaker *CircuitBreakerOptions
+}
+
+func NewClientOptions() *ClientOptions {
+	return &ClientOptions{
+		Network:              "tcp",
+		AuthPass:             nil,
+		Username:             "",
+		CredentialsProvider:  nil,
+		TLSConfig:            nil,
+		PoolLimits:           PoolLimits{MaxIdle: maxConns, MaxActive: 0},
+		HostLimits:           map[string]PoolLimits{},
+		Wait:                 false,
+		IdleTimeout:          0,
+		MaxConnLifetime:      0,
+		TestOnBorrowInterval: time.Minute,
+		ConnectTimeout:       0,
+		ReadTimeout:          0,
+		WriteTimeout:         0,
+		Database:             0,
+		ClientName:           "",
+		DialOptions:          []redis.DialOption{},
+		Strategy:             nil,
+		HealthCheckInterval:  0,
+		BorrowRetries:        2,
+		CircuitBreaker:       nil,
+	}
+}
+
+// DefaultClientOptions are the options of the pools created by NewClient
+var DefaultClientOptions = *NewClientOptions()
+
+// SetAuthPass sets the password sent with AUTH on every new connection
+func (options *ClientOptions) SetAuthPass(authPass string) *ClientOptions {
+	options.AuthPass = &authPass
+	return options
+}
+
+// SetACLAuth sets the ACL user and password authenticated with AUTH on every new connection
+func (options *ClientOptions) SetACLAuth(username, password string) *ClientOptions {
+	options.Username = username
+	options.AuthPass = &password
+	return options
+}
+
+// SetCredentialsProvider sets the function providing the credentials of every new connection
+func (options *ClientOptions) SetCredentialsProvider(provider CredentialsProvider) *ClientOptions {
+	options.CredentialsProvider = provider
+	return options
+}
+
+// SetTLSConfig enables TLS with the given configuration, holding the CA certificates, client certificates and server name
+func (options *ClientOptions) SetTLSConfig(config *tls.Config) *ClientOptions {
+	options.TLSConfig = config
+	return options
+}
+
+// SetPoolLimits sets the connection limits of the pool of each host
+func (options *ClientOptions) SetPoolLimits(maxIdle, maxActive int, wait bool) *ClientOptions {
+	options.MaxIdle = maxIdle
+	options.MaxActive = maxActive
+	options.Wait = wait
+	return options
+}
+
+// SetHostLimits overrides the connection limits of the pool of the given host
+func (options *ClientOptions) SetHostLimits(host string, maxIdle, maxActive int) *ClientOptions {
+	if options.HostLimits == nil {
+		options.HostLimits = map[string]PoolLimits{}
+	}
+	options.HostLimits[host] = PoolLimits{MaxIdle: maxIdle, MaxActive: maxActive}
+	return options
+}
+
+// SetConnLifetime sets how long connections may remain idle, and how long they may be used overall
+func (options *ClientOptions) SetConnLifetime(idleTimeout, maxConnLifetime time.Duration) *ClientOptions {
+	options.IdleTimeout = idleTimeout
+	options.MaxConnLifetime = maxConnLifetime
+	return options
+}
+
+// SetTestOnBorrowInterval sets the idle time after which a connection is checked with PING when borrowed
+func (options *ClientOptions) SetTestOnBorrowInterval(interval time.Duration) *ClientOptions {
+	options.TestOnBorrowInterval = interval
+	return options
+}
+
+// SetTimeouts sets the connect, read and write timeouts of the connections
+func (options *ClientOptions) SetTimeouts(connectTimeout, readTimeout, writeTimeout time.Duration) *ClientOptions {
+	options.ConnectTimeout = connectTimeout
+	options.ReadTimeout = readTimeout
+	options.WriteTimeout = writeTimeout
+	return options
+}
+
+// SetDatabase sets the database selected on every new connection
+func (options *ClientOptions) SetDatabase(database int) *ClientOptions {
+	options.Database = database
+	return options
+}
+
+// SetClientName sets the connection name set with CLIENT SETNAME on every new connection
+func (options *ClientOptions) SetClientName(clientName string) *ClientOptions {
+	options.ClientName = clientName
+	return options
+}
+
+// AddDialOptions appends redigo dial options, applied after the ones derived from the other options
+func (options *ClientOptions) AddDialOptions(dialOptions ...redis.DialOption) *ClientOptions {
+	options.DialOptions = append(options.DialOptions, dialOptions...)
+	return options
+}
+
+// SetStrategy sets the strategy selecting the host of each connection borrowed from a MultiHostPool
+func (options *ClientOptions) SetStrategy(strategy BalancingStrategy) *ClientOptions {
+	options.Strategy = strategy
+	return options
+}
+
+// SetHealthCheck sets the period of the health checks of the hosts of a MultiHostPool, and the number of other hosts
+// tried when dialing the selected one fails
+func (options *ClientOptions) SetHealthCheck(interval time.Duration, borrowRetries int) *ClientOptions {
+	options.HealthCheckInterval = interval
+	options.BorrowRetries = borrowRetries
+	return options
+}
+
+// SetCircuitBreaker guards each host with a circuit breaker configured by circuitBreaker
+func (options *ClientOptions) SetCircuitBreaker(circuitBreaker *CircuitBreakerOptions) *ClientOptions {
+	options.CircuitBreaker = circuitBreaker
+	return options
+}
+
+// NewClientWithOptions creates a new client connecting to the redis host with the given options, and using the given name as key prefix.
+// Addr can be a single host:port pair, or a comma separated list of host:port,host:port...
+// In the case of multiple hosts we create a multi-pool, selecting connections according to options.Strategy
+func NewClientWithOptions(addr, name string, options ClientOptions) *Client {
+	addrs := strings.Split(addr, ",")
+	var pool ConnPool
+	if len(addrs) == 1 {
+		pool = NewSingleHostPoolWithOptions(addrs[0], options)
+		if options.CircuitBreaker != nil {
+			pool = NewCircuitBreakerPool(pool, addrs[0], *options.CircuitBreaker)
+		}
+	} else {
+		pool = NewMultiHostPoolWithOptions(addrs, options)
+	}
+	return &Client{
+		Pool: pool,
+		Name: name,
+	}
+}
+
+// withAuthPass returns the default options with the given password, as used by the constructors taking only a password
+func withAuthPass(authPass *string) ClientOptions {
+	options := DefaultClientOptions
+	options.AuthPass = authPass
+	return options
+}
+
+// newPool creates the connection pool of host
+func (options ClientOptions) newPool(host string) *redis.Pool {
+	limits := options.PoolLimits
+	if hostLimits, found := options.HostLimits[host]; found {
+		limits = hostLimits
+	}
+	return &redis.Pool{
+		DialContext:     options.dialFunc(host),
+		TestOnBorrow:    options.testOnBorrow(),
+		MaxIdle:         limits.MaxIdle,
+		MaxActive:       limits.MaxActive,
+		Wait:            options.Wait,
+		IdleTimeout:     options.IdleTimeout,
+		MaxConnLifetime: options.MaxConnLifetime,
+	}
+}
+
+func (options ClientOptions) dialFunc(host string) func(ctx context.Context) (redis.Conn, error) {
+	dialOptions := []redis.DialOption{redis.DialDatabase(options.Database)}
+	if options.AuthPass != nil && options.CredentialsProvider == nil {
+		dialOptions = append(dialOptions, redis.DialUsername(options.Username), redis.DialPassword(*options.AuthPass))
+	}
+	if options.TLSConfig != nil {
+		dialOptions = append(dialOptions, redis.DialUseTLS(true), redis.DialTLSConfig(options.TLSConfig))
+	}
+	if options.ClientName != "" {
+		dialOptions = append(dialOptions, redis.DialClientName(options.ClientName))
+	}
+	if options.ConnectTimeout > 0 {
+		dialOptions = append(dialOptions, redis.DialConnectTimeout(options.ConnectTimeout))
+	}
+	if options.ReadTimeout > 0 {
+		dialOptions = append(dialOptions, redis.DialReadTimeout(options.ReadTimeout))
+	}
+	if options.WriteTimeout > 0 {
+		dialOptions = append(dialOptions, redis.DialWriteTimeout(options.WriteTimeout))
+	}
+	dialOptions = append(dialOptions, options.DialOptions...)
+	network := options.Network
+	if network == "" {
+		network = "tcp"
+	}
+	provider := options.CredentialsProvider
+	return func(ctx context.Context) (redis.Conn, error) {
+		options := dialOptions
+		if provider != nil {
+			username, password, err := provider(ctx)
+			if err != nil {
+				return nil, err
+			}
+			options = append([]redis.DialOption{redis.DialUsername(username), redis.DialPassword(password)}, dialOptions...)
+		}
+		conn, err := redis.DialContext(ctx, network, host, options...)
+		if err != nil {
+			return nil, err
+		}
+		return newDialedConn(conn), nil
+	}
+}
+
+func (options ClientOptions) testOnBorrow() func(c redis.Conn, t time.Time) error {
+	interval := options.TestOnBorrowInterval
+	if interval < 0 {
+		return nil
+	}
+	return func(c redis.Conn, t time.Time) (err error) {
+		if time.Since(t) > interval {
+			_, err = c.Do("PING")
+		}
+		return err
+	}
+}
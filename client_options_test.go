@@ -0,0 +1,188 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClientWithOptions_Dial(t *testing.T) {
+	var mu sync.Mutex
+	var commands []string
+	s := newRespServer(t, func(args []string) interface{} {
+		mu.Lock()
+		commands = append(commands, strings.Join(args, " "))
+		mu.Unlock()
+		if args[0] == ADD_CMD {
+			return int64(1)
+		}
+		return respStatus("OK")
+	})
+	defer s.close()
+
+	tests := []struct {
+		name    string
+		options *ClientOptions
+		want    []string
+	}{
+		{"defaults", NewClientOptions(), []string{"TS.ADD key 1 1"}},
+		{"auth, database and name", NewClientOptions().SetAuthPass("secret").SetDatabase(2).SetClientName("collector"),
+			[]string{"AUTH secret", "CLIENT SETNAME collector", "SELECT 2", "TS.ADD key 1 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			commands = nil
+			mu.Unlock()
+			c := NewClientWithOptions(s.addr(), "test", *tt.options)
+			defer c.Pool.Close()
+			_, err := c.Add("key", 1, 1)
+			assert.Nil(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tt.want, commands)
+		})
+	}
+}
+
+func TestClientOptions_NewPool(t *testing.T) {
+	options := NewClientOptions().SetPoolLimits(10, 20, true).SetHostLimits("b:6379", 1, 2).
+		SetConnLifetime(time.Minute, time.Hour).SetTestOnBorrowInterval(-1)
+	tests := []struct {
+		host          string
+		wantMaxIdle   int
+		wantMaxActive int
+	}{
+		{"a:6379", 10, 20},
+		{"b:6379", 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			pool := options.newPool(tt.host)
+			assert.Equal(t, tt.wantMaxIdle, pool.MaxIdle)
+			assert.Equal(t, tt.wantMaxActive, pool.MaxActive)
+			assert.True(t, pool.Wait)
+			assert.Equal(t, time.Minute, pool.IdleTimeout)
+			assert.Equal(t, time.Hour, pool.MaxConnLifetime)
+			assert.Nil(t, pool.TestOnBorrow)
+		})
+	}
+
+	multi := NewMultiHostPoolWithOptions([]string{"b:6379"}, *options)
+	_, pool, _ := multi.pickPool(nil)
+	assert.Equal(t, 2, pool.MaxActive)
+	single := NewSingleHostPool("a:6379", nil)
+	assert.Equal(t, maxConns, single.MaxIdle)
+	assert.NotNil(t, single.TestOnBorrow)
+}
+
+func TestClientOptions_TestOnBorrow(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		idle     time.Duration
+		wantPing bool
+	}{
+		{"recently used", time.Minute, time.Second, false},
+		{"default, busy", DefaultClientOptions.TestOnBorrowInterval, time.Second, false},
+		{"idle for long", time.Minute, time.Hour, true},
+		{"always", 0, time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinged := false
+			conn := &stubConn{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+				pinged = cmd == "PING"
+				return "PONG", nil
+			}}
+			testOnBorrow := NewClientOptions().SetTestOnBorrowInterval(tt.interval).testOnBorrow()
+			assert.Nil(t, testOnBorrow(conn, time.Now().Add(-tt.idle)))
+			assert.Equal(t, tt.wantPing, pinged)
+		})
+	}
+}
+
+func TestNewClientWithOptions_TLS(t *testing.T) {
+	// borrow the self-signed certificate of an httptest server, valid for 127.0.0.1
+	httpServer := httptest.NewTLSServer(http.NotFoundHandler())
+	serverConfig := &tls.Config{Certificates: httpServer.TLS.Certificates}
+	roots := x509.NewCertPool()
+	roots.AddCert(httpServer.Certificate())
+	httpServer.Close()
+
+	s := newTLSRespServer(t, serverConfig, func(args []string) interface{} {
+		return int64(1)
+	})
+	defer s.close()
+
+	tests := []struct {
+		name    string
+		config  *tls.Config
+		wantErr bool
+	}{
+		{"trusted CA", &tls.Config{RootCAs: roots}, false},
+		{"unknown CA", &tls.Config{}, true},
+		{"server name mismatch", &tls.Config{RootCAs: roots, ServerName: "redis.example.org"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClientWithOptions(s.addr(), "test", *NewClientOptions().SetTLSConfig(tt.config))
+			defer c.Pool.Close()
+			_, err := c.Add("key", 1, 1)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestNewClientWithOptions_Credentials(t *testing.T) {
+	var mu sync.Mutex
+	var auths []string
+	s := newRespServer(t, func(args []string) interface{} {
+		if args[0] == "AUTH" {
+			mu.Lock()
+			auths = append(auths, strings.Join(args[1:], " "))
+			mu.Unlock()
+			return respStatus("OK")
+		}
+		return int64(1)
+	})
+	defer s.close()
+
+	c := NewClientWithOptions(s.addr(), "test", *NewClientOptions().SetACLAuth("collector", "secret"))
+	_, err := c.Add("key", 1, 1)
+	assert.Nil(t, err)
+	c.Pool.Close()
+
+	// the provider is invoked on each dial, so that the rotated password is used by the second connection
+	rotation := 0
+	provider := func(ctx context.Context) (string, string, error) {
+		rotation++
+		if rotation > 2 {
+			return "", "", errors.New("vault unavailable")
+		}
+		return "collector", fmt.Sprintf("secret-%d", rotation), nil
+	}
+	options := NewClientOptions().SetCredentialsProvider(provider).SetPoolLimits(0, 0, false)
+	c = NewClientWithOptions(s.addr(), "test", *options)
+	defer c.Pool.Close()
+	for i := 0; i < 2; i++ {
+		_, err = c.Add("key", 1, 1)
+		assert.Nil(t, err)
+	}
+	_, err = c.Add("key", 1, 1)
+	assert.EqualError(t, err, "vault unavailable")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"collector secret", "collector secret-1", "collector secret-2"}, auths)
+}
@@ -1,45 +1,28 @@
 package redis_timeseries_go
 
 import (
+	"errors"
 	"log"
+	"net"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/RedisTimeSeries/redistimeseries-go/tstest"
 	"github.com/gomodule/redigo/redis"
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
 )
 
-// getRedisTimeSeriesVersion returns RedisTimeSeries version by issuing "MODULE LIST" command
-// and iterating through the availabe modules up until "timeseries" is found as the name property
-func (c *Client) getRedisTimeSeriesVersion() (version int64, err error) {
-	conn := client.Pool.Get()
-	defer conn.Close()
-	var values []interface{}
-	var moduleInfo []interface{}
-	var moduleName string
-	values, err = redis.Values(conn.Do("MODULE", "LIST"))
-	if err != nil {
-		return
-	}
-	for _, rawModule := range values {
-		moduleInfo, err = redis.Values(rawModule, err)
-		if err != nil {
-			return
-		}
-		moduleName, err = redis.String(moduleInfo[1], err)
-		if err != nil {
-			return
-		}
-		if moduleName == "timeseries" {
-			version, err = redis.Int64(moduleInfo[3], err)
-		}
-	}
-	return
+var fakeServer struct {
+	once   sync.Once
+	server *tstest.Server
 }
 
+// getTestConnectionDetails returns the server given by REDISTIMESERIES_TEST_HOST, else localhost:6379 when it is
+// reachable, else an in-process fake server
 func getTestConnectionDetails() (string, string) {
 	value, exists := os.LookupEnv("REDISTIMESERIES_TEST_HOST")
 	host := "localhost:6379"
@@ -47,6 +30,14 @@ func getTestConnectionDetails() (string, string) {
 	valuePassword, existsPassword := os.LookupEnv("REDISTIMESERIES_TEST_PASSWORD")
 	if exists && value != "" {
 		host = value
+	} else if conn, err := net.DialTimeout("tcp", host, 100*time.Millisecond); err == nil {
+		conn.Close()
+	} else {
+		fakeServer.once.Do(func() {
+			fakeServer.server = tstest.NewServer()
+			fakeServer.server.SetModuleVersion(tstest.ModuleVersion16)
+		})
+		host = fakeServer.server.Addr()
 	}
 	if existsPassword && valuePassword != "" {
 		password = valuePassword
@@ -95,9 +86,9 @@ func TestCreateKey(t *testing.T) {
 	err = client.CreateKeyWithOptions("test_CreateKeyChunkSize", CreateOptions{ChunkSize: 1024})
 	assert.Nil(t, err)
 
-	version, err := client.getRedisTimeSeriesVersion()
+	capabilities, err := client.ServerCapabilities()
 	assert.Nil(t, err)
-	if version >= 14000 {
+	if capabilities.Supports(FeatureDuplicatePolicy) {
 		var datapoint *DataPoint
 		info, err := client.Info("test_CreateKeyChunkSize")
 		assert.Nil(t, err)
@@ -298,7 +289,8 @@ func TestDeleteRule(t *testing.T) {
 	info, _ := client.Info(key)
 	assert.Equal(t, 0, len(info.Rules))
 	err = client.DeleteRule(key, destKey)
-	assert.Equal(t, redis.Error("ERR TSDB: compaction rule does not exist"), err)
+	assert.True(t, errors.Is(err, ErrRuleNotFound))
+	assert.EqualError(t, err, "ERR TSDB: compaction rule does not exist")
 }
 
 func TestAdd(t *testing.T) {
@@ -327,9 +319,9 @@ func TestAdd(t *testing.T) {
 	storedTimestamp2, _ = client.AddAutoTsWithOptions(key, PI, CreateOptions{RetentionMSecs: defaultDuration})
 	assert.True(t, storedTimestamp1 < storedTimestamp2)
 
-	version, err := client.getRedisTimeSeriesVersion()
+	capabilities, err := client.ServerCapabilities()
 	assert.Nil(t, err)
-	if version >= 14000 {
+	if capabilities.Supports(FeatureDuplicatePolicy) {
 		var datapoint *DataPoint
 		_, err = client.AddWithOptions("TestAdd_BlockDuplicatePolicy", 1, 1.0, CreateOptions{DuplicatePolicy: BlockDuplicatePolicy})
 		assert.Nil(t, err)
@@ -0,0 +1,239 @@
+package redis_timeseries_go
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Port used for the hosts of a redis:// or rediss:// URL which do not specify one
+const defaultRedisPort = "6379"
+
+// NewClientFromURL creates a new client configured by a connection URL, and using the given name as key prefix.
+// Supported forms are redis://[[user]:password@]host[:port][,host[:port]...][/db][?option=value...],
+// rediss://... which is like redis:// over TLS, and unix://[[user]:password@]/path/to/socket[?db=N&option=value...]
+// Several hosts create a MultiHostPool, a single one a SingleHostPool.
+// The options are db, client_name, connect_timeout, read_timeout, write_timeout, idle_timeout, max_conn_lifetime and
+// test_on_borrow_interval (Go durations such as 500ms, or plain seconds), max_idle, max_active and wait,
+// and for rediss:// skip_verify, server_name, ca_file, cert_file and key_file.
+func NewClientFromURL(rawurl, name string) (*Client, error) {
+	hosts, options, err := parseClientURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	var pool ConnPool
+	if len(hosts) == 1 {
+		pool = NewSingleHostPoolWithOptions(hosts[0], options)
+	} else {
+		pool = NewMultiHostPoolWithOptions(hosts, options)
+	}
+	return &Client{
+		Pool: pool,
+		Name: name,
+	}, nil
+}
+
+// parseClientURL returns the hosts and the options described by a connection URL
+func parseClientURL(rawurl string) (hosts []string, options ClientOptions, err error) {
+	// url.Parse rejects host lists, so the hosts are parsed apart
+	withoutHosts, host := splitURLHosts(rawurl)
+	u, err := url.Parse(withoutHosts)
+	if err != nil {
+		return nil, options, fmt.Errorf("invalid redis URL: %v", err)
+	}
+	options = *NewClientOptions()
+	if u.User != nil {
+		options.Username = u.User.Username()
+		if password, found := u.User.Password(); found {
+			options.AuthPass = &password
+		}
+	}
+	query := u.Query()
+	switch u.Scheme {
+	case "redis", "rediss":
+		hosts, err = parseURLHosts(host)
+		if err != nil {
+			return nil, options, err
+		}
+		if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+			if query.Get("db") != "" {
+				return nil, options, fmt.Errorf("invalid redis URL: database given both in path and as db option")
+			}
+			query.Set("db", db)
+		}
+	case "unix":
+		if u.Host != "" || u.Path == "" {
+			return nil, options, fmt.Errorf("invalid unix URL: expected unix:///path/to/socket, got %s", rawurl)
+		}
+		hosts = []string{u.Path}
+		options.Network = "unix"
+	default:
+		return nil, options, fmt.Errorf("invalid redis URL scheme %q: expected redis, rediss or unix", u.Scheme)
+	}
+	if err = applyURLOptions(&options, query, u.Scheme == "rediss"); err != nil {
+		return nil, options, err
+	}
+	return hosts, options, nil
+}
+
+// splitURLHosts removes the hosts from the authority of a redis:// or rediss:// URL,
+// and returns the URL without them along with the hosts as given. Other URLs are returned unchanged.
+func splitURLHosts(rawurl string) (string, string) {
+	i := strings.Index(rawurl, "://")
+	if i < 0 {
+		return rawurl, ""
+	}
+	if scheme := strings.ToLower(rawurl[:i]); scheme != "redis" && scheme != "rediss" {
+		return rawurl, ""
+	}
+	start, end := i+len("://"), len(rawurl)
+	if n := strings.IndexAny(rawurl[start:], "/?#"); n >= 0 {
+		end = start + n
+	}
+	if at := strings.LastIndex(rawurl[start:end], "@"); at >= 0 {
+		start += at + 1
+	}
+	return rawurl[:start] + rawurl[end:], rawurl[start:end]
+}
+
+func parseURLHosts(host string) ([]string, error) {
+	if host == "" {
+		return nil, fmt.Errorf("invalid redis URL: missing host")
+	}
+	hosts := strings.Split(host, ",")
+	for i, h := range hosts {
+		hostname, port, err := net.SplitHostPort(h)
+		if err != nil {
+			// the port is optional
+			hostname, port = h, defaultRedisPort
+			if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
+				hostname = h[1 : len(h)-1]
+			} else if strings.ContainsAny(h, "[]") {
+				return nil, fmt.Errorf("invalid redis URL: invalid host %q", h)
+			}
+		}
+		if hostname == "" {
+			return nil, fmt.Errorf("invalid redis URL: empty host in %q", host)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return nil, fmt.Errorf("invalid redis URL: invalid port %q", port)
+		}
+		hosts[i] = net.JoinHostPort(hostname, port)
+	}
+	return hosts, nil
+}
+
+// applyURLOptions sets the options given as URL query parameters, rejecting the unknown ones
+func applyURLOptions(options *ClientOptions, query url.Values, useTLS bool) (err error) {
+	var tlsConfig *tls.Config
+	if useTLS {
+		tlsConfig = &tls.Config{}
+		options.TLSConfig = tlsConfig
+	}
+	var certFile, keyFile string
+	for name, values := range query {
+		value := values[len(values)-1]
+		switch name {
+		case "db":
+			options.Database, err = parseURLInt(name, value)
+		case "client_name":
+			options.ClientName = value
+		case "connect_timeout", "dial_timeout":
+			options.ConnectTimeout, err = parseURLDuration(name, value)
+		case "read_timeout":
+			options.ReadTimeout, err = parseURLDuration(name, value)
+		case "write_timeout":
+			options.WriteTimeout, err = parseURLDuration(name, value)
+		case "idle_timeout":
+			options.IdleTimeout, err = parseURLDuration(name, value)
+		case "max_conn_lifetime":
+			options.MaxConnLifetime, err = parseURLDuration(name, value)
+		case "test_on_borrow_interval":
+			options.TestOnBorrowInterval, err = parseURLDuration(name, value)
+		case "max_idle":
+			options.MaxIdle, err = parseURLInt(name, value)
+		case "max_active":
+			options.MaxActive, err = parseURLInt(name, value)
+		case "wait":
+			options.Wait, err = parseURLBool(name, value)
+		case "skip_verify", "server_name", "ca_file", "cert_file", "key_file":
+			if tlsConfig == nil {
+				return fmt.Errorf("invalid redis URL: option %s requires the rediss scheme", name)
+			}
+			switch name {
+			case "skip_verify":
+				tlsConfig.InsecureSkipVerify, err = parseURLBool(name, value)
+			case "server_name":
+				tlsConfig.ServerName = value
+			case "ca_file":
+				tlsConfig.RootCAs, err = loadCertPool(value)
+			case "cert_file":
+				certFile = value
+			case "key_file":
+				keyFile = value
+			}
+		default:
+			return fmt.Errorf("invalid redis URL: unknown option %s", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return fmt.Errorf("invalid redis URL: cert_file and key_file must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("invalid redis URL: cert_file and key_file: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return nil
+}
+
+func parseURLInt(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid redis URL: option %s expects a positive integer, got %q", name, value)
+	}
+	return n, nil
+}
+
+func parseURLBool(name, value string) (bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid redis URL: option %s expects a boolean, got %q", name, value)
+	}
+	return b, nil
+}
+
+// parseURLDuration accepts Go durations, as well as plain numbers of seconds
+func parseURLDuration(name, value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid redis URL: option %s expects a duration, got %q", name, value)
+	}
+	return d, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: ca_file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("invalid redis URL: no certificate found in ca_file %s", caFile)
+	}
+	return pool, nil
+}
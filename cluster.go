@@ -0,0 +1,387 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Number of hash slots of a Redis Cluster
+const ClusterSlots = 16384
+
+// maxClusterRedirects bounds the MOVED/ASK redirections followed for a single command
+const maxClusterRedirects = 5
+
+// clusterSlotsTimeout bounds the loading of the slot map when no context is given
+const clusterSlotsTimeout = 10 * time.Second
+
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return
+}()
+
+// crc16 computes the CRC16-CCITT (XMODEM) checksum used by Redis Cluster
+func crc16(data string) (crc uint16) {
+	for i := 0; i < len(data); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^data[i]]
+	}
+	return
+}
+
+// ClusterSlot returns the hash slot of key. When key contains a non-empty hash tag within curly braces,
+// only the hash tag is hashed, so that keys sharing it live in the same slot.
+func ClusterSlot(key string) int {
+	return int(crc16(hashTag(key))) % ClusterSlots
+}
+
+// hashTag returns the non-empty hash tag of key within curly braces, or key itself when it has none
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+// ClusterPool is a ShardedConnPool for Redis Cluster.
+// It loads the slot map with CLUSTER SLOTS, routes keyed commands to the master owning the key hash slot,
+// and follows MOVED and ASK redirections issued by the cluster.
+type ClusterPool struct {
+	sync.RWMutex
+	seeds     []string
+	authPass  *string
+	pools     map[string]*redis.Pool
+	slots     []string
+	reloading int32
+}
+
+// NewClusterPool creates a ClusterPool discovering the cluster topology from the given seed nodes
+func NewClusterPool(seeds []string, authPass *string) *ClusterPool {
+	return &ClusterPool{
+		seeds:    seeds,
+		authPass: authPass,
+		pools:    make(map[string]*redis.Pool, len(seeds)),
+	}
+}
+
+// NewClusterClient creates a new client connecting to a Redis Cluster, using the given name as key prefix.
+// Addr is a comma separated list of host:port seed nodes used to discover the cluster topology.
+func NewClusterClient(addr, name string, authPass *string) *Client {
+	return &Client{
+		Pool: NewClusterPool(strings.Split(addr, ","), authPass),
+		Name: name,
+	}
+}
+
+// ReloadSlots refreshes the slot map, querying CLUSTER SLOTS on the known masters and then on the seed nodes until one answers
+func (p *ClusterPool) ReloadSlots(ctx context.Context) (err error) {
+	p.RLock()
+	candidates := uniqueSorted(p.slots)
+	p.RUnlock()
+	candidates = append(candidates, p.seeds...)
+	err = errors.New("no cluster node available")
+	for _, addr := range candidates {
+		if addr == "" {
+			continue
+		}
+		var slots []string
+		slots, err = p.loadSlots(ctx, addr)
+		if err == nil {
+			p.Lock()
+			p.slots = slots
+			p.Unlock()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("failed loading cluster slots: %v", err)
+}
+
+func (p *ClusterPool) loadSlots(ctx context.Context, addr string) ([]string, error) {
+	conn, err := getPooled(ctx, p.nodePool(addr))
+	if err != nil {
+		return nil, err
+	}
+	values, err := redis.Values(doContext(ctx, conn, "CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	slots := make([]string, ClusterSlots)
+	for _, value := range values {
+		slotRange, err := redis.Values(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(slotRange) < 3 {
+			return nil, fmt.Errorf("CLUSTER SLOTS: expects at least 3 elements per slot range, got %d", len(slotRange))
+		}
+		start, err := redis.Int(slotRange[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		end, err := redis.Int(slotRange[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		master, err := parseClusterNode(slotRange[2], host)
+		if err != nil {
+			return nil, err
+		}
+		if start < 0 || end >= ClusterSlots || start > end {
+			return nil, fmt.Errorf("CLUSTER SLOTS: invalid slot range %d-%d", start, end)
+		}
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = master
+		}
+	}
+	return slots, nil
+}
+
+// parseClusterNode returns the host:port address of a CLUSTER SLOTS node entry.
+// An empty host means the node is reachable on the same host as the node which answered.
+func parseClusterNode(node interface{}, defaultHost string) (string, error) {
+	values, err := redis.Values(node, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(values) < 2 {
+		return "", fmt.Errorf("CLUSTER SLOTS: expects at least host and port per node, got %d elements", len(values))
+	}
+	host, err := redis.String(values[0], nil)
+	if err != nil {
+		return "", err
+	}
+	port, err := redis.Int(values[1], nil)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = defaultHost
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// ensureSlots loads the slot map on first use
+func (p *ClusterPool) ensureSlots(ctx context.Context) error {
+	p.RLock()
+	loaded := p.slots != nil
+	p.RUnlock()
+	if loaded {
+		return nil
+	}
+	return p.ReloadSlots(ctx)
+}
+
+// reloadSlotsAsync refreshes the slot map in the background, unless a refresh is already running
+func (p *ClusterPool) reloadSlotsAsync() {
+	if !atomic.CompareAndSwapInt32(&p.reloading, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&p.reloading, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), clusterSlotsTimeout)
+		defer cancel()
+		p.ReloadSlots(ctx) //nolint:errcheck
+	}()
+}
+
+func (p *ClusterPool) nodePool(addr string) *redis.Pool {
+	p.Lock()
+	defer p.Unlock()
+	pool, found := p.pools[addr]
+	if !found {
+		pool = withAuthPass(p.authPass).newPool(addr)
+		p.pools[addr] = pool
+	}
+	return pool
+}
+
+// NodeForKey returns the address of the master owning the hash slot of key,
+// loading the slot map within clusterSlotsTimeout on first use
+func (p *ClusterPool) NodeForKey(key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterSlotsTimeout)
+	defer cancel()
+	return p.NodeForKeyContext(ctx, key)
+}
+
+// NodeForKeyContext is like NodeForKey, honoring the deadline and cancellation of ctx while loading the slot map
+func (p *ClusterPool) NodeForKeyContext(ctx context.Context, key string) (string, error) {
+	if err := p.ensureSlots(ctx); err != nil {
+		return "", err
+	}
+	slot := ClusterSlot(key)
+	p.RLock()
+	node := p.slots[slot]
+	p.RUnlock()
+	if node == "" {
+		return "", fmt.Errorf("no cluster node serves slot %d", slot)
+	}
+	return node, nil
+}
+
+// Nodes returns the addresses of all the masters serving slots, loading the slot map within clusterSlotsTimeout on first use
+func (p *ClusterPool) Nodes() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterSlotsTimeout)
+	defer cancel()
+	return p.NodesContext(ctx)
+}
+
+// NodesContext is like Nodes, honoring the deadline and cancellation of ctx while loading the slot map
+func (p *ClusterPool) NodesContext(ctx context.Context) ([]string, error) {
+	if err := p.ensureSlots(ctx); err != nil {
+		return nil, err
+	}
+	p.RLock()
+	defer p.RUnlock()
+	return uniqueSorted(p.slots), nil
+}
+
+// GetNodeContext gets a connection to the given node, which follows MOVED and ASK redirections on Do
+func (p *ClusterPool) GetNodeContext(ctx context.Context, node string) (redis.Conn, error) {
+	conn, err := getPooled(ctx, p.nodePool(node))
+	if err != nil {
+		return nil, err
+	}
+	return &clusterConn{Conn: conn, pool: p}, nil
+}
+
+// Get gets a connection to a random master
+func (p *ClusterPool) Get() redis.Conn {
+	conn, err := p.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return conn
+}
+
+// GetContext gets a connection to a random master
+func (p *ClusterPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	nodes, err := p.NodesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("no cluster node serves any slot")
+	}
+	return p.GetNodeContext(ctx, nodes[rand.Intn(len(nodes))])
+}
+
+func (p *ClusterPool) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	return closePools(p.pools)
+}
+
+// clusterConn follows the MOVED and ASK redirections replied to Do and DoWithTimeout.
+// Pipelined commands issued with Send and Receive are not redirected.
+type clusterConn struct {
+	redis.Conn
+	pool *ClusterPool
+}
+
+func (c *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(cmd, args...)
+	return c.followRedirects(reply, err, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do(cmd, args...)
+	})
+}
+
+func (c *clusterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+	return c.followRedirects(reply, err, func(conn redis.Conn) (interface{}, error) {
+		return redis.DoWithTimeout(conn, timeout, cmd, args...)
+	})
+}
+
+func (c *clusterConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func (c *clusterConn) abort() {
+	abortConn(c.Conn)
+}
+
+func (c *clusterConn) followRedirects(reply interface{}, err error, do func(conn redis.Conn) (interface{}, error)) (interface{}, error) {
+	for i := 0; i < maxClusterRedirects; i++ {
+		ask, slot, addr, ok := parseRedirect(err)
+		if !ok {
+			break
+		}
+		if !ask {
+			c.pool.Lock()
+			if c.pool.slots != nil {
+				c.pool.slots[slot] = addr
+			}
+			c.pool.Unlock()
+			c.pool.reloadSlotsAsync()
+		}
+		reply, err = c.redirect(addr, ask, do)
+	}
+	return reply, err
+}
+
+func (c *clusterConn) redirect(addr string, ask bool, do func(conn redis.Conn) (interface{}, error)) (interface{}, error) {
+	conn := c.pool.nodePool(addr).Get()
+	defer conn.Close()
+	if ask {
+		if _, err := conn.Do("ASKING"); err != nil {
+			return nil, err
+		}
+	}
+	return do(conn)
+}
+
+// parseRedirect extracts the redirection from a "MOVED <slot> <addr>" or "ASK <slot> <addr>" error reply
+func parseRedirect(err error) (ask bool, slot int, addr string, ok bool) {
+	rerr, isRedisErr := err.(redis.Error)
+	if !isRedisErr {
+		return
+	}
+	fields := strings.Fields(string(rerr))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return
+	}
+	slot, convErr := strconv.Atoi(fields[1])
+	if convErr != nil || slot < 0 || slot >= ClusterSlots {
+		return
+	}
+	return fields[0] == "ASK", slot, fields[2], true
+}
+
+// uniqueSorted returns the distinct non-empty values in ascending order
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0)
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,254 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterSlot(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"check value", "123456789", 12739},
+		{"plain key", "foo", 12182},
+		{"hash tag", "{foo}:temperature", 12182},
+		{"empty hash tag", "{}foo", ClusterSlot("{}foo")},
+		{"unterminated hash tag", "{foo", ClusterSlot("{foo")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ClusterSlot(tt.key))
+		})
+	}
+	assert.NotEqual(t, ClusterSlot("foo"), ClusterSlot("{}foo"))
+}
+
+// fakeClusterNode records the keys it received, and answers as the owner of the slots in [first, last]
+type fakeClusterNode struct {
+	*respServer
+	mu   sync.Mutex
+	keys []string
+}
+
+func (n *fakeClusterNode) received() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string{}, n.keys...)
+}
+
+func newFakeCluster(t *testing.T) (a, b *fakeClusterNode) {
+	a, b = &fakeClusterNode{}, &fakeClusterNode{}
+	slots := func() interface{} {
+		return []interface{}{
+			[]interface{}{int64(0), int64(8191), []interface{}{a.host(), a.port(), "a"}},
+			[]interface{}{int64(8192), int64(16383), []interface{}{"", b.port(), "b"}},
+		}
+	}
+	handler := func(node *fakeClusterNode, first, last int, other func() string) func(args []string) interface{} {
+		return func(args []string) interface{} {
+			switch strings.ToUpper(args[0]) {
+			case "CLUSTER":
+				return slots()
+			case "PING":
+				return respStatus("PONG")
+			case MADD_CMD:
+				replies := []interface{}{}
+				for i := 1; i < len(args); i += 3 {
+					node.mu.Lock()
+					node.keys = append(node.keys, args[i])
+					node.mu.Unlock()
+					replies = append(replies, args[i+1])
+				}
+				return replies
+			case QUERYINDEX_CMD:
+				return []interface{}{args[0] + "@" + node.addr()}
+			case MRANGE_CMD:
+				return []interface{}{
+					[]interface{}{"region=eu",
+						[]interface{}{[]interface{}{"region", "eu"}, []interface{}{"__reducer__", "sum"}, []interface{}{"__source__", node.addr()}},
+						[]interface{}{[]interface{}{int64(1), "1"}, []interface{}{int64(first + 2), "2"}}},
+				}
+			}
+			key := args[1]
+			slot := ClusterSlot(key)
+			// "moved" is owned by a according to CLUSTER SLOTS, but was migrated to b
+			if key == "moved" && node == a {
+				return redis.Error(fmt.Sprintf("MOVED %d %s", slot, other()))
+			}
+			if key != "moved" && (slot < first || slot > last) {
+				return redis.Error(fmt.Sprintf("MOVED %d %s", slot, other()))
+			}
+			node.mu.Lock()
+			node.keys = append(node.keys, key)
+			node.mu.Unlock()
+			return int64(slot)
+		}
+	}
+	a.respServer = newRespServer(t, handler(a, 0, 8191, func() string { return b.addr() }))
+	b.respServer = newRespServer(t, handler(b, 8192, 16383, func() string { return a.addr() }))
+	return
+}
+
+func TestClusterPool(t *testing.T) {
+	a, b := newFakeCluster(t)
+	defer a.close()
+	defer b.close()
+	pool := NewClusterPool([]string{a.addr()}, nil)
+	defer pool.Close()
+
+	nodes, err := pool.Nodes()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{a.addr(), b.addr()}, nodes)
+	node, err := pool.NodeForKey("123456789")
+	assert.Nil(t, err)
+	assert.Equal(t, b.addr(), node)
+	node, err = pool.NodeForKey("bar")
+	assert.Nil(t, err)
+	assert.Equal(t, a.addr(), node)
+
+	c := &Client{Pool: pool, Name: "test"}
+	// keyed commands are routed to the owner of the key slot
+	ts, err := c.Add("123456789", 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12739), ts)
+	assert.Equal(t, []string{"123456789"}, b.received())
+	// MOVED redirections are followed
+	ts, err = c.Add("moved", 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(ClusterSlot("moved")), ts)
+	assert.Equal(t, []string{"123456789", "moved"}, b.received())
+}
+
+func TestClusterPool_SlotsContext(t *testing.T) {
+	release := make(chan struct{})
+	s := newRespServer(t, func(args []string) interface{} {
+		<-release
+		return []interface{}{}
+	})
+	defer s.close()
+	defer close(release)
+	pool := NewClusterPool([]string{s.addr()}, nil)
+	defer pool.Close()
+
+	// the seed never answers CLUSTER SLOTS, so that the discovery of the nodes ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := pool.NodesContext(ctx)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < time.Second, "bounded by the context")
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.NodeForKeyContext(ctx, "key")
+	assert.Equal(t, context.Canceled, err)
+	_, err = (&Client{Pool: pool, Name: "test"}).GetCtx(ctx, "key")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestClusterPool_MultiKeyCommands(t *testing.T) {
+	a, b := newFakeCluster(t)
+	defer a.close()
+	defer b.close()
+	pool := NewClusterPool([]string{b.addr()}, nil)
+	defer pool.Close()
+	c := &Client{Pool: pool, Name: "test"}
+
+	// TS.MADD is split per node, and the replies are realigned with the samples
+	samples := []Sample{
+		{Key: "123456789", DataPoint: DataPoint{Timestamp: 1, Value: 1}},
+		{Key: "bar", DataPoint: DataPoint{Timestamp: 2, Value: 1}},
+		{Key: "foo", DataPoint: DataPoint{Timestamp: 3, Value: 1}},
+	}
+	timestamps, err := c.MultiAdd(samples...)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{[]byte("1"), []byte("2"), []byte("3")}, timestamps)
+	assert.Equal(t, []string{"bar"}, a.received())
+	assert.Equal(t, []string{"123456789", "foo"}, b.received())
+
+	// TS.QUERYINDEX is fanned out to every master
+	keys, err := c.QueryIndex("a=1")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{QUERYINDEX_CMD + "@" + a.addr(), QUERYINDEX_CMD + "@" + b.addr()}, keys)
+
+	// groups split across nodes are reduced again
+	ranges, err := c.MultiRangeWithOptions(0, 100, *NewMultiRangeOptions().SetGroupByReduce("region", SumReducer), "region!=")
+	assert.Nil(t, err)
+	assert.Len(t, ranges, 1)
+	assert.Equal(t, "region=eu", ranges[0].Name)
+	assert.Equal(t, []DataPoint{{1, 2}, {2, 2}, {8194, 2}}, ranges[0].DataPoints)
+	assert.Equal(t, "sum", ranges[0].Labels["__reducer__"])
+}
+
+func TestRegroupRanges(t *testing.T) {
+	ranges := []Range{
+		{"a=1", map[string]string{"a": "1", "__source__": "s1"}, []DataPoint{{1, 1}, {2, 5}}},
+		{"a=2", map[string]string{"a": "2", "__source__": "s2"}, []DataPoint{{1, 3}}},
+		{"a=1", map[string]string{"a": "1", "__source__": "s3"}, []DataPoint{{1, 4}, {3, 2}}},
+	}
+	tests := []struct {
+		name    string
+		reducer ReducerType
+		reverse bool
+		count   int64
+		want    []DataPoint
+		wantErr bool
+	}{
+		{"sum", SumReducer, false, -1, []DataPoint{{1, 5}, {2, 5}, {3, 2}}, false},
+		{"min", MinReducer, false, -1, []DataPoint{{1, 1}, {2, 5}, {3, 2}}, false},
+		{"max reversed", MaxReducer, true, -1, []DataPoint{{3, 2}, {2, 5}, {1, 4}}, false},
+		{"count", SumReducer, false, 2, []DataPoint{{1, 5}, {2, 5}}, false},
+		{"unsupported", "AVG", false, -1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := regroupRanges(ranges, tt.reducer, tt.reverse, tt.count)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("regroupRanges() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Len(t, got, 2)
+			assert.Equal(t, tt.want, got[0].DataPoints)
+			assert.Equal(t, "s1,s3", got[0].Labels["__source__"])
+		})
+	}
+}
+
+func TestClusterPool_Pipeline(t *testing.T) {
+	a, b := newFakeCluster(t)
+	defer a.close()
+	defer b.close()
+	pool := NewClusterPool([]string{a.addr()}, nil)
+	defer pool.Close()
+	c := &Client{Pool: pool, Name: "test"}
+
+	p := c.Pipeline()
+	p.Add("bar", 1, 1)
+	p.Add("foo", 1, 1)
+	p.QueryIndex("a=1")
+	results, err := p.Exec()
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	ts, err := results[0].Int64()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(ClusterSlot("bar")), ts)
+	ts, err = results[1].Int64()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(ClusterSlot("foo")), ts)
+	keys, err := results[2].Strings()
+	assert.Nil(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, []string{"bar"}, a.received())
+	assert.Equal(t, []string{"foo"}, b.received())
+}
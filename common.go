@@ -3,6 +3,7 @@ package redis_timeseries_go
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -27,6 +28,7 @@ const (
 	SumAggregation   AggregationType = "SUM"
 	MinAggregation   AggregationType = "MIN"
 	MaxAggregation   AggregationType = "MAX"
+	RangeAggregation AggregationType = "RANGE"
 	CountAggregation AggregationType = "COUNT"
 	FirstAggregation AggregationType = "FIRST"
 	LastAggregation  AggregationType = "LAST"
@@ -90,6 +92,19 @@ var DefaultCreateOptions = CreateOptions{
 type Client struct {
 	Pool ConnPool
 	Name string
+	// Namespace, when set, prefixes every key with Name, see NamespaceOptions
+	Namespace *NamespaceOptions
+	// RetryPolicy, when set, retries the idempotent commands failing with a transient error
+	RetryPolicy *RetryPolicy
+	// Hooks are invoked around every command, in order before it and in reverse order after it
+	Hooks []Hook
+	// CheckCapabilities, when set, fails the commands using an option the server does not support with an
+	// *UnsupportedByServerError before sending them, the capabilities being detected once with MODULE LIST
+	CheckCapabilities bool
+	// EmulateUnsupported, when set, applies client side the FILTER_BY_TS, FILTER_BY_VALUE, ALIGN and GROUPBY/REDUCE
+	// options of the range queries the server does not support, fetching the raw or aggregated samples instead
+	EmulateUnsupported bool
+	capabilities       capabilitiesCache
 }
 
 const TimeRangeMinimum = 0
@@ -166,8 +181,14 @@ func (options *CreateOptions) Serialize(args []interface{}) (result []interface{
 	}
 	if len(options.Labels) > 0 {
 		result = append(result, "LABELS")
-		for key, value := range options.Labels {
-			result = append(result, key, value)
+		// the labels are sorted by name so that the same options are always serialized the same way
+		keys := make([]string, 0, len(options.Labels))
+		for key := range options.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = append(result, key, options.Labels[key])
 		}
 	}
 	return
@@ -0,0 +1,222 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// runWithContext runs fn against conn and closes the connection once fn returns.
+// If ctx is done before fn completes, ctx.Err() is returned straight away and the connection is aborted,
+// so that the in-flight command fails and the connection is discarded rather than returned to its pool.
+// The caller must not use conn after calling runWithContext.
+func runWithContext(ctx context.Context, conn redis.Conn, fn func(conn redis.Conn) error) error {
+	if ctx.Done() == nil {
+		defer conn.Close()
+		return fn(conn)
+	}
+	if err := ctx.Err(); err != nil {
+		conn.Close()
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer conn.Close()
+		done <- fn(conn)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		abortConn(conn)
+		return ctx.Err()
+	}
+}
+
+// abortable is implemented by the connections which can be closed while a command is in flight
+type abortable interface {
+	abort()
+}
+
+// abortConn closes the network connection of conn, if it can be reached, making the in-flight command fail.
+// The wrapping connections forward abort to the connection they wrap.
+func abortConn(conn redis.Conn) {
+	if conn, ok := conn.(abortable); ok {
+		conn.abort()
+	}
+}
+
+// dialedConns maps the address of each open dialedConn to itself, so that it can be found behind the connection
+// a redis.Pool lends, which does not expose it
+var dialedConns sync.Map
+
+// dialedConn is a connection dialed for a pool built from ClientOptions
+type dialedConn struct {
+	redis.Conn
+}
+
+// newDialedConn wraps a dialed connection, registering it in dialedConns until it is closed
+func newDialedConn(conn redis.Conn) *dialedConn {
+	c := &dialedConn{Conn: conn}
+	dialedConns.Store(reflect.ValueOf(c).Pointer(), c)
+	return c
+}
+
+func (c *dialedConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+}
+
+func (c *dialedConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func (c *dialedConn) Close() error {
+	dialedConns.Delete(reflect.ValueOf(c).Pointer())
+	return c.Conn.Close()
+}
+
+// abort closes the connection, which is safe while a command is in flight. The connection then reports an error,
+// so that redis.Pool discards it when it is returned.
+func (c *dialedConn) abort() {
+	c.Conn.Close()
+}
+
+// pooledConn is a connection lent by a redis.Pool, which can be aborted through the connection the pool dialed
+type pooledConn struct {
+	redis.Conn
+	dialed *dialedConn
+}
+
+func (c *pooledConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+}
+
+func (c *pooledConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func (c *pooledConn) abort() {
+	c.dialed.abort()
+}
+
+// getPooled borrows a connection from a pool built from ClientOptions, which can be aborted
+func getPooled(ctx context.Context, pool *redis.Pool) (redis.Conn, error) {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dialed, found := dialedConns.Load(pooledConnAddr(conn)); found {
+		return &pooledConn{Conn: conn, dialed: dialed.(*dialedConn)}, nil
+	}
+	return conn, nil
+}
+
+// pooledConnAddr returns the address of the connection a redis.Pool dialed, behind the one it lends.
+// It is read from the unexported fields of the lent connection, and is 0 when they are not the expected ones,
+// the connection then not being abortable.
+func pooledConnAddr(conn redis.Conn) uintptr {
+	v := reflect.ValueOf(conn)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return 0
+	}
+	pc := v.Elem().FieldByName("pc")
+	if pc.Kind() != reflect.Ptr || pc.IsNil() || pc.Elem().Kind() != reflect.Struct {
+		return 0
+	}
+	c := pc.Elem().FieldByName("c")
+	if c.Kind() != reflect.Interface || c.IsNil() || c.Elem().Kind() != reflect.Ptr {
+		return 0
+	}
+	return c.Elem().Pointer()
+}
+
+// abortablePool lends the connections of a pool built from ClientOptions through getPooled
+type abortablePool struct {
+	*redis.Pool
+}
+
+func (p abortablePool) Get() redis.Conn {
+	conn, err := p.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return conn
+}
+
+func (p abortablePool) GetContext(ctx context.Context) (redis.Conn, error) {
+	return getPooled(ctx, p.Pool)
+}
+
+// doWithDeadline issues the command using the remaining time until the ctx deadline as read timeout,
+// whenever both the context and the connection allow it
+func doWithDeadline(ctx context.Context, conn redis.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if cwt, ok := conn.(redis.ConnWithTimeout); ok {
+			return cwt.DoWithTimeout(time.Until(deadline), cmd, args...)
+		}
+	}
+	return conn.Do(cmd, args...)
+}
+
+// receiveWithDeadline is the Receive counterpart of doWithDeadline
+func receiveWithDeadline(ctx context.Context, conn redis.Conn) (interface{}, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if cwt, ok := conn.(redis.ConnWithTimeout); ok {
+			return cwt.ReceiveWithTimeout(time.Until(deadline))
+		}
+	}
+	return conn.Receive()
+}
+
+// doContext issues a single command on conn honoring ctx, and closes the connection afterwards
+func doContext(ctx context.Context, conn redis.Conn, cmd string, args ...interface{}) (interface{}, error) {
+	var reply interface{}
+	err := runWithContext(ctx, conn, func(conn redis.Conn) (err error) {
+		reply, err = doWithDeadline(ctx, conn, cmd, args...)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// getContext borrows a connection from the pool, favouring ctx.Err() over the pool error
+// when the failure was caused by the context
+func getContext(ctx context.Context, pool ConnPool) (redis.Conn, error) {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// getNodeContext is the ShardedConnPool counterpart of getContext
+func getNodeContext(ctx context.Context, pool ShardedConnPool, node string) (redis.Conn, error) {
+	conn, err := pool.GetNodeContext(ctx, node)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// getReadOnlyContext is the ReadOnlyConnPool counterpart of getContext
+func getReadOnlyContext(ctx context.Context, pool ReadOnlyConnPool) (redis.Conn, error) {
+	conn, err := pool.GetReadOnlyContext(ctx)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,198 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_AddCtx(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	blocking := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		<-release
+		return int64(1), nil
+	}}
+	immediate := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return int64(1), nil
+	}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelExpired()
+
+	tests := []struct {
+		name    string
+		pool    ConnPool
+		ctx     context.Context
+		want    int64
+		wantErr error
+	}{
+		{"background", immediate, context.Background(), 1, nil},
+		{"cancelled before borrowing", immediate, cancelled, 0, context.Canceled},
+		{"deadline while waiting for reply", blocking, expired, 0, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Pool: tt.pool, Name: "test"}
+			got, err := c.AddCtx(tt.ctx, "key", 1, 1)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_QueryCtx(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	reply := func(cmd string) interface{} {
+		switch cmd {
+		case RANGE_CMD:
+			return []interface{}{[]interface{}{int64(1), []byte("1.5")}}
+		case MRANGE_CMD:
+			return []interface{}{[]interface{}{[]byte("key"), []interface{}{}, []interface{}{[]interface{}{int64(1), []byte("1.5")}}}}
+		}
+		return int64(1)
+	}
+	blocking := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		<-release
+		return reply(cmd), nil
+	}}
+	immediate := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return reply(cmd), nil
+	}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelExpired()
+
+	queries := []struct {
+		name  string
+		query func(c *Client, ctx context.Context) error
+	}{
+		{"range", func(c *Client, ctx context.Context) error {
+			dataPoints, err := c.RangeWithOptionsCtx(ctx, "key", 0, 10, DefaultRangeOptions)
+			if err == nil {
+				assert.Equal(t, []DataPoint{{1, 1.5}}, dataPoints)
+			}
+			return err
+		}},
+		{"multi range", func(c *Client, ctx context.Context) error {
+			ranges, err := c.MultiRangeWithOptionsCtx(ctx, 0, 10, DefaultMultiRangeOptions, "a=1")
+			if err == nil {
+				assert.Equal(t, []Range{{Name: "key", Labels: map[string]string{}, DataPoints: []DataPoint{{1, 1.5}}}}, ranges)
+			}
+			return err
+		}},
+		{"pipeline", func(c *Client, ctx context.Context) error {
+			p := c.Pipeline()
+			p.Add("key", 1, 1)
+			p.RangeWithOptions("key", 0, 10, DefaultRangeOptions)
+			results, err := p.ExecCtx(ctx)
+			if err == nil {
+				assert.Len(t, results, 2)
+			}
+			return err
+		}},
+	}
+	tests := []struct {
+		name    string
+		pool    ConnPool
+		ctx     context.Context
+		wantErr error
+	}{
+		{"background", immediate, context.Background(), nil},
+		{"cancelled before borrowing", immediate, cancelled, context.Canceled},
+		{"deadline while waiting for reply", blocking, expired, context.DeadlineExceeded},
+	}
+	for _, q := range queries {
+		for _, tt := range tests {
+			t.Run(q.name+"/"+tt.name, func(t *testing.T) {
+				c := &Client{Pool: tt.pool, Name: "test"}
+				assert.Equal(t, tt.wantErr, q.query(c, tt.ctx))
+			})
+		}
+	}
+}
+
+func TestClient_QueryCtx_Discard(t *testing.T) {
+	received, release := make(chan struct{}, 1), make(chan struct{})
+	s := newRespServer(t, func(args []string) interface{} {
+		received <- struct{}{}
+		<-release
+		return []interface{}{}
+	})
+	defer s.close()
+	defer close(release)
+	pool := NewSingleHostPoolWithOptions(s.addr(), *NewClientOptions().SetTestOnBorrowInterval(0))
+	defer pool.Close()
+	c := NewClientFromConnPool(pool, "test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		cancel()
+	}()
+	_, err := c.RangeWithOptionsCtx(ctx, "key", 0, 10, DefaultRangeOptions)
+	assert.Equal(t, context.Canceled, err)
+	// the connection with a pending reply is closed, rather than returned to the pool
+	waitFor(t, func() bool { return pool.Stats().ActiveCount == 0 })
+	assert.Equal(t, 0, pool.Stats().IdleCount)
+}
+
+func TestGetPooled(t *testing.T) {
+	var commands []string
+	var mu sync.Mutex
+	s := newRespServer(t, func(args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		commands = append(commands, args[0])
+		return respStatus("PONG")
+	})
+	defer s.close()
+	pool := NewSingleHostPoolWithOptions(s.addr(), *NewClientOptions().SetTestOnBorrowInterval(-1))
+	defer pool.Close()
+
+	// both the dialed and the idle connections are found behind the ones the pool lends, without any round trip
+	var addr uintptr
+	for _, borrow := range []string{"dialed", "idle"} {
+		conn, err := getPooled(context.Background(), pool.Pool)
+		assert.Nil(t, err)
+		pooled, ok := conn.(*pooledConn)
+		assert.True(t, ok, borrow)
+		if ok {
+			addr = reflect.ValueOf(pooled.dialed).Pointer()
+			_, found := dialedConns.Load(addr)
+			assert.True(t, found, borrow)
+		}
+		conn.Close()
+	}
+	mu.Lock()
+	assert.Empty(t, commands)
+	mu.Unlock()
+	assert.Equal(t, uintptr(0), pooledConnAddr(&stubConn{}))
+
+	// a closed connection is no longer registered
+	pool.Close()
+	_, found := dialedConns.Load(addr)
+	assert.False(t, found)
+}
+
+func TestRunWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	conn := &stubConn{}
+	go cancel()
+	err := runWithContext(ctx, conn, func(conn redis.Conn) error {
+		<-release
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	close(release)
+	assert.Eventually(t, func() bool { return conn.isClosed() }, time.Second, time.Millisecond)
+}
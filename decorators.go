@@ -0,0 +1,589 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrReadOnly is returned by a ReadOnlyClient for the operations writing to the database
+var ErrReadOnly = errors.New("write operation refused by a read-only client")
+
+// Logger is the logger of a LoggingClient, satisfied by *log.Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// backgroundForms implements the forms of the operations of a TimeSeriesClient without a context, running the forms
+// honoring a context of ctxForms with context.Background(). The decorators embed it, and implement the latter ones,
+// so that an operation added to TimeSeriesClient does not compile until each decorator handles it.
+type backgroundForms struct {
+	ctxForms TimeSeriesClient
+}
+
+func (f backgroundForms) CreateKey(key string, retentionTime time.Duration) error {
+	options := DefaultCreateOptions
+	options.RetentionMSecs = retentionTime
+	return f.ctxForms.CreateKeyWithOptionsCtx(context.Background(), key, options)
+}
+
+func (f backgroundForms) CreateKeyWithOptions(key string, options CreateOptions) error {
+	return f.ctxForms.CreateKeyWithOptionsCtx(context.Background(), key, options)
+}
+
+func (f backgroundForms) AlterKeyWithOptions(key string, options CreateOptions) error {
+	return f.ctxForms.AlterKeyWithOptionsCtx(context.Background(), key, options)
+}
+
+func (f backgroundForms) Add(key string, timestamp int64, value float64) (int64, error) {
+	return f.ctxForms.AddCtx(context.Background(), key, timestamp, value)
+}
+
+func (f backgroundForms) AddAutoTs(key string, value float64) (int64, error) {
+	return f.ctxForms.AddAutoTsCtx(context.Background(), key, value)
+}
+
+func (f backgroundForms) AddWithOptions(key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return f.ctxForms.AddWithOptionsCtx(context.Background(), key, timestamp, value, options)
+}
+
+func (f backgroundForms) AddAutoTsWithOptions(key string, value float64, options CreateOptions) (int64, error) {
+	return f.ctxForms.AddAutoTsWithOptionsCtx(context.Background(), key, value, options)
+}
+
+func (f backgroundForms) AddWithRetention(key string, timestamp int64, value float64, duration int64) (int64, error) {
+	options := DefaultCreateOptions
+	options.RetentionMSecs = time.Duration(duration)
+	return f.ctxForms.AddWithOptionsCtx(context.Background(), key, timestamp, value, options)
+}
+
+func (f backgroundForms) IncrBy(key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return f.ctxForms.IncrByCtx(context.Background(), key, timestamp, value, options)
+}
+
+func (f backgroundForms) IncrByAutoTs(key string, value float64, options CreateOptions) (int64, error) {
+	return f.ctxForms.IncrByAutoTsCtx(context.Background(), key, value, options)
+}
+
+func (f backgroundForms) DecrBy(key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return f.ctxForms.DecrByCtx(context.Background(), key, timestamp, value, options)
+}
+
+func (f backgroundForms) DecrByAutoTs(key string, value float64, options CreateOptions) (int64, error) {
+	return f.ctxForms.DecrByAutoTsCtx(context.Background(), key, value, options)
+}
+
+func (f backgroundForms) MultiAdd(samples ...Sample) ([]interface{}, error) {
+	return f.ctxForms.MultiAddCtx(context.Background(), samples...)
+}
+
+func (f backgroundForms) MultiAddWithResults(samples ...Sample) (MultiAddResults, error) {
+	return f.ctxForms.MultiAddWithResultsCtx(context.Background(), samples...)
+}
+
+func (f backgroundForms) MultiAddWithOptions(samples ...MultiAddSample) (MultiAddResults, error) {
+	return f.ctxForms.MultiAddWithOptionsCtx(context.Background(), samples...)
+}
+
+func (f backgroundForms) Range(key string, fromTimestamp int64, toTimestamp int64) ([]DataPoint, error) {
+	return f.ctxForms.RangeWithOptionsCtx(context.Background(), key, fromTimestamp, toTimestamp, DefaultRangeOptions)
+}
+
+func (f backgroundForms) AggRange(key string, fromTimestamp int64, toTimestamp int64, aggType AggregationType, bucketSizeSec int) ([]DataPoint, error) {
+	rangeOptions := NewRangeOptions().SetAggregation(aggType, bucketSizeSec)
+	return f.ctxForms.RangeWithOptionsCtx(context.Background(), key, fromTimestamp, toTimestamp, *rangeOptions)
+}
+
+func (f backgroundForms) RangeWithOptions(key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error) {
+	return f.ctxForms.RangeWithOptionsCtx(context.Background(), key, fromTimestamp, toTimestamp, rangeOptions)
+}
+
+func (f backgroundForms) ReverseRangeWithOptions(key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error) {
+	return f.ctxForms.ReverseRangeWithOptionsCtx(context.Background(), key, fromTimestamp, toTimestamp, rangeOptions)
+}
+
+func (f backgroundForms) AggMultiRange(fromTimestamp int64, toTimestamp int64, aggType AggregationType, bucketSizeSec int, filters ...string) ([]Range, error) {
+	mrangeOptions := NewMultiRangeOptions().SetAggregation(aggType, bucketSizeSec)
+	return f.ctxForms.MultiRangeWithOptionsCtx(context.Background(), fromTimestamp, toTimestamp, *mrangeOptions, filters...)
+}
+
+func (f backgroundForms) MultiRangeWithOptions(fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error) {
+	return f.ctxForms.MultiRangeWithOptionsCtx(context.Background(), fromTimestamp, toTimestamp, mrangeOptions, filters...)
+}
+
+func (f backgroundForms) MultiReverseRangeWithOptions(fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error) {
+	return f.ctxForms.MultiReverseRangeWithOptionsCtx(context.Background(), fromTimestamp, toTimestamp, mrangeOptions, filters...)
+}
+
+func (f backgroundForms) Get(key string) (*DataPoint, error) {
+	return f.ctxForms.GetCtx(context.Background(), key)
+}
+
+func (f backgroundForms) MultiGet(filters ...string) ([]Range, error) {
+	return f.ctxForms.MultiGetCtx(context.Background(), filters...)
+}
+
+func (f backgroundForms) MultiGetWithOptions(multiGetOptions MultiGetOptions, filters ...string) ([]Range, error) {
+	return f.ctxForms.MultiGetWithOptionsCtx(context.Background(), multiGetOptions, filters...)
+}
+
+func (f backgroundForms) Info(key string) (KeyInfo, error) {
+	return f.ctxForms.InfoCtx(context.Background(), key)
+}
+
+func (f backgroundForms) QueryIndex(filters ...string) ([]string, error) {
+	return f.ctxForms.QueryIndexCtx(context.Background(), filters...)
+}
+
+func (f backgroundForms) CreateRule(sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) error {
+	return f.ctxForms.CreateRuleCtx(context.Background(), sourceKey, aggType, bucketSizeMSec, destinationKey)
+}
+
+func (f backgroundForms) DeleteRule(sourceKey string, destinationKey string) error {
+	return f.ctxForms.DeleteRuleCtx(context.Background(), sourceKey, destinationKey)
+}
+
+func (f backgroundForms) DeleteSerie(key string) error {
+	return f.ctxForms.DeleteSerieCtx(context.Background(), key)
+}
+
+func (f backgroundForms) DeleteRange(key string, fromTimestamp int64, toTimestamp int64) (int64, error) {
+	return f.ctxForms.DeleteRangeCtx(context.Background(), key, fromTimestamp, toTimestamp)
+}
+
+// LoggingClient is a TimeSeriesClient logging each operation of the wrapped client, along with its key, or its filters
+// or number of samples for the multi-key operations, its duration and its error
+type LoggingClient struct {
+	backgroundForms
+	client TimeSeriesClient
+	logger Logger
+}
+
+// NewLoggingClient creates a LoggingClient logging the operations of client into logger
+func NewLoggingClient(client TimeSeriesClient, logger Logger) *LoggingClient {
+	c := &LoggingClient{client: client, logger: logger}
+	c.backgroundForms = backgroundForms{c}
+	return c
+}
+
+// log logs an operation started at start, once it completed with *err
+func (c *LoggingClient) log(operation, subject string, start time.Time, err *error) {
+	if *err != nil {
+		c.logger.Printf("%s %s failed after %v: %v", operation, subject, time.Since(start), *err)
+		return
+	}
+	c.logger.Printf("%s %s took %v", operation, subject, time.Since(start))
+}
+
+func filtersSubject(filters []string) string {
+	return fmt.Sprintf("%q", filters)
+}
+
+func samplesSubject(count int) string {
+	return fmt.Sprintf("%d samples", count)
+}
+
+func (c *LoggingClient) CreateKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) (err error) {
+	defer c.log("CreateKey", key, time.Now(), &err)
+	return c.client.CreateKeyWithOptionsCtx(ctx, key, options)
+}
+
+func (c *LoggingClient) AlterKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) (err error) {
+	defer c.log("AlterKey", key, time.Now(), &err)
+	return c.client.AlterKeyWithOptionsCtx(ctx, key, options)
+}
+
+func (c *LoggingClient) AddCtx(ctx context.Context, key string, timestamp int64, value float64) (storedTimestamp int64, err error) {
+	defer c.log("Add", key, time.Now(), &err)
+	return c.client.AddCtx(ctx, key, timestamp, value)
+}
+
+func (c *LoggingClient) AddAutoTsCtx(ctx context.Context, key string, value float64) (storedTimestamp int64, err error) {
+	defer c.log("AddAutoTs", key, time.Now(), &err)
+	return c.client.AddAutoTsCtx(ctx, key, value)
+}
+
+func (c *LoggingClient) AddWithOptionsCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (storedTimestamp int64, err error) {
+	defer c.log("Add", key, time.Now(), &err)
+	return c.client.AddWithOptionsCtx(ctx, key, timestamp, value, options)
+}
+
+func (c *LoggingClient) AddAutoTsWithOptionsCtx(ctx context.Context, key string, value float64, options CreateOptions) (storedTimestamp int64, err error) {
+	defer c.log("AddAutoTs", key, time.Now(), &err)
+	return c.client.AddAutoTsWithOptionsCtx(ctx, key, value, options)
+}
+
+func (c *LoggingClient) IncrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (storedTimestamp int64, err error) {
+	defer c.log("IncrBy", key, time.Now(), &err)
+	return c.client.IncrByCtx(ctx, key, timestamp, value, options)
+}
+
+func (c *LoggingClient) IncrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (storedTimestamp int64, err error) {
+	defer c.log("IncrByAutoTs", key, time.Now(), &err)
+	return c.client.IncrByAutoTsCtx(ctx, key, value, options)
+}
+
+func (c *LoggingClient) DecrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (storedTimestamp int64, err error) {
+	defer c.log("DecrBy", key, time.Now(), &err)
+	return c.client.DecrByCtx(ctx, key, timestamp, value, options)
+}
+
+func (c *LoggingClient) DecrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (storedTimestamp int64, err error) {
+	defer c.log("DecrByAutoTs", key, time.Now(), &err)
+	return c.client.DecrByAutoTsCtx(ctx, key, value, options)
+}
+
+func (c *LoggingClient) MultiAddCtx(ctx context.Context, samples ...Sample) (timestamps []interface{}, err error) {
+	defer c.log("MultiAdd", samplesSubject(len(samples)), time.Now(), &err)
+	return c.client.MultiAddCtx(ctx, samples...)
+}
+
+func (c *LoggingClient) MultiAddWithResultsCtx(ctx context.Context, samples ...Sample) (results MultiAddResults, err error) {
+	defer c.log("MultiAddWithResults", samplesSubject(len(samples)), time.Now(), &err)
+	return c.client.MultiAddWithResultsCtx(ctx, samples...)
+}
+
+func (c *LoggingClient) MultiAddWithOptionsCtx(ctx context.Context, samples ...MultiAddSample) (results MultiAddResults, err error) {
+	defer c.log("MultiAddWithOptions", samplesSubject(len(samples)), time.Now(), &err)
+	return c.client.MultiAddWithOptionsCtx(ctx, samples...)
+}
+
+func (c *LoggingClient) RangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) (dataPoints []DataPoint, err error) {
+	defer c.log("Range", key, time.Now(), &err)
+	return c.client.RangeWithOptionsCtx(ctx, key, fromTimestamp, toTimestamp, rangeOptions)
+}
+
+func (c *LoggingClient) ReverseRangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) (dataPoints []DataPoint, err error) {
+	defer c.log("ReverseRange", key, time.Now(), &err)
+	return c.client.ReverseRangeWithOptionsCtx(ctx, key, fromTimestamp, toTimestamp, rangeOptions)
+}
+
+func (c *LoggingClient) MultiRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) (ranges []Range, err error) {
+	defer c.log("MultiRange", filtersSubject(filters), time.Now(), &err)
+	return c.client.MultiRangeWithOptionsCtx(ctx, fromTimestamp, toTimestamp, mrangeOptions, filters...)
+}
+
+func (c *LoggingClient) MultiReverseRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) (ranges []Range, err error) {
+	defer c.log("MultiReverseRange", filtersSubject(filters), time.Now(), &err)
+	return c.client.MultiReverseRangeWithOptionsCtx(ctx, fromTimestamp, toTimestamp, mrangeOptions, filters...)
+}
+
+func (c *LoggingClient) GetCtx(ctx context.Context, key string) (dataPoint *DataPoint, err error) {
+	defer c.log("Get", key, time.Now(), &err)
+	return c.client.GetCtx(ctx, key)
+}
+
+func (c *LoggingClient) MultiGetCtx(ctx context.Context, filters ...string) (ranges []Range, err error) {
+	defer c.log("MultiGet", filtersSubject(filters), time.Now(), &err)
+	return c.client.MultiGetCtx(ctx, filters...)
+}
+
+func (c *LoggingClient) MultiGetWithOptionsCtx(ctx context.Context, multiGetOptions MultiGetOptions, filters ...string) (ranges []Range, err error) {
+	defer c.log("MultiGet", filtersSubject(filters), time.Now(), &err)
+	return c.client.MultiGetWithOptionsCtx(ctx, multiGetOptions, filters...)
+}
+
+func (c *LoggingClient) InfoCtx(ctx context.Context, key string) (res KeyInfo, err error) {
+	defer c.log("Info", key, time.Now(), &err)
+	return c.client.InfoCtx(ctx, key)
+}
+
+func (c *LoggingClient) QueryIndexCtx(ctx context.Context, filters ...string) (keys []string, err error) {
+	defer c.log("QueryIndex", filtersSubject(filters), time.Now(), &err)
+	return c.client.QueryIndexCtx(ctx, filters...)
+}
+
+func (c *LoggingClient) CreateRuleCtx(ctx context.Context, sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) (err error) {
+	defer c.log("CreateRule", sourceKey+" -> "+destinationKey, time.Now(), &err)
+	return c.client.CreateRuleCtx(ctx, sourceKey, aggType, bucketSizeMSec, destinationKey)
+}
+
+func (c *LoggingClient) DeleteRuleCtx(ctx context.Context, sourceKey string, destinationKey string) (err error) {
+	defer c.log("DeleteRule", sourceKey+" -> "+destinationKey, time.Now(), &err)
+	return c.client.DeleteRuleCtx(ctx, sourceKey, destinationKey)
+}
+
+func (c *LoggingClient) DeleteSerieCtx(ctx context.Context, key string) (err error) {
+	defer c.log("DeleteSerie", key, time.Now(), &err)
+	return c.client.DeleteSerieCtx(ctx, key)
+}
+
+func (c *LoggingClient) DeleteRangeCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64) (totalDeletedSamples int64, err error) {
+	defer c.log("DeleteRange", key, time.Now(), &err)
+	return c.client.DeleteRangeCtx(ctx, key, fromTimestamp, toTimestamp)
+}
+
+// ReadOnlyClient is a TimeSeriesClient refusing the operations writing to the database with ErrReadOnly,
+// without sending them, and passing the reads to the wrapped client
+type ReadOnlyClient struct {
+	backgroundForms
+	client TimeSeriesClient
+}
+
+// NewReadOnlyClient creates a ReadOnlyClient reading through client
+func NewReadOnlyClient(client TimeSeriesClient) *ReadOnlyClient {
+	c := &ReadOnlyClient{client: client}
+	c.backgroundForms = backgroundForms{c}
+	return c
+}
+
+func (c *ReadOnlyClient) CreateKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) error {
+	return ErrReadOnly
+}
+
+func (c *ReadOnlyClient) AlterKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) error {
+	return ErrReadOnly
+}
+
+func (c *ReadOnlyClient) AddCtx(ctx context.Context, key string, timestamp int64, value float64) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) AddAutoTsCtx(ctx context.Context, key string, value float64) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) AddWithOptionsCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) AddAutoTsWithOptionsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) IncrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) IncrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) DecrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) DecrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) MultiAddCtx(ctx context.Context, samples ...Sample) ([]interface{}, error) {
+	return nil, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) MultiAddWithResultsCtx(ctx context.Context, samples ...Sample) (MultiAddResults, error) {
+	return nil, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) MultiAddWithOptionsCtx(ctx context.Context, samples ...MultiAddSample) (MultiAddResults, error) {
+	return nil, ErrReadOnly
+}
+
+func (c *ReadOnlyClient) RangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error) {
+	return c.client.RangeWithOptionsCtx(ctx, key, fromTimestamp, toTimestamp, rangeOptions)
+}
+
+func (c *ReadOnlyClient) ReverseRangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error) {
+	return c.client.ReverseRangeWithOptionsCtx(ctx, key, fromTimestamp, toTimestamp, rangeOptions)
+}
+
+func (c *ReadOnlyClient) MultiRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error) {
+	return c.client.MultiRangeWithOptionsCtx(ctx, fromTimestamp, toTimestamp, mrangeOptions, filters...)
+}
+
+func (c *ReadOnlyClient) MultiReverseRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error) {
+	return c.client.MultiReverseRangeWithOptionsCtx(ctx, fromTimestamp, toTimestamp, mrangeOptions, filters...)
+}
+
+func (c *ReadOnlyClient) GetCtx(ctx context.Context, key string) (*DataPoint, error) {
+	return c.client.GetCtx(ctx, key)
+}
+
+func (c *ReadOnlyClient) MultiGetCtx(ctx context.Context, filters ...string) ([]Range, error) {
+	return c.client.MultiGetCtx(ctx, filters...)
+}
+
+func (c *ReadOnlyClient) MultiGetWithOptionsCtx(ctx context.Context, multiGetOptions MultiGetOptions, filters ...string) ([]Range, error) {
+	return c.client.MultiGetWithOptionsCtx(ctx, multiGetOptions, filters...)
+}
+
+func (c *ReadOnlyClient) InfoCtx(ctx context.Context, key string) (KeyInfo, error) {
+	return c.client.InfoCtx(ctx, key)
+}
+
+func (c *ReadOnlyClient) QueryIndexCtx(ctx context.Context, filters ...string) ([]string, error) {
+	return c.client.QueryIndexCtx(ctx, filters...)
+}
+
+func (c *ReadOnlyClient) CreateRuleCtx(ctx context.Context, sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) error {
+	return ErrReadOnly
+}
+
+func (c *ReadOnlyClient) DeleteRuleCtx(ctx context.Context, sourceKey string, destinationKey string) error {
+	return ErrReadOnly
+}
+
+func (c *ReadOnlyClient) DeleteSerieCtx(ctx context.Context, key string) error {
+	return ErrReadOnly
+}
+
+func (c *ReadOnlyClient) DeleteRangeCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64) (int64, error) {
+	return 0, ErrReadOnly
+}
+
+// NamespaceClient is a TimeSeriesClient prefixing the keys given to the wrapped client with a name and a separator,
+// and stripping the prefix from the keys it returns, like Client.Namespace does for a Client.
+// When NamespaceOptions.Label is set, the series created are labeled with the name, and the multi-key reads
+// are filtered on it.
+type NamespaceClient struct {
+	backgroundForms
+	client TimeSeriesClient
+	ns     *namespace
+}
+
+// NewNamespaceClient creates a NamespaceClient keeping the series of client under the namespace name
+func NewNamespaceClient(client TimeSeriesClient, name string, options NamespaceOptions) *NamespaceClient {
+	c := &NamespaceClient{
+		client: client,
+		ns:     &namespace{prefix: name + options.Separator, label: options.Label, value: name},
+	}
+	c.backgroundForms = backgroundForms{c}
+	return c
+}
+
+func (c *NamespaceClient) CreateKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) error {
+	return c.client.CreateKeyWithOptionsCtx(ctx, c.ns.key(key), c.ns.createOptions(options))
+}
+
+func (c *NamespaceClient) AlterKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) error {
+	return c.client.AlterKeyWithOptionsCtx(ctx, c.ns.key(key), c.ns.alterOptions(options))
+}
+
+// AddCtx adds the sample with the options of the namespace, labeling the series it creates
+func (c *NamespaceClient) AddCtx(ctx context.Context, key string, timestamp int64, value float64) (int64, error) {
+	return c.client.AddWithOptionsCtx(ctx, c.ns.key(key), timestamp, value, c.ns.createOptions(CreateOptions{}))
+}
+
+// AddAutoTsCtx adds the sample with the options of the namespace, labeling the series it creates
+func (c *NamespaceClient) AddAutoTsCtx(ctx context.Context, key string, value float64) (int64, error) {
+	return c.client.AddAutoTsWithOptionsCtx(ctx, c.ns.key(key), value, c.ns.createOptions(CreateOptions{}))
+}
+
+func (c *NamespaceClient) AddWithOptionsCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return c.client.AddWithOptionsCtx(ctx, c.ns.key(key), timestamp, value, c.ns.createOptions(options))
+}
+
+func (c *NamespaceClient) AddAutoTsWithOptionsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error) {
+	return c.client.AddAutoTsWithOptionsCtx(ctx, c.ns.key(key), value, c.ns.createOptions(options))
+}
+
+func (c *NamespaceClient) IncrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return c.client.IncrByCtx(ctx, c.ns.key(key), timestamp, value, c.ns.createOptions(options))
+}
+
+func (c *NamespaceClient) IncrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error) {
+	return c.client.IncrByAutoTsCtx(ctx, c.ns.key(key), value, c.ns.createOptions(options))
+}
+
+func (c *NamespaceClient) DecrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error) {
+	return c.client.DecrByCtx(ctx, c.ns.key(key), timestamp, value, c.ns.createOptions(options))
+}
+
+func (c *NamespaceClient) DecrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error) {
+	return c.client.DecrByAutoTsCtx(ctx, c.ns.key(key), value, c.ns.createOptions(options))
+}
+
+func (c *NamespaceClient) MultiAddCtx(ctx context.Context, samples ...Sample) ([]interface{}, error) {
+	return c.client.MultiAddCtx(ctx, c.ns.samples(samples)...)
+}
+
+func (c *NamespaceClient) MultiAddWithResultsCtx(ctx context.Context, samples ...Sample) (MultiAddResults, error) {
+	multiAddSamples := make([]MultiAddSample, len(samples))
+	for i, sample := range samples {
+		multiAddSamples[i] = MultiAddSample{Sample: sample}
+	}
+	return c.MultiAddWithOptionsCtx(ctx, multiAddSamples...)
+}
+
+// MultiAddWithOptionsCtx adds the samples in the namespace, the results holding the samples as given
+func (c *NamespaceClient) MultiAddWithOptionsCtx(ctx context.Context, samples ...MultiAddSample) (MultiAddResults, error) {
+	prefixed := make([]MultiAddSample, len(samples))
+	for i, sample := range samples {
+		prefixed[i] = MultiAddSample{Sample: Sample{Key: c.ns.key(sample.Key), DataPoint: sample.DataPoint}}
+		if sample.CreateOptions != nil {
+			options := c.ns.createOptions(*sample.CreateOptions)
+			prefixed[i].CreateOptions = &options
+		}
+	}
+	results, err := c.client.MultiAddWithOptionsCtx(ctx, prefixed...)
+	for i := range results {
+		if i < len(samples) {
+			results[i].Sample = samples[i]
+		}
+	}
+	return results, err
+}
+
+func (c *NamespaceClient) RangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error) {
+	return c.client.RangeWithOptionsCtx(ctx, c.ns.key(key), fromTimestamp, toTimestamp, rangeOptions)
+}
+
+func (c *NamespaceClient) ReverseRangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error) {
+	return c.client.ReverseRangeWithOptionsCtx(ctx, c.ns.key(key), fromTimestamp, toTimestamp, rangeOptions)
+}
+
+func (c *NamespaceClient) MultiRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error) {
+	ranges, err := c.client.MultiRangeWithOptionsCtx(ctx, fromTimestamp, toTimestamp, mrangeOptions, c.ns.filters(filters)...)
+	return c.ns.stripRanges(ranges), err
+}
+
+func (c *NamespaceClient) MultiReverseRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error) {
+	ranges, err := c.client.MultiReverseRangeWithOptionsCtx(ctx, fromTimestamp, toTimestamp, mrangeOptions, c.ns.filters(filters)...)
+	return c.ns.stripRanges(ranges), err
+}
+
+func (c *NamespaceClient) GetCtx(ctx context.Context, key string) (*DataPoint, error) {
+	return c.client.GetCtx(ctx, c.ns.key(key))
+}
+
+func (c *NamespaceClient) MultiGetCtx(ctx context.Context, filters ...string) ([]Range, error) {
+	return c.MultiGetWithOptionsCtx(ctx, DefaultMultiGetOptions, filters...)
+}
+
+func (c *NamespaceClient) MultiGetWithOptionsCtx(ctx context.Context, multiGetOptions MultiGetOptions, filters ...string) ([]Range, error) {
+	ranges, err := c.client.MultiGetWithOptionsCtx(ctx, multiGetOptions, c.ns.filters(filters)...)
+	return c.ns.stripRanges(ranges), err
+}
+
+func (c *NamespaceClient) InfoCtx(ctx context.Context, key string) (KeyInfo, error) {
+	info, err := c.client.InfoCtx(ctx, c.ns.key(key))
+	return c.ns.stripInfo(info), err
+}
+
+func (c *NamespaceClient) QueryIndexCtx(ctx context.Context, filters ...string) ([]string, error) {
+	keys, err := c.client.QueryIndexCtx(ctx, c.ns.filters(filters)...)
+	return c.ns.stripKeys(keys), err
+}
+
+func (c *NamespaceClient) CreateRuleCtx(ctx context.Context, sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) error {
+	return c.client.CreateRuleCtx(ctx, c.ns.key(sourceKey), aggType, bucketSizeMSec, c.ns.key(destinationKey))
+}
+
+func (c *NamespaceClient) DeleteRuleCtx(ctx context.Context, sourceKey string, destinationKey string) error {
+	return c.client.DeleteRuleCtx(ctx, c.ns.key(sourceKey), c.ns.key(destinationKey))
+}
+
+func (c *NamespaceClient) DeleteSerieCtx(ctx context.Context, key string) error {
+	return c.client.DeleteSerieCtx(ctx, c.ns.key(key))
+}
+
+func (c *NamespaceClient) DeleteRangeCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64) (int64, error) {
+	return c.client.DeleteRangeCtx(ctx, c.ns.key(key), fromTimestamp, toTimestamp)
+}
+
+var (
+	_ TimeSeriesClient = (*LoggingClient)(nil)
+	_ TimeSeriesClient = (*ReadOnlyClient)(nil)
+	_ TimeSeriesClient = (*NamespaceClient)(nil)
+)
@@ -0,0 +1,155 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLogger records the logged lines
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestLoggingClient(t *testing.T) {
+	logger := &recordingLogger{}
+	c := NewLoggingClient(hookedClient(), logger)
+	ctx := context.Background()
+
+	keys, err := c.QueryIndexCtx(ctx, "a=1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "bb"}, keys)
+	_, err = c.Get("missing")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	assert.Len(t, logger.lines, 2)
+	assert.True(t, strings.HasPrefix(logger.lines[0], `QueryIndex ["a=1"] took `), logger.lines[0])
+	assert.True(t, strings.HasPrefix(logger.lines[1], "Get missing failed after "), logger.lines[1])
+	assert.True(t, strings.HasSuffix(logger.lines[1], ": ERR TSDB: the key does not exist"), logger.lines[1])
+}
+
+func TestReadOnlyClient(t *testing.T) {
+	var commands []string
+	c := NewReadOnlyClient(&Client{Pool: &stubPool{handler: namespaceHandler(&commands)}, Name: "test"})
+	ctx := context.Background()
+	writes := []struct {
+		name string
+		run  func() error
+	}{
+		{"create", func() error { return c.CreateKeyWithOptionsCtx(ctx, "a", DefaultCreateOptions) }},
+		{"alter", func() error { return c.AlterKeyWithOptionsCtx(ctx, "a", DefaultCreateOptions) }},
+		{"add", func() error {
+			_, err := c.AddWithOptionsCtx(ctx, "a", 1, 1, DefaultCreateOptions)
+			return err
+		}},
+		{"incrby", func() error {
+			_, err := c.IncrByAutoTsCtx(ctx, "a", 1, DefaultCreateOptions)
+			return err
+		}},
+		{"add without options", func() error {
+			_, err := c.Add("a", 1, 1)
+			return err
+		}},
+		{"multi add", func() error {
+			_, err := c.MultiAddCtx(ctx, Sample{Key: "a"})
+			return err
+		}},
+		{"multi add with results", func() error {
+			_, err := c.MultiAddWithResults(Sample{Key: "a"})
+			return err
+		}},
+		{"create rule", func() error { return c.CreateRuleCtx(ctx, "a", AvgAggregation, 60, "b") }},
+		{"delete", func() error { return c.DeleteSerieCtx(ctx, "a") }},
+		{"delete range", func() error {
+			_, err := c.DeleteRangeCtx(ctx, "a", 0, 1)
+			return err
+		}},
+	}
+	for _, tt := range writes {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, ErrReadOnly, tt.run())
+		})
+	}
+	assert.Empty(t, commands)
+
+	keys, err := c.QueryIndexCtx(ctx, "a=1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tenant:a", "tenant:b"}, keys)
+	keys, err = c.QueryIndex("b=2")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tenant:a", "tenant:b"}, keys)
+	assert.Equal(t, []string{"TS.QUERYINDEX a=1", "TS.QUERYINDEX b=2"}, commands)
+}
+
+func TestNamespaceClient(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		run      func(c *NamespaceClient) (interface{}, error)
+		want     interface{}
+		wantCmds []string
+	}{
+		{"add", func(c *NamespaceClient) (interface{}, error) {
+			return c.AddWithOptionsCtx(ctx, "a", 1, 2, CreateOptions{})
+		}, int64(1), []string{"TS.ADD tenant:a 1 2 LABELS ns tenant"}},
+		{"add without options", func(c *NamespaceClient) (interface{}, error) {
+			return c.Add("a", 1, 2)
+		}, int64(1), []string{"TS.ADD tenant:a 1 2 LABELS ns tenant"}},
+		{"create rule", func(c *NamespaceClient) (interface{}, error) {
+			return nil, c.CreateRuleCtx(ctx, "a", AvgAggregation, 60, "avg")
+		}, nil, []string{"TS.CREATERULE tenant:a tenant:avg AGGREGATION AVG 60"}},
+		{"query index", func(c *NamespaceClient) (interface{}, error) {
+			return c.QueryIndexCtx(ctx, "a=1")
+		}, []string{"a", "b"}, []string{"TS.QUERYINDEX a=1 ns=tenant"}},
+		{"query index without context", func(c *NamespaceClient) (interface{}, error) {
+			return c.QueryIndex("a=1")
+		}, []string{"a", "b"}, []string{"TS.QUERYINDEX a=1 ns=tenant"}},
+		{"info", func(c *NamespaceClient) (interface{}, error) {
+			info, err := c.InfoCtx(ctx, "a")
+			return info.Rules, err
+		}, []Rule{{DestKey: "avg", BucketSizeSec: 60, AggType: AvgAggregation}}, []string{"TS.INFO tenant:a"}},
+		{"multi add", func(c *NamespaceClient) (interface{}, error) {
+			results, err := c.MultiAddWithOptionsCtx(ctx,
+				MultiAddSample{Sample: Sample{Key: "a", DataPoint: DataPoint{Timestamp: 1, Value: 1}}},
+				MultiAddSample{Sample: Sample{Key: "b", DataPoint: DataPoint{Timestamp: 2, Value: 2}}})
+			return []string{results[0].Sample.Key, results[1].Sample.Key}, err
+		}, []string{"a", "b"}, []string{"TS.MADD tenant:a 1 1 tenant:b 2 2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var commands []string
+			client := &Client{Pool: &stubPool{handler: namespaceHandler(&commands)}, Name: "test"}
+			c := NewNamespaceClient(client, "tenant", *NewNamespaceOptions().SetLabel("ns"))
+			got, err := tt.run(c)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCmds, commands)
+		})
+	}
+}
+
+func TestDecorators_Stacked(t *testing.T) {
+	var commands []string
+	logger := &recordingLogger{}
+	client := &Client{Pool: &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		commands = append(commands, cmd)
+		return nil, redis.Error("ERR TSDB: the key does not exist")
+	}}, Name: "test"}
+	var c TimeSeriesClient = NewReadOnlyClient(NewLoggingClient(NewNamespaceClient(client, "tenant", DefaultNamespaceOptions), logger))
+
+	_, err := c.GetCtx(context.Background(), "a")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+	assert.Equal(t, ErrReadOnly, c.DeleteSerieCtx(context.Background(), "a"))
+	assert.Equal(t, []string{GET_CMD}, commands)
+	assert.Len(t, logger.lines, 1)
+	assert.True(t, strings.HasPrefix(logger.lines[0], "Get a failed after "), logger.lines[0])
+}
@@ -0,0 +1,291 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Label added by the server to the series produced by GROUPBY/REDUCE, naming the reducer
+const reducerLabel = "__reducer__"
+
+// emulatedQuery are the options of a range query applied client side, as the server does not support them.
+// The series are fetched raw when they are filtered or aggregated client side, then processed in the order of the
+// server: the samples are filtered, aggregated into buckets, reversed and counted, before the series are grouped.
+type emulatedQuery struct {
+	// filter tells whether FILTER_BY_TS and FILTER_BY_VALUE are applied client side
+	filter                             bool
+	filterByTs                         []int64
+	filterByValueMin, filterByValueMax *float64
+	// aggregate tells whether AGGREGATION, ALIGN and COUNT are applied client side, on raw samples.
+	// It is set whenever filter is, the filters applying to the samples before they are aggregated.
+	aggregate  bool
+	aggType    AggregationType
+	timeBucket int
+	align      int64
+	count      int64
+	reverse    bool
+	// groupBy, when set, groups the series client side, reducing them with reducer
+	groupBy string
+	reducer ReducerType
+}
+
+// emulatedQuery returns the options of a range query to apply client side, or nil when the server supports them
+// all or the client does not emulate them
+func (client *Client) emulatedQuery(ctx context.Context, cmd string, options MultiRangeOptions) (*emulatedQuery, error) {
+	if !client.EmulateUnsupported {
+		return nil, nil
+	}
+	if len(options.FilterByTs) == 0 && options.FilterByValueMin == nil && options.Align == -1 && options.GroupBy == "" {
+		return nil, nil
+	}
+	capabilities, err := client.ServerCapabilitiesCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	q := &emulatedQuery{
+		filter: len(options.FilterByTs) > 0 && !capabilities.Supports(FeatureFilterByTs) ||
+			options.FilterByValueMin != nil && !capabilities.Supports(FeatureFilterByValue),
+		filterByTs:       options.FilterByTs,
+		filterByValueMin: options.FilterByValueMin,
+		filterByValueMax: options.FilterByValueMax,
+		aggType:          options.AggType,
+		timeBucket:       options.TimeBucket,
+		align:            options.Align,
+		count:            options.Count,
+		reverse:          cmd == REVRANGE_CMD || cmd == MREVRANGE_CMD,
+		reducer:          options.Reduce,
+	}
+	// ALIGN without AGGREGATION is left to the server, which rejects it
+	q.aggregate = q.filter || options.AggType != "" && options.Align != -1 && !capabilities.Supports(FeatureAlign)
+	if options.GroupBy != "" && (q.aggregate || !capabilities.Supports(FeatureGroupBy)) {
+		switch ReducerType(strings.ToUpper(string(options.Reduce))) {
+		case SumReducer, MinReducer, MaxReducer:
+		default:
+			return nil, &UnsupportedByServerError{Feature: FeatureGroupBy, RequiredVersion: FeatureVersions[FeatureGroupBy],
+				ServerVersion: capabilities.ModuleVersion}
+		}
+		q.groupBy = options.GroupBy
+	}
+	if !q.aggregate && q.groupBy == "" {
+		return nil, nil
+	}
+	return q, nil
+}
+
+// multiRangeOptions returns the options of a single series query as the ones of a multi series query
+func (options RangeOptions) multiRangeOptions() MultiRangeOptions {
+	return MultiRangeOptions{
+		AggType:          options.AggType,
+		TimeBucket:       options.TimeBucket,
+		Count:            options.Count,
+		Align:            options.Align,
+		FilterByTs:       options.FilterByTs,
+		FilterByValueMin: options.FilterByValueMin,
+		FilterByValueMax: options.FilterByValueMax,
+	}
+}
+
+// command returns the range command fetching the samples, raw ones being fetched in ascending order
+func (q *emulatedQuery) command(cmd string) string {
+	if !q.aggregate {
+		return cmd
+	}
+	switch cmd {
+	case REVRANGE_CMD:
+		return RANGE_CMD
+	case MREVRANGE_CMD:
+		return MRANGE_CMD
+	}
+	return cmd
+}
+
+// rangeOptions returns the options sent to the server, without the ones applied client side
+func (q *emulatedQuery) rangeOptions(options RangeOptions) RangeOptions {
+	if q.aggregate {
+		options.AggType, options.TimeBucket, options.Count, options.Align = "", -1, -1, -1
+	}
+	if q.filter {
+		options.FilterByTs, options.FilterByValueMin, options.FilterByValueMax = []int64{}, nil, nil
+	}
+	return options
+}
+
+// multiRangeOptions returns the options sent to the server, without the ones applied client side
+func (q *emulatedQuery) multiRangeOptions(options MultiRangeOptions) MultiRangeOptions {
+	if q.aggregate {
+		options.AggType, options.TimeBucket, options.Count, options.Align = "", -1, -1, -1
+	}
+	if q.filter {
+		options.FilterByTs, options.FilterByValueMin, options.FilterByValueMax = []int64{}, nil, nil
+	}
+	if q.groupBy != "" {
+		options.WithLabels, options.SelectedLabels = true, []string{}
+		options.GroupBy, options.Reduce = "", ""
+	}
+	return options
+}
+
+// apply filters, aggregates, reverses and counts the raw samples of a series
+func (q *emulatedQuery) apply(dataPoints []DataPoint) ([]DataPoint, error) {
+	if !q.aggregate {
+		return dataPoints, nil
+	}
+	var err error
+	if q.filter {
+		dataPoints = q.filtered(dataPoints)
+	}
+	if q.aggType != "" {
+		if dataPoints, err = q.aggregated(dataPoints); err != nil {
+			return nil, err
+		}
+	}
+	if q.reverse {
+		for i, j := 0, len(dataPoints)-1; i < j; i, j = i+1, j-1 {
+			dataPoints[i], dataPoints[j] = dataPoints[j], dataPoints[i]
+		}
+	}
+	if q.count >= 0 && int64(len(dataPoints)) > q.count {
+		dataPoints = dataPoints[:q.count]
+	}
+	return dataPoints, nil
+}
+
+// applyRanges applies the query to the samples of every series, then groups the series
+func (q *emulatedQuery) applyRanges(ranges []Range) ([]Range, error) {
+	for i := range ranges {
+		dataPoints, err := q.apply(ranges[i].DataPoints)
+		if err != nil {
+			return nil, err
+		}
+		ranges[i].DataPoints = dataPoints
+	}
+	if q.groupBy == "" {
+		return ranges, nil
+	}
+	groups := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		value, found := r.Labels[q.groupBy]
+		if !found {
+			continue
+		}
+		groups = append(groups, Range{
+			Name: q.groupBy + "=" + value,
+			Labels: map[string]string{
+				q.groupBy:    value,
+				reducerLabel: strings.ToLower(string(q.reducer)),
+				sourceLabel:  r.Name,
+			},
+			DataPoints: r.DataPoints,
+		})
+	}
+	groups, err := regroupRanges(groups, q.reducer, q.reverse, q.count)
+	if err != nil {
+		return nil, err
+	}
+	sortRanges(groups)
+	return groups, nil
+}
+
+// filtered returns the samples matching FILTER_BY_TS and FILTER_BY_VALUE
+func (q *emulatedQuery) filtered(dataPoints []DataPoint) []DataPoint {
+	var timestamps map[int64]bool
+	if len(q.filterByTs) > 0 {
+		timestamps = make(map[int64]bool, len(q.filterByTs))
+		for _, timestamp := range q.filterByTs {
+			timestamps[timestamp] = true
+		}
+	}
+	filtered := make([]DataPoint, 0, len(dataPoints))
+	for _, dp := range dataPoints {
+		if timestamps != nil && !timestamps[dp.Timestamp] {
+			continue
+		}
+		if q.filterByValueMin != nil && (dp.Value < *q.filterByValueMin || dp.Value > *q.filterByValueMax) {
+			continue
+		}
+		filtered = append(filtered, dp)
+	}
+	return filtered
+}
+
+// aggregated aggregates the samples, in ascending order, into buckets of timeBucket aligned to align
+func (q *emulatedQuery) aggregated(dataPoints []DataPoint) ([]DataPoint, error) {
+	if q.timeBucket <= 0 {
+		return nil, fmt.Errorf("aggregation %s requires a positive time bucket, got %d", q.aggType, q.timeBucket)
+	}
+	bucket, align := int64(q.timeBucket), q.align
+	if align == -1 {
+		align = 0
+	}
+	buckets := []DataPoint{}
+	for start := 0; start < len(dataPoints); {
+		offset := (dataPoints[start].Timestamp - align) % bucket
+		if offset < 0 {
+			offset += bucket
+		}
+		timestamp := dataPoints[start].Timestamp - offset
+		end := start
+		for end < len(dataPoints) && dataPoints[end].Timestamp < timestamp+bucket {
+			end++
+		}
+		value, err := aggregateValues(q.aggType, dataPoints[start:end])
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, DataPoint{Timestamp: timestamp, Value: value})
+		start = end
+	}
+	return buckets, nil
+}
+
+// aggregateValues applies aggregation to the values of a bucket, which is not empty
+func aggregateValues(aggregation AggregationType, dataPoints []DataPoint) (float64, error) {
+	aggregation = AggregationType(strings.ToUpper(string(aggregation)))
+	switch aggregation {
+	case CountAggregation:
+		return float64(len(dataPoints)), nil
+	case FirstAggregation:
+		return dataPoints[0].Value, nil
+	case LastAggregation:
+		return dataPoints[len(dataPoints)-1].Value, nil
+	}
+	sum, min, max := 0.0, math.Inf(1), math.Inf(-1)
+	for _, dp := range dataPoints {
+		sum += dp.Value
+		min = math.Min(min, dp.Value)
+		max = math.Max(max, dp.Value)
+	}
+	n := float64(len(dataPoints))
+	switch aggregation {
+	case SumAggregation:
+		return sum, nil
+	case MinAggregation:
+		return min, nil
+	case MaxAggregation:
+		return max, nil
+	case RangeAggregation:
+		return max - min, nil
+	case AvgAggregation:
+		return sum / n, nil
+	case StdPAggregation, StdSAggregation, VarPAggregation, VarSAggregation:
+	default:
+		return 0, fmt.Errorf("aggregation %s can not be applied client side", aggregation)
+	}
+	squares := 0.0
+	for _, dp := range dataPoints {
+		squares += (dp.Value - sum/n) * (dp.Value - sum/n)
+	}
+	variance := squares / n
+	if aggregation == StdSAggregation || aggregation == VarSAggregation {
+		variance = 0
+		if n > 1 {
+			variance = squares / (n - 1)
+		}
+	}
+	if aggregation == StdPAggregation || aggregation == StdSAggregation {
+		return math.Sqrt(variance), nil
+	}
+	return variance, nil
+}
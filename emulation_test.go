@@ -0,0 +1,220 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/RedisTimeSeries/redistimeseries-go/tstest"
+	"github.com/stretchr/testify/assert"
+)
+
+// emulationSeries are three series with overlapping timestamps, by key, with their labels and samples
+func emulationSeries() (map[string]map[string]string, []Sample) {
+	series := map[string]map[string]string{
+		"a": {"region": "east", "host": "a"},
+		"b": {"region": "east", "host": "b"},
+		"c": {"region": "west", "host": "c"},
+	}
+	var samples []Sample
+	for ts := int64(1); ts <= 60; ts++ {
+		samples = append(samples, Sample{"a", DataPoint{ts, float64(ts % 7)}}, Sample{"b", DataPoint{ts * 2, float64(ts % 5)}},
+			Sample{"c", DataPoint{ts + 3, -float64(ts % 3)}})
+	}
+	return series, samples
+}
+
+// emulationServers returns a RedisTimeSeries 1.6 server and a 1.4 one holding the same series,
+// and clients of them querying natively and emulating the options, which return the same results.
+// The returned function closes the servers.
+func emulationServers(t *testing.T, series map[string]map[string]string, samples []Sample) (*Client, *RecordingPool, *Client, func()) {
+	servers := []*tstest.Server{tstest.NewServer(), tstest.NewServer()}
+	servers[0].SetModuleVersion(tstest.ModuleVersion16)
+	native := NewClient(servers[0].Addr(), "native", nil)
+	pool := NewRecordingPool(NewSingleHostPool(servers[1].Addr(), nil))
+	emulating := NewClientFromConnPool(pool, "emulating")
+	emulating.EmulateUnsupported = true
+	for _, client := range []*Client{native, emulating} {
+		for key, labels := range series {
+			assert.NoError(t, client.CreateKeyWithOptions(key, CreateOptions{Labels: labels}))
+		}
+		_, err := client.MultiAdd(samples...)
+		assert.NoError(t, err)
+	}
+	return native, pool, emulating, func() {
+		pool.Close()
+		servers[0].Close()
+		servers[1].Close()
+	}
+}
+
+func TestClient_EmulateUnsupported_Range(t *testing.T) {
+	series, samples := emulationSeries()
+	native, pool, emulating, closeServers := emulationServers(t, series, samples)
+	defer closeServers()
+	tests := []struct {
+		name    string
+		options *RangeOptions
+	}{
+		{"filter by ts", NewRangeOptions().SetFilterByTs([]int64{3, 5, 8, 13, 21})},
+		{"filter by value", NewRangeOptions().SetFilterByValue(2, 4).SetCount(5)},
+		{"filtered aggregation", NewRangeOptions().SetFilterByValue(1, 5).SetAggregation(AvgAggregation, 10).SetCount(3)},
+		{"aligned aggregation", NewRangeOptions().SetAggregation(StdSAggregation, 7).SetAlign(3)},
+		{"every option", NewRangeOptions().SetFilterByTs([]int64{2, 4, 6, 8, 10, 12, 14}).SetFilterByValue(1, 6).
+			SetAggregation(CountAggregation, 5).SetAlign(2).SetCount(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, cmd := range []string{RANGE_CMD, REVRANGE_CMD} {
+				pool.Reset()
+				want, err := native.rangeWithOptions(context.Background(), cmd, "a", 0, 50, *tt.options)
+				assert.NoError(t, err)
+				got, err := emulating.rangeWithOptions(context.Background(), cmd, "a", 0, 50, *tt.options)
+				assert.NoError(t, err)
+				assert.NotEmpty(t, want)
+				assert.Equal(t, want, got, cmd)
+				exchanges := pool.Exchanges()
+				assert.Equal(t, RANGE_CMD, exchanges[len(exchanges)-1].Command)
+				assert.Equal(t, []string{"a", "0", "50"}, exchanges[len(exchanges)-1].Args, "raw samples fetched")
+			}
+		})
+	}
+}
+
+func TestClient_EmulateUnsupported_MultiRange(t *testing.T) {
+	series, samples := emulationSeries()
+	native, _, emulating, closeServers := emulationServers(t, series, samples)
+	defer closeServers()
+	tests := []struct {
+		name    string
+		options *MultiRangeOptions
+	}{
+		{"filter by value", NewMultiRangeOptions().SetFilterByValue(0, 2).SetWithLabels(true)},
+		{"aligned aggregation", NewMultiRangeOptions().SetAggregation(MaxAggregation, 10).SetAlign(4).SetCount(4)},
+		{"group by sum", NewMultiRangeOptions().SetGroupByReduce("region", SumReducer)},
+		{"group by min", NewMultiRangeOptions().SetGroupByReduce("host", MinReducer).SetFilterByTs([]int64{4, 8, 12})},
+		{"group by aggregated max", NewMultiRangeOptions().SetGroupByReduce("region", MaxReducer).
+			SetAggregation(SumAggregation, 10).SetAlign(5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, cmd := range []string{MRANGE_CMD, MREVRANGE_CMD} {
+				want, err := native.multiRangeWithOptions(context.Background(), cmd, 0, 40, *tt.options, []string{"region=(east,west)"})
+				assert.NoError(t, err)
+				got, err := emulating.multiRangeWithOptions(context.Background(), cmd, 0, 40, *tt.options, []string{"region=(east,west)"})
+				assert.NoError(t, err)
+				assert.NotEmpty(t, want)
+				assert.Equal(t, want, got, cmd)
+			}
+		})
+	}
+
+	_, err := emulating.MultiRangeWithOptions(0, 40, *NewMultiRangeOptions().SetGroupByReduce("region", "avg"), "region=east")
+	assert.True(t, errors.Is(err, ErrUnsupportedByServer), "reducer not emulated")
+}
+
+// TestClient_EmulateUnsupported_Expected checks the results of both clients against fixed values, following
+// RedisTimeSeries 1.6: the samples are filtered and aggregated per series, and COUNT limits the reduced groups
+func TestClient_EmulateUnsupported_Expected(t *testing.T) {
+	series := map[string]map[string]string{"x": {"region": "east"}, "y": {"region": "east"}, "z": {"region": "west"}}
+	samples := []Sample{
+		{"x", DataPoint{10, 1}}, {"x", DataPoint{20, 2}}, {"x", DataPoint{30, 3}}, {"x", DataPoint{40, 4}}, {"x", DataPoint{50, 5}},
+		{"y", DataPoint{10, 10}}, {"y", DataPoint{25, 20}}, {"y", DataPoint{40, 30}},
+		{"z", DataPoint{15, 7}}, {"z", DataPoint{30, 8}},
+	}
+	native, _, emulating, closeServers := emulationServers(t, series, samples)
+	defer closeServers()
+	group := func(region, reducer, sources string, dataPoints ...DataPoint) Range {
+		return Range{Name: "region=" + region, DataPoints: dataPoints,
+			Labels: map[string]string{"region": region, reducerLabel: reducer, sourceLabel: sources}}
+	}
+
+	ranges := []struct {
+		name    string
+		cmd     string
+		options *RangeOptions
+		want    []DataPoint
+	}{
+		{"aligned aggregation", RANGE_CMD, NewRangeOptions().SetAggregation(SumAggregation, 20).SetAlign(5),
+			[]DataPoint{{5, 3}, {25, 7}, {45, 5}}},
+		{"filter by value and count", RANGE_CMD, NewRangeOptions().SetFilterByValue(2, 4).SetCount(2),
+			[]DataPoint{{20, 2}, {30, 3}}},
+		{"reversed filtered aggregation", REVRANGE_CMD, NewRangeOptions().SetFilterByTs([]int64{10, 20, 30, 50}).
+			SetAggregation(AvgAggregation, 20).SetAlign(10), []DataPoint{{50, 5}, {30, 3}, {10, 1.5}}},
+	}
+	for _, tt := range ranges {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, client := range []*Client{native, emulating} {
+				got, err := client.rangeWithOptions(context.Background(), tt.cmd, "x", 0, 60, *tt.options)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got, client.Name)
+			}
+		})
+	}
+
+	multiRanges := []struct {
+		name    string
+		cmd     string
+		options *MultiRangeOptions
+		want    []Range
+	}{
+		{"count after group by", MRANGE_CMD, NewMultiRangeOptions().SetGroupByReduce("region", SumReducer).SetCount(2),
+			[]Range{group("east", "sum", "x,y", DataPoint{10, 11}, DataPoint{20, 2}), group("west", "sum", "z", DataPoint{15, 7}, DataPoint{30, 8})}},
+		{"reversed count after group by", MREVRANGE_CMD, NewMultiRangeOptions().SetGroupByReduce("region", MaxReducer).SetCount(2),
+			[]Range{group("east", "max", "x,y", DataPoint{50, 5}, DataPoint{40, 30}), group("west", "max", "z", DataPoint{30, 8}, DataPoint{15, 7})}},
+		{"filter by ts and group by", MRANGE_CMD, NewMultiRangeOptions().SetGroupByReduce("region", MinReducer).SetFilterByTs([]int64{10, 30, 40}),
+			[]Range{group("east", "min", "x,y", DataPoint{10, 1}, DataPoint{30, 3}, DataPoint{40, 4}), group("west", "min", "z", DataPoint{30, 8})}},
+		{"aligned aggregation and group by", MRANGE_CMD, NewMultiRangeOptions().SetGroupByReduce("region", SumReducer).
+			SetAggregation(MaxAggregation, 20).SetAlign(10),
+			[]Range{group("east", "sum", "x,y", DataPoint{10, 22}, DataPoint{30, 34}, DataPoint{50, 5}),
+				group("west", "sum", "z", DataPoint{10, 7}, DataPoint{30, 8})}},
+	}
+	for _, tt := range multiRanges {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, client := range []*Client{native, emulating} {
+				got, err := client.multiRangeWithOptions(context.Background(), tt.cmd, 0, 60, *tt.options, []string{"region=(east,west)"})
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got, client.Name)
+			}
+		})
+	}
+}
+
+func TestClient_EmulateUnsupported_Supported(t *testing.T) {
+	calls := 0
+	client := NewClientFromConnPool(&stubPool{func(cmd string, args ...interface{}) (interface{}, error) {
+		if cmd == "MODULE" {
+			return moduleListHandler(&calls, []interface{}{"name", "timeseries", "ver", int64(10600)})(cmd, args...)
+		}
+		assert.Equal(t, []interface{}{"key", "0", "10", "FILTER_BY_TS", "1", "AGGREGATION", AvgAggregation, "5", "ALIGN", "2"}, args)
+		return []interface{}{[]interface{}{int64(0), []byte("1.5")}}, nil
+	}}, "supported")
+	client.EmulateUnsupported = true
+	points, err := client.ReverseRangeWithOptions("key", 0, 10,
+		*NewRangeOptions().SetFilterByTs([]int64{1}).SetAggregation(AvgAggregation, 5).SetAlign(2))
+	assert.NoError(t, err)
+	assert.Equal(t, []DataPoint{{0, 1.5}}, points)
+	assert.Equal(t, 1, calls)
+}
+
+func TestAggregateValues(t *testing.T) {
+	dataPoints := []DataPoint{{1, 2}, {2, 4}, {3, 4}, {4, 4}, {5, 5}, {6, 5}, {7, 7}, {8, 9}}
+	tests := []struct {
+		aggregation AggregationType
+		want        float64
+	}{
+		{AvgAggregation, 5}, {SumAggregation, 40}, {MinAggregation, 2}, {MaxAggregation, 9}, {"range", 7},
+		{CountAggregation, 8}, {FirstAggregation, 2}, {LastAggregation, 9}, {StdPAggregation, 2},
+		{StdSAggregation, math.Sqrt(32.0 / 7)}, {VarPAggregation, 4}, {VarSAggregation, 32.0 / 7},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.aggregation), func(t *testing.T) {
+			got, err := aggregateValues(tt.aggregation, dataPoints)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+	_, err := aggregateValues("median", dataPoints)
+	assert.EqualError(t, err, "aggregation MEDIAN can not be applied client side")
+}
@@ -0,0 +1,131 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Errors reported by the server, classified by the Client so that they can be matched with errors.Is
+var (
+	// ErrKeyNotFound is returned when the time-series does not exist
+	ErrKeyNotFound = errors.New("time-series key does not exist")
+	// ErrKeyExists is returned when creating a time-series which already exists
+	ErrKeyExists = errors.New("time-series key already exists")
+	// ErrKeyIsNotTimeSeries is returned when the key holds another data type than a time-series
+	ErrKeyIsNotTimeSeries = errors.New("key is not a time-series")
+	// ErrDuplicateSampleBlocked is returned when a sample is rejected by the BLOCK duplicate policy
+	ErrDuplicateSampleBlocked = errors.New("duplicate sample blocked by the duplicate policy")
+	// ErrTimestampTooOld is returned when a sample is older than the latest sample or than the retention period allows
+	ErrTimestampTooOld = errors.New("sample timestamp is too old")
+	// ErrInvalidFilter is returned when a label filter of TS.MRANGE, TS.MGET or TS.QUERYINDEX can not be parsed
+	ErrInvalidFilter = errors.New("invalid label filter")
+	// ErrRuleExists is returned when creating a compaction rule conflicting with an existing one
+	ErrRuleExists = errors.New("compaction rule already exists")
+	// ErrRuleNotFound is returned when deleting a compaction rule which does not exist
+	ErrRuleNotFound = errors.New("compaction rule does not exist")
+)
+
+// Server error messages fragments, checked in order, and the error they are classified as.
+// Specific messages are listed before the generic ones they contain.
+var errorClasses = []struct {
+	fragments []string
+	err       error
+}{
+	{[]string{"compaction rule does not exist"}, ErrRuleNotFound},
+	{[]string{"already has a", "rule already exists"}, ErrRuleExists},
+	{[]string{"key does not exist"}, ErrKeyNotFound},
+	{[]string{"key already exists"}, ErrKeyExists},
+	{[]string{"not a tsdb key", "wrongtype"}, ErrKeyIsNotTimeSeries},
+	{[]string{"block mode"}, ErrDuplicateSampleBlocked},
+	{[]string{"older than", "timestamp must be"}, ErrTimestampTooOld},
+	{[]string{"invalid filter", "missing filter", "filter argument", "matcher", "failed parsing labels"}, ErrInvalidFilter},
+}
+
+// TimeSeriesError is a server error classified as one of the ErrXxx errors.
+// Error returns the original server message, Unwrap the classified error,
+// and errors.As also accepts a *redis.Error target to retrieve the raw server error.
+type TimeSeriesError struct {
+	// Message is the error message as replied by the server
+	Message string
+	Err     error
+}
+
+func (e *TimeSeriesError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the classified error, such as ErrKeyNotFound
+func (e *TimeSeriesError) Unwrap() error {
+	return e.Err
+}
+
+// As fills a *redis.Error target with the raw server error
+func (e *TimeSeriesError) As(target interface{}) bool {
+	if rerr, ok := target.(*redis.Error); ok {
+		*rerr = redis.Error(e.Message)
+		return true
+	}
+	return false
+}
+
+// classifyError turns the server errors with a known message into a *TimeSeriesError,
+// and returns any other error unchanged
+func classifyError(err error) error {
+	rerr, ok := err.(redis.Error)
+	if !ok {
+		return err
+	}
+	message := strings.ToLower(string(rerr))
+	for _, class := range errorClasses {
+		for _, fragment := range class.fragments {
+			if strings.Contains(message, fragment) {
+				return &TimeSeriesError{Message: string(rerr), Err: class.err}
+			}
+		}
+	}
+	return err
+}
+
+// classifyReplies classifies the errors found among the elements of an array reply, such as the one of TS.MADD
+func classifyReplies(replies []interface{}, err error) ([]interface{}, error) {
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	for i, reply := range replies {
+		if rerr, ok := reply.(redis.Error); ok {
+			replies[i] = classifyError(rerr)
+		}
+	}
+	return replies, nil
+}
+
+// Prefixes of the server errors reporting a temporary condition, after which the command can be sent again
+var transientErrorPrefixes = []string{"LOADING", "BUSY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN", "READONLY"}
+
+// isTransientError tells whether the command may succeed if sent again: the network errors, such as connection
+// failures and timeouts, and the server errors reporting a temporary condition. The errors raised by the client itself,
+// such as ErrCircuitOpen or redis.ErrPoolExhausted, are not.
+func isTransientError(err error) bool {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var rerr redis.Error
+	if !errors.As(err, &rerr) {
+		return false
+	}
+	for _, prefix := range transientErrorPrefixes {
+		if strings.HasPrefix(string(rerr), prefix) {
+			return true
+		}
+	}
+	return false
+}
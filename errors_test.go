@@ -0,0 +1,70 @@
+package redis_timeseries_go
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"key not found", redis.Error("ERR TSDB: the key does not exist"), ErrKeyNotFound},
+		{"key exists", redis.Error("ERR TSDB: key already exists"), ErrKeyExists},
+		{"not a time-series", redis.Error("ERR TSDB: the key is not a TSDB key"), ErrKeyIsNotTimeSeries},
+		{"wrong type", redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), ErrKeyIsNotTimeSeries},
+		{"duplicate blocked", redis.Error("ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode"), ErrDuplicateSampleBlocked},
+		{"too old", redis.Error("ERR TSDB: Timestamp is older than retention"), ErrTimestampTooOld},
+		{"older than latest", redis.Error("ERR TSDB: Timestamp cannot be older than the latest timestamp in the time series"), ErrTimestampTooOld},
+		{"invalid filter", redis.Error("ERR TSDB: failed parsing labels"), ErrInvalidFilter},
+		{"missing matcher", redis.Error("ERR TSDB: please provide at least one matcher"), ErrInvalidFilter},
+		{"rule exists", redis.Error("ERR TSDB: the destination key already has a src rule"), ErrRuleExists},
+		{"rule not found", redis.Error("ERR TSDB: compaction rule does not exist"), ErrRuleNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(tt.err)
+			assert.True(t, errors.Is(err, tt.want))
+			assert.Equal(t, tt.err.Error(), err.Error())
+			var tsErr *TimeSeriesError
+			assert.True(t, errors.As(err, &tsErr))
+			var rerr redis.Error
+			assert.True(t, errors.As(err, &rerr))
+			assert.Equal(t, tt.err, rerr)
+		})
+	}
+}
+
+func TestClassifyError_Unknown(t *testing.T) {
+	unknown := redis.Error("ERR unknown command 'TS.ADD'")
+	assert.Equal(t, unknown, classifyError(unknown))
+	assert.Nil(t, classifyError(nil))
+	assert.Equal(t, ErrTxAborted, classifyError(ErrTxAborted))
+}
+
+func TestClient_ClassifiedErrors(t *testing.T) {
+	pool := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case MADD_CMD:
+			return []interface{}{int64(1), redis.Error("ERR TSDB: the key does not exist"), int64(3)}, nil
+		}
+		return nil, redis.Error("ERR TSDB: the key is not a TSDB key")
+	}}
+	c := &Client{Pool: pool, Name: "test"}
+
+	_, err := c.Add("a", 1, 1)
+	assert.True(t, errors.Is(err, ErrKeyIsNotTimeSeries))
+	_, err = c.Get("a")
+	assert.True(t, errors.Is(err, ErrKeyIsNotTimeSeries))
+
+	timestamps, err := c.MultiAdd(Sample{"a", DataPoint{1, 1}}, Sample{"b", DataPoint{2, 2}}, Sample{"c", DataPoint{3, 3}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), timestamps[0])
+	assert.True(t, errors.Is(timestamps[1].(error), ErrKeyNotFound))
+	assert.Equal(t, int64(3), timestamps[2])
+}
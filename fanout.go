@@ -0,0 +1,285 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Label added by the server to the series produced by GROUPBY/REDUCE, listing the reduced series
+const sourceLabel = "__source__"
+
+// fanOut issues the command concurrently on every node of the sharded pool, and returns the replies in node order
+func (client *Client) fanOut(ctx context.Context, pool ShardedConnPool, cmd string, args ...interface{}) ([]interface{}, error) {
+	if err := client.checkSupported(ctx, cmd, args); err != nil {
+		return nil, err
+	}
+	nodes, err := pool.NodesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, len(nodes))
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			errs[i] = client.retry(ctx, cmd, args, func() error {
+				conn, err := getNodeContext(ctx, pool, node)
+				if err != nil {
+					failedCommand(ctx, client.Hooks, node, "", cmd, len(args), err)
+					return err
+				}
+				replies[i], err = client.doHooked(ctx, conn, node, "", cmd, args...)
+				return err
+			})
+		}(i, node)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return replies, nil
+}
+
+func (client *Client) multiRangeSharded(ctx context.Context, pool ShardedConnPool, cmd string, args []interface{}, mrangeOptions MultiRangeOptions) (ranges []Range, err error) {
+	replies, err := client.fanOut(ctx, pool, cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+	ranges = []Range{}
+	for _, reply := range replies {
+		var nodeRanges []Range
+		nodeRanges, err = ParseRanges(reply)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, nodeRanges...)
+	}
+	if mrangeOptions.GroupBy != "" {
+		ranges, err = regroupRanges(ranges, mrangeOptions.Reduce, cmd == MREVRANGE_CMD, mrangeOptions.Count)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortRanges(ranges)
+	return ranges, nil
+}
+
+func (client *Client) multiGetSharded(ctx context.Context, pool ShardedConnPool, args []interface{}) (ranges []Range, err error) {
+	replies, err := client.fanOut(ctx, pool, MGET_CMD, args...)
+	if err != nil {
+		return nil, err
+	}
+	ranges = []Range{}
+	for _, reply := range replies {
+		var nodeRanges []Range
+		nodeRanges, err = ParseRangesSingleDataPoint(reply)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, nodeRanges...)
+	}
+	sortRanges(ranges)
+	return ranges, nil
+}
+
+func (client *Client) queryIndexSharded(ctx context.Context, pool ShardedConnPool, args []interface{}) (keys []string, err error) {
+	replies, err := client.fanOut(ctx, pool, QUERYINDEX_CMD, args...)
+	if err != nil {
+		return nil, err
+	}
+	keys = []string{}
+	for _, reply := range replies {
+		var nodeKeys []string
+		nodeKeys, err = redis.Strings(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, nodeKeys...)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// multiAddSharded splits the samples per owning node, issues one TS.MADD per node concurrently,
+// and returns the per-sample replies aligned with the samples
+func (client *Client) multiAddSharded(ctx context.Context, pool ShardedConnPool, samples []Sample) ([]interface{}, error) {
+	byNode := make(map[string][]int)
+	for i, sample := range samples {
+		node, err := pool.NodeForKeyContext(ctx, sample.Key)
+		if err != nil {
+			return nil, err
+		}
+		byNode[node] = append(byNode[node], i)
+	}
+	timestamps := make([]interface{}, len(samples))
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for node, indexes := range byNode {
+		wg.Add(1)
+		go func(node string, indexes []int) {
+			defer wg.Done()
+			nodeSamples := make([]Sample, len(indexes))
+			for j, i := range indexes {
+				nodeSamples[j] = samples[i]
+			}
+			replies, err := client.multiAddNode(ctx, pool, node, nodeSamples)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for j, i := range indexes {
+				timestamps[i] = replies[j]
+			}
+		}(node, indexes)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return timestamps, nil
+}
+
+func (client *Client) multiAddNode(ctx context.Context, pool ShardedConnPool, node string, samples []Sample) ([]interface{}, error) {
+	args := multiAddArgs(samples)
+	var replies []interface{}
+	err := client.retry(ctx, MADD_CMD, args, func() error {
+		conn, err := getNodeContext(ctx, pool, node)
+		if err != nil {
+			failedCommand(ctx, client.Hooks, node, "", MADD_CMD, len(args), err)
+			return err
+		}
+		replies, err = classifyReplies(redis.Values(client.doHooked(ctx, conn, node, "", MADD_CMD, args...)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(replies) != len(samples) {
+		return nil, fmt.Errorf("%s on %s returned %d replies for %d samples", MADD_CMD, node, len(replies), len(samples))
+	}
+	return replies, nil
+}
+
+// regroupRanges merges the GROUPBY/REDUCE groups sharing the same name, which are produced when the series
+// of a group live on different nodes. The data points of a merged group are reduced again per timestamp,
+// which is only possible for associative reducers, and limited to count as the server limits every group.
+func regroupRanges(ranges []Range, reducer ReducerType, reverse bool, count int64) ([]Range, error) {
+	groups := make([]Range, 0, len(ranges))
+	byName := make(map[string]int, len(ranges))
+	merged := make(map[string]bool)
+	for _, r := range ranges {
+		i, found := byName[r.Name]
+		if !found {
+			byName[r.Name] = len(groups)
+			groups = append(groups, r)
+			continue
+		}
+		dataPoints, err := reduceDataPoints(groups[i].DataPoints, r.DataPoints, reducer)
+		if err != nil {
+			return nil, err
+		}
+		groups[i].DataPoints = dataPoints
+		groups[i].Labels = mergeGroupLabels(groups[i].Labels, r.Labels)
+		merged[r.Name] = true
+	}
+	for i := range groups {
+		if !merged[groups[i].Name] {
+			continue
+		}
+		sortDataPoints(groups[i].DataPoints, reverse)
+		if count >= 0 && int64(len(groups[i].DataPoints)) > count {
+			groups[i].DataPoints = groups[i].DataPoints[:count]
+		}
+	}
+	return groups, nil
+}
+
+// reduceDataPoints combines two series of the same group, reducing the values sharing a timestamp
+func reduceDataPoints(a, b []DataPoint, reducer ReducerType) ([]DataPoint, error) {
+	values := make(map[int64]float64, len(a)+len(b))
+	for _, dp := range a {
+		values[dp.Timestamp] = dp.Value
+	}
+	for _, dp := range b {
+		current, found := values[dp.Timestamp]
+		if !found {
+			values[dp.Timestamp] = dp.Value
+			continue
+		}
+		value, err := reduceValues(reducer, current, dp.Value)
+		if err != nil {
+			return nil, err
+		}
+		values[dp.Timestamp] = value
+	}
+	dataPoints := make([]DataPoint, 0, len(values))
+	for timestamp, value := range values {
+		dataPoints = append(dataPoints, DataPoint{Timestamp: timestamp, Value: value})
+	}
+	return dataPoints, nil
+}
+
+// reduceValues applies reducer to two values
+func reduceValues(reducer ReducerType, a, b float64) (float64, error) {
+	switch ReducerType(strings.ToUpper(string(reducer))) {
+	case SumReducer:
+		return a + b, nil
+	case MinReducer:
+		if b < a {
+			return b, nil
+		}
+		return a, nil
+	case MaxReducer:
+		if b > a {
+			return b, nil
+		}
+		return a, nil
+	}
+	return 0, fmt.Errorf("reducer %s can not be applied client side", reducer)
+}
+
+// mergeGroupLabels merges the labels of two parts of the same group, joining their __source__ series
+func mergeGroupLabels(a, b map[string]string) map[string]string {
+	labels := make(map[string]string, len(a))
+	for name, value := range a {
+		labels[name] = value
+	}
+	if sources, found := b[sourceLabel]; found {
+		if labels[sourceLabel] != "" {
+			sources = labels[sourceLabel] + "," + sources
+		}
+		list := strings.Split(sources, ",")
+		sort.Strings(list)
+		labels[sourceLabel] = strings.Join(list, ",")
+	}
+	return labels
+}
+
+func sortDataPoints(dataPoints []DataPoint, reverse bool) {
+	sort.Slice(dataPoints, func(i, j int) bool {
+		if reverse {
+			return dataPoints[i].Timestamp > dataPoints[j].Timestamp
+		}
+		return dataPoints[i].Timestamp < dataPoints[j].Timestamp
+	})
+}
+
+func sortRanges(ranges []Range) {
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Name < ranges[j].Name
+	})
+}
@@ -0,0 +1,429 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Errors injected by a FaultInjectionPool. They are net.Error values, so that they are retried by a RetryPolicy
+// like the network failures they simulate.
+var (
+	// ErrInjectedDialFailure is returned when borrowing a connection from a FaultInjectionPool fails
+	ErrInjectedDialFailure error = injectedError{message: "injected fault: dial failed"}
+	// ErrInjectedConnectionDrop is returned when a FaultInjectionPool drops a connection before its reply was read
+	ErrInjectedConnectionDrop error = injectedError{message: "injected fault: connection dropped"}
+	// ErrInjectedTimeout is returned when the latency injected by a FaultInjectionPool exceeds the read timeout
+	ErrInjectedTimeout error = injectedError{message: "injected fault: i/o timeout", timeout: true}
+)
+
+// injectedError is a network failure injected by a FaultInjectionPool
+type injectedError struct {
+	message string
+	timeout bool
+}
+
+func (e injectedError) Error() string   { return e.message }
+func (e injectedError) Timeout() bool   { return e.timeout }
+func (e injectedError) Temporary() bool { return true }
+
+// Error replies commonly injected by a FaultInjectionPool
+var (
+	// LoadingReply is replied by a server loading its dataset, and is retried by a RetryPolicy
+	LoadingReply = redis.Error("LOADING Redis is loading the dataset in memory")
+	// OOMReply is replied by a server which reached its maxmemory
+	OOMReply = redis.Error("OOM command not allowed when used memory > 'maxmemory'.")
+	// DuplicateBlockedReply is replied to a sample rejected by the BLOCK duplicate policy, classified as ErrDuplicateSampleBlocked
+	DuplicateBlockedReply = redis.Error("ERR TSDB: Error at upsert, update is not supported in BLOCK mode")
+)
+
+// Fault is a failure injected into a command. The zero Fault lets the command through untouched.
+type Fault struct {
+	// Latency is waited before the reply is read, and counts against the read timeout of the command.
+	// When it exceeds the read timeout, the timeout is waited and the command fails with ErrInjectedTimeout.
+	Latency time.Duration
+	// Reply, when set, is replied instead of sending the command, such as LoadingReply
+	Reply redis.Error
+	// Drop sends the command, so that the server applies it, but closes the connection without reading the reply,
+	// failing with ErrInjectedConnectionDrop. The connections of the pools of this package are then discarded.
+	Drop bool
+}
+
+// ErrorReplyRate is an error reply injected at a rate
+type ErrorReplyRate struct {
+	Reply redis.Error
+	// Rate is the fraction of the commands receiving Reply, between 0 and 1
+	Rate float64
+}
+
+// FaultInjectionOptions are the faults injected by a FaultInjectionPool.
+// The faults of Script are injected first, in order, then the faults are drawn at random according to the rates,
+// from a source seeded with Seed so that the same sequence of commands always receives the same faults.
+type FaultInjectionOptions struct {
+	// Seed seeds the random source drawing the faults
+	Seed int64
+	// DialFailures is the number of connection borrows failing with ErrInjectedDialFailure before DialFailureRate applies
+	DialFailures int
+	// DialFailureRate is the fraction of the connection borrows failing with ErrInjectedDialFailure
+	DialFailureRate float64
+	// Latency is added to a fraction LatencyRate of the commands
+	Latency     time.Duration
+	LatencyRate float64
+	// ReadTimeout is the read timeout of the connections of the wrapped pool, such as ClientOptions.ReadTimeout,
+	// which the injected latency counts against when a command is issued without timeout. Zero means no timeout.
+	ReadTimeout time.Duration
+	// DropRate is the fraction of the commands whose connection is dropped before the reply is read
+	DropRate float64
+	// ErrorReplies are the error replies injected at a rate, each command receiving at most one of them
+	ErrorReplies []ErrorReplyRate
+	// Script are the faults injected into the first commands, in order, before the rates apply
+	Script []Fault
+	// Commands restricts the faults to the given commands, such as ADD_CMD, every command being subject to faults when empty
+	Commands []string
+}
+
+func NewFaultInjectionOptions() *FaultInjectionOptions {
+	return &FaultInjectionOptions{
+		Seed:            1,
+		DialFailures:    0,
+		DialFailureRate: 0,
+		Latency:         0,
+		LatencyRate:     0,
+		ReadTimeout:     0,
+		DropRate:        0,
+		ErrorReplies:    []ErrorReplyRate{},
+		Script:          []Fault{},
+		Commands:        []string{},
+	}
+}
+
+// DefaultFaultInjectionOptions inject no fault
+var DefaultFaultInjectionOptions = *NewFaultInjectionOptions()
+
+// SetSeed sets the seed of the random source drawing the faults
+func (options *FaultInjectionOptions) SetSeed(seed int64) *FaultInjectionOptions {
+	options.Seed = seed
+	return options
+}
+
+// SetDialFailures makes the first failures borrows fail, then a fraction rate of them
+func (options *FaultInjectionOptions) SetDialFailures(failures int, rate float64) *FaultInjectionOptions {
+	options.DialFailures = failures
+	options.DialFailureRate = rate
+	return options
+}
+
+// SetLatency adds latency to a fraction rate of the commands
+func (options *FaultInjectionOptions) SetLatency(latency time.Duration, rate float64) *FaultInjectionOptions {
+	options.Latency = latency
+	options.LatencyRate = rate
+	return options
+}
+
+// SetReadTimeout sets the read timeout of the connections of the wrapped pool, which the injected latency counts against
+func (options *FaultInjectionOptions) SetReadTimeout(timeout time.Duration) *FaultInjectionOptions {
+	options.ReadTimeout = timeout
+	return options
+}
+
+// SetDropRate sets the fraction of the commands whose connection is dropped before the reply is read
+func (options *FaultInjectionOptions) SetDropRate(rate float64) *FaultInjectionOptions {
+	options.DropRate = rate
+	return options
+}
+
+// AddErrorReply injects reply into a fraction rate of the commands
+func (options *FaultInjectionOptions) AddErrorReply(reply redis.Error, rate float64) *FaultInjectionOptions {
+	options.ErrorReplies = append(options.ErrorReplies, ErrorReplyRate{Reply: reply, Rate: rate})
+	return options
+}
+
+// SetScript sets the faults injected into the first commands, in order
+func (options *FaultInjectionOptions) SetScript(faults ...Fault) *FaultInjectionOptions {
+	options.Script = faults
+	return options
+}
+
+// SetCommands restricts the faults to the given commands
+func (options *FaultInjectionOptions) SetCommands(commands ...string) *FaultInjectionOptions {
+	options.Commands = commands
+	return options
+}
+
+// FaultInjectionStats are the numbers of faults injected by a FaultInjectionPool
+type FaultInjectionStats struct {
+	DialFailures uint64
+	Latencies    uint64
+	Drops        uint64
+	ErrorReplies uint64
+}
+
+// FaultInjectionPool wraps a ConnPool, such as a SingleHostPool, and injects faults into its connections,
+// to exercise the retry, circuit breaking and buffering code paths.
+// It only exposes the ConnPool interface of the pool it wraps, so sharded pools should not be wrapped.
+type FaultInjectionPool struct {
+	ConnPool
+	options FaultInjectionOptions
+	mu      sync.Mutex
+	random  *rand.Rand
+	borrows int
+	// commands is the number of commands subject to faults issued so far, indexing the script
+	commands int
+	stats    FaultInjectionStats
+}
+
+// NewFaultInjectionPool wraps pool with a FaultInjectionPool
+func NewFaultInjectionPool(pool ConnPool, options FaultInjectionOptions) *FaultInjectionPool {
+	return &FaultInjectionPool{ConnPool: pool, options: options, random: rand.New(rand.NewSource(options.Seed))}
+}
+
+func (p *FaultInjectionPool) Get() redis.Conn {
+	conn, err := p.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return conn
+}
+
+// GetContext fails with ErrInjectedDialFailure as configured, without borrowing from the wrapped pool
+func (p *FaultInjectionPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	if p.dialFails() {
+		return nil, ErrInjectedDialFailure
+	}
+	conn, err := p.ConnPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &faultConn{Conn: conn, pool: p}, nil
+}
+
+// Injected returns the numbers of faults injected so far
+func (p *FaultInjectionPool) Injected() FaultInjectionStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+func (p *FaultInjectionPool) dialFails() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.borrows++
+	if p.borrows <= p.options.DialFailures || p.draw(p.options.DialFailureRate) {
+		p.stats.DialFailures++
+		return true
+	}
+	return false
+}
+
+// draw tells whether an event of probability rate happens. The lock must be held.
+func (p *FaultInjectionPool) draw(rate float64) bool {
+	return rate > 0 && p.random.Float64() < rate
+}
+
+// fault returns the fault injected into cmd
+func (p *FaultInjectionPool) fault(cmd string) Fault {
+	if !p.subject(cmd) {
+		return Fault{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var fault Fault
+	if p.commands < len(p.options.Script) {
+		fault = p.options.Script[p.commands]
+	} else {
+		if p.draw(p.options.LatencyRate) {
+			fault.Latency = p.options.Latency
+		}
+		fault.Drop = p.draw(p.options.DropRate)
+		for _, reply := range p.options.ErrorReplies {
+			if p.draw(reply.Rate) && fault.Reply == "" {
+				fault.Reply = reply.Reply
+			}
+		}
+	}
+	p.commands++
+	if fault.Latency > 0 {
+		p.stats.Latencies++
+	}
+	if fault.Reply != "" {
+		p.stats.ErrorReplies++
+	} else if fault.Drop {
+		p.stats.Drops++
+	}
+	return fault
+}
+
+func (p *FaultInjectionPool) subject(cmd string) bool {
+	if cmd == "" {
+		return false
+	}
+	if len(p.options.Commands) == 0 {
+		return true
+	}
+	for _, subject := range p.options.Commands {
+		if strings.EqualFold(subject, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// faultConn injects the faults drawn by its pool into the commands it issues.
+// The replies of the pipelined commands are received in order, the injected ones without reading the connection.
+type faultConn struct {
+	redis.Conn
+	pool    *FaultInjectionPool
+	pending []Fault
+	// err is the injected failure which broke the connection, if any
+	err error
+}
+
+func (c *faultConn) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.Conn.Err()
+}
+
+func (c *faultConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(0, cmd, args...)
+}
+
+func (c *faultConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(timeout, cmd, args...)
+}
+
+func (c *faultConn) do(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(c.pending) > 0 {
+		if cmd != "" {
+			if err := c.Send(cmd, args...); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.Flush(); err != nil {
+			return nil, err
+		}
+		return receivePending(c, len(c.pending))
+	}
+	fault := c.pool.fault(cmd)
+	if fault.Drop && fault.Reply == "" {
+		if err := c.Conn.Send(cmd, args...); err != nil {
+			return nil, err
+		}
+		if err := c.Conn.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	timeout, err := c.wait(fault.Latency, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if fault.Reply != "" {
+		return nil, fault.Reply
+	}
+	if fault.Drop {
+		return nil, c.fail(ErrInjectedConnectionDrop)
+	}
+	if timeout > 0 {
+		return redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+	}
+	return c.Conn.Do(cmd, args...)
+}
+
+// wait waits the injected latency, and returns what remains of timeout, or of the read timeout when timeout is zero.
+// When the latency exceeds it, the timeout is waited and the connection fails with ErrInjectedTimeout.
+func (c *faultConn) wait(latency, timeout time.Duration) (time.Duration, error) {
+	if timeout <= 0 {
+		timeout = c.pool.options.ReadTimeout
+	}
+	if timeout > 0 && latency >= timeout {
+		time.Sleep(timeout)
+		return 0, c.fail(ErrInjectedTimeout)
+	}
+	time.Sleep(latency)
+	if timeout > 0 {
+		timeout -= latency
+	}
+	return timeout, nil
+}
+
+// fail fails the following operations with err. The underlying connection is closed without reading the pending replies,
+// so that its pool discards it.
+func (c *faultConn) fail(err error) error {
+	c.err = err
+	abortConn(c.Conn)
+	c.Conn.Close()
+	return err
+}
+
+func (c *faultConn) Send(cmd string, args ...interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	fault := c.pool.fault(cmd)
+	if fault.Reply == "" {
+		if err := c.Conn.Send(cmd, args...); err != nil {
+			return err
+		}
+	}
+	c.pending = append(c.pending, fault)
+	return nil
+}
+
+func (c *faultConn) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.Conn.Flush()
+}
+
+func (c *faultConn) Receive() (interface{}, error) {
+	return c.receive(0)
+}
+
+func (c *faultConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return c.receive(timeout)
+}
+
+func (c *faultConn) receive(timeout time.Duration) (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	var fault Fault
+	if len(c.pending) > 0 {
+		fault = c.pending[0]
+		c.pending = c.pending[1:]
+	}
+	timeout, err := c.wait(fault.Latency, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if fault.Reply != "" {
+		return nil, fault.Reply
+	}
+	if fault.Drop {
+		return nil, c.fail(ErrInjectedConnectionDrop)
+	}
+	if timeout > 0 {
+		return redis.ReceiveWithTimeout(c.Conn, timeout)
+	}
+	return c.Conn.Receive()
+}
+
+func (c *faultConn) abort() {
+	abortConn(c.Conn)
+}
+
+func (c *faultConn) Close() error {
+	if c.err != nil {
+		return nil
+	}
+	return c.Conn.Close()
+}
@@ -0,0 +1,208 @@
+package redis_timeseries_go
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/RedisTimeSeries/redistimeseries-go/tstest"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// faultyClient returns a client of server issuing its commands through a FaultInjectionPool, retrying 3 times
+func faultyClient(server *tstest.Server, options *FaultInjectionOptions) (*Client, *FaultInjectionPool) {
+	pool := NewFaultInjectionPool(NewSingleHostPool(server.Addr(), nil), *options)
+	client := NewClientFromConnPool(pool, "faulty")
+	client.RetryPolicy = NewRetryPolicy().SetBackoff(time.Millisecond, time.Millisecond, 0)
+	return client, pool
+}
+
+func TestFaultInjectionPool_Script(t *testing.T) {
+	server := tstest.NewServer()
+	defer server.Close()
+	tests := []struct {
+		name         string
+		options      *FaultInjectionOptions
+		wantErr      error
+		wantInjected FaultInjectionStats
+	}{
+		{"retried loading and drop",
+			NewFaultInjectionOptions().SetScript(Fault{Reply: LoadingReply}, Fault{Drop: true}), nil,
+			FaultInjectionStats{ErrorReplies: 1, Drops: 1}},
+		{"dial failures", NewFaultInjectionOptions().SetDialFailures(2, 0), nil, FaultInjectionStats{DialFailures: 2}},
+		{"attempts exhausted",
+			NewFaultInjectionOptions().SetScript(Fault{Drop: true}, Fault{Drop: true}, Fault{Drop: true}),
+			ErrInjectedConnectionDrop, FaultInjectionStats{Drops: 3}},
+		{"not retried", NewFaultInjectionOptions().SetScript(Fault{Reply: OOMReply}), OOMReply,
+			FaultInjectionStats{ErrorReplies: 1}},
+		{"latency", NewFaultInjectionOptions().SetScript(Fault{Latency: 10 * time.Millisecond}), nil,
+			FaultInjectionStats{Latencies: 1}},
+		{"other commands", NewFaultInjectionOptions().SetScript(Fault{Reply: OOMReply}).SetCommands(ADD_CMD), nil,
+			FaultInjectionStats{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.FlushAll()
+			_, err := NewClient(server.Addr(), "setup", nil).Add("series", 1, 1)
+			assert.NoError(t, err)
+
+			client, pool := faultyClient(server, tt.options)
+			start := time.Now()
+			point, err := client.Get("series")
+			assert.Equal(t, tt.wantErr, err)
+			if tt.wantErr == nil {
+				assert.Equal(t, &DataPoint{1, 1}, point)
+			}
+			assert.True(t, time.Since(start) >= time.Duration(tt.wantInjected.Latencies)*10*time.Millisecond)
+			assert.Equal(t, tt.wantInjected, pool.Injected())
+			pool.Close()
+		})
+	}
+}
+
+func TestFaultInjectionPool_Drop(t *testing.T) {
+	server := tstest.NewServer()
+	defer server.Close()
+	client, pool := faultyClient(server, NewFaultInjectionOptions().SetScript(Fault{}, Fault{Drop: true}, Fault{Reply: DuplicateBlockedReply}))
+	defer pool.Close()
+
+	_, err := client.Add("series", 1, 1)
+	assert.NoError(t, err)
+	_, err = client.Add("series", 2, 2)
+	assert.Equal(t, ErrInjectedConnectionDrop, err, "non-idempotent command not retried")
+	_, err = client.Add("series", 3, 3)
+	assert.True(t, errors.Is(err, ErrDuplicateSampleBlocked))
+
+	// the dropped command is applied once the server reads it from the closed connection
+	waitFor(t, func() bool {
+		points, err := client.Range("series", 0, 10)
+		return err == nil && len(points) == 2
+	})
+	points, err := client.Range("series", 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []DataPoint{{1, 1}, {2, 2}}, points, "dropped command applied, error reply not sent")
+}
+
+func TestFaultInjectionPool_DropDiscards(t *testing.T) {
+	received, release := make(chan struct{}, 1), make(chan struct{})
+	s := newRespServer(t, func(args []string) interface{} {
+		received <- struct{}{}
+		<-release
+		return respStatus("OK")
+	})
+	defer s.close()
+	defer close(release)
+	wrapped := NewSingleHostPoolWithOptions(s.addr(), *NewClientOptions().SetTestOnBorrowInterval(0))
+	pool := NewFaultInjectionPool(wrapped, *NewFaultInjectionOptions().SetScript(Fault{Drop: true}))
+	defer pool.Close()
+
+	conn := pool.Get()
+	_, err := conn.Do("SET", "key", "value")
+	assert.Equal(t, ErrInjectedConnectionDrop, err, "reply not awaited")
+	<-received
+	conn.Close()
+	stats := wrapped.Stats()
+	assert.Equal(t, 0, stats.ActiveCount)
+	assert.Equal(t, 0, stats.IdleCount, "dropped connection discarded")
+}
+
+func TestFaultInjectionPool_LatencyTimeout(t *testing.T) {
+	s := newRespServer(t, pongHandler)
+	defer s.close()
+	pool := func(options *FaultInjectionOptions) *FaultInjectionPool {
+		return NewFaultInjectionPool(NewSingleHostPool(s.addr(), nil), *options)
+	}
+	latency := NewFaultInjectionOptions().SetLatency(50*time.Millisecond, 1)
+	tests := []struct {
+		name    string
+		pool    *FaultInjectionPool
+		do      func(conn redis.Conn) (interface{}, error)
+		wantErr error
+		waited  time.Duration
+	}{
+		{"within timeout", pool(latency), func(conn redis.Conn) (interface{}, error) {
+			return redis.DoWithTimeout(conn, time.Second, "PING")
+		}, nil, 50 * time.Millisecond},
+		{"exceeding timeout", pool(latency), func(conn redis.Conn) (interface{}, error) {
+			return redis.DoWithTimeout(conn, 20*time.Millisecond, "PING")
+		}, ErrInjectedTimeout, 20 * time.Millisecond},
+		{"exceeding read timeout", pool(NewFaultInjectionOptions().SetLatency(50*time.Millisecond, 1).SetReadTimeout(20 * time.Millisecond)),
+			func(conn redis.Conn) (interface{}, error) {
+				return conn.Do("PING")
+			}, ErrInjectedTimeout, 20 * time.Millisecond},
+		{"pipelined exceeding timeout", pool(latency), func(conn redis.Conn) (interface{}, error) {
+			assert.NoError(t, conn.Send("PING"))
+			assert.NoError(t, conn.Flush())
+			return redis.ReceiveWithTimeout(conn, 20*time.Millisecond)
+		}, ErrInjectedTimeout, 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.pool.Close()
+			conn := tt.pool.Get()
+			defer conn.Close()
+			start := time.Now()
+			_, err := tt.do(conn)
+			elapsed := time.Since(start)
+			assert.Equal(t, tt.wantErr, err)
+			assert.True(t, elapsed >= tt.waited && elapsed < tt.waited+30*time.Millisecond, "waited %v", elapsed)
+			if tt.wantErr != nil {
+				var netErr net.Error
+				assert.True(t, errors.As(err, &netErr) && netErr.Timeout())
+				assert.Equal(t, ErrInjectedTimeout, conn.Err())
+			}
+		})
+	}
+}
+
+func TestFaultInjectionPool_Pipeline(t *testing.T) {
+	server := tstest.NewServer()
+	defer server.Close()
+	client, pool := faultyClient(server, NewFaultInjectionOptions().SetScript(Fault{}, Fault{Reply: LoadingReply}, Fault{}, Fault{Drop: true}))
+	defer pool.Close()
+
+	pipeline := client.Pipeline()
+	pipeline.Add("a", 1, 1)
+	pipeline.Add("b", 1, 2)
+	pipeline.Add("c", 1, 3)
+	results, err := pipeline.Exec()
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, LoadingReply, results[1].Err)
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, []string{"a", "c"}, server.Keys())
+
+	pipeline = client.Pipeline()
+	pipeline.Add("d", 1, 1)
+	pipeline.Add("e", 1, 1)
+	_, err = pipeline.Exec()
+	assert.Equal(t, ErrInjectedConnectionDrop, err)
+	waitFor(t, func() bool { return len(server.Keys()) == 4 })
+	assert.Equal(t, []string{"a", "c", "d", "e"}, server.Keys())
+}
+
+func TestFaultInjectionPool_Seed(t *testing.T) {
+	outcomes := func(seed int64) []error {
+		options := NewFaultInjectionOptions().SetSeed(seed).SetDialFailures(0, 0.1).SetDropRate(0.1).
+			AddErrorReply(LoadingReply, 0.1).AddErrorReply(OOMReply, 0.1)
+		pool := NewFaultInjectionPool(&stubPool{func(cmd string, args ...interface{}) (interface{}, error) {
+			return "OK", nil
+		}}, *options)
+		errs := make([]error, 1000)
+		for i := range errs {
+			conn := pool.Get()
+			_, errs[i] = conn.Do("PING")
+			conn.Close()
+		}
+		injected := pool.Injected()
+		assert.InDelta(t, 100, injected.DialFailures, 40)
+		assert.InDelta(t, 170, injected.ErrorReplies, 60)
+		assert.InDelta(t, 70, injected.Drops, 40)
+		return errs
+	}
+	first := outcomes(42)
+	assert.Equal(t, first, outcomes(42))
+	assert.NotEqual(t, first, outcomes(7))
+}
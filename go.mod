@@ -1,6 +1,6 @@
 module github.com/RedisTimeSeries/redistimeseries-go
 
-go 1.12
+go 1.13
 
 require (
 	github.com/gomodule/redigo v1.8.2
@@ -0,0 +1,160 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency histograms recorded by MetricsHook
+var DefaultLatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// DefaultSizeBuckets are the upper bounds, in bytes, of the size histograms recorded by MetricsHook
+var DefaultSizeBuckets = []float64{16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+// DefaultCountBuckets are the upper bounds of the argument count histograms recorded by MetricsHook
+var DefaultCountBuckets = []float64{1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 4096, 16384}
+
+// Histogram counts the observed values into buckets with fixed upper bounds, and a last unbounded bucket
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates a histogram with buckets bounded by the given upper bounds, sorted in increasing order
+func NewHistogram(bounds []float64) *Histogram {
+	bounds = append([]float64{}, bounds...)
+	sort.Float64s(bounds)
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe counts value into the first bucket whose upper bound is not lower than value
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.bounds, value)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += value
+}
+
+// Snapshot returns a copy of the counts of the histogram
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: append([]uint64{}, h.counts...),
+		Count:  h.count,
+		Sum:    h.sum,
+	}
+}
+
+// HistogramSnapshot are the counts of a histogram at a point in time.
+// Counts has one more element than Bounds, counting the values above the last bound.
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+// Mean returns the average of the observed values, zero when there is none
+func (s HistogramSnapshot) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Quantile returns the upper bound of the bucket holding the q quantile, with q between 0 and 1.
+// It is +Inf when the quantile falls in the last unbounded bucket, and zero when no value was observed.
+func (s HistogramSnapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(s.Count)))
+	if rank == 0 {
+		rank = 1
+	}
+	var cumulative uint64
+	for i, count := range s.Counts {
+		cumulative += count
+		if cumulative >= rank && i < len(s.Bounds) {
+			return s.Bounds[i]
+		}
+	}
+	return math.Inf(1)
+}
+
+// HistogramRegistry holds histograms by name, creating them on first use
+type HistogramRegistry struct {
+	mu         sync.Mutex
+	histograms map[string]*Histogram
+}
+
+func NewHistogramRegistry() *HistogramRegistry {
+	return &HistogramRegistry{histograms: map[string]*Histogram{}}
+}
+
+// Histogram returns the histogram registered under name, creating it with the given bounds when it does not exist
+func (r *HistogramRegistry) Histogram(name string, bounds []float64) *Histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, found := r.histograms[name]
+	if !found {
+		h = NewHistogram(bounds)
+		r.histograms[name] = h
+	}
+	return h
+}
+
+// Snapshot returns the snapshots of every registered histogram, by name
+func (r *HistogramRegistry) Snapshot() map[string]HistogramSnapshot {
+	r.mu.Lock()
+	histograms := make(map[string]*Histogram, len(r.histograms))
+	for name, h := range r.histograms {
+		histograms[name] = h
+	}
+	r.mu.Unlock()
+	snapshots := make(map[string]HistogramSnapshot, len(histograms))
+	for name, h := range histograms {
+		snapshots[name] = h.Snapshot()
+	}
+	return snapshots
+}
+
+// MetricsHook is a Hook recording the commands into the histograms of a registry, named after each command:
+// "<command>.duration_seconds" for the latency, "<command>.reply_bytes" for the reply sizes, "<command>.args" for
+// the argument counts, and "<command>.errors" for the latency of the failed commands, whose Count is the number of errors.
+type MetricsHook struct {
+	registry *HistogramRegistry
+}
+
+// NewMetricsHook creates a MetricsHook recording into registry
+func NewMetricsHook(registry *HistogramRegistry) *MetricsHook {
+	return &MetricsHook{registry: registry}
+}
+
+// Registry returns the registry the hook records into
+func (h *MetricsHook) Registry() *HistogramRegistry {
+	return h.registry
+}
+
+func (h *MetricsHook) BeforeCommand(ctx context.Context, event *CommandEvent) context.Context {
+	return ctx
+}
+
+func (h *MetricsHook) AfterCommand(ctx context.Context, event *CommandEvent) {
+	seconds := event.Duration.Seconds()
+	h.registry.Histogram(event.Command+".duration_seconds", DefaultLatencyBuckets).Observe(seconds)
+	h.registry.Histogram(event.Command+".reply_bytes", DefaultSizeBuckets).Observe(float64(event.ReplySize))
+	h.registry.Histogram(event.Command+".args", DefaultCountBuckets).Observe(float64(event.ArgCount))
+	if event.Err != nil {
+		h.registry.Histogram(event.Command+".errors", DefaultLatencyBuckets).Observe(seconds)
+	}
+}
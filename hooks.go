@@ -0,0 +1,127 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// CommandEvent describes a command issued by a Client, as seen by its hooks
+type CommandEvent struct {
+	Command string
+	// Key is the key the command acts on, empty for the multi-key commands
+	Key string
+	// Node is the node of a ShardedConnPool a fanned out or split command is sent to, empty otherwise
+	Node     string
+	ArgCount int
+	// Duration, Err and ReplySize are set once the command completed. ReplySize is the approximate size in bytes of the reply.
+	Duration  time.Duration
+	Err       error
+	ReplySize int
+}
+
+// Hook is invoked around every command a Client issues with Do, including each retry, and around every command
+// a Pipeline or a Tx sends. As the latter are sent in a single round trip, BeforeCommand is called for each of them
+// before the round trip, and AfterCommand as each reply is read, the Duration spanning the round trip up to that reply.
+// MULTI and EXEC are not hooked, the outcome of the transaction being reported to the hooks of each queued command.
+// When no connection can be borrowed to send a command, its hooks are still invoked, AfterCommand reporting the error.
+type Hook interface {
+	// BeforeCommand is called before the command is sent, and returns the context passed to AfterCommand
+	BeforeCommand(ctx context.Context, event *CommandEvent) context.Context
+	// AfterCommand is called once the command completed, with Duration, Err and ReplySize set
+	AfterCommand(ctx context.Context, event *CommandEvent)
+}
+
+// HookFuncs adapts a pair of functions to a Hook, either of which may be nil
+type HookFuncs struct {
+	Before func(ctx context.Context, event *CommandEvent) context.Context
+	After  func(ctx context.Context, event *CommandEvent)
+}
+
+func (h HookFuncs) BeforeCommand(ctx context.Context, event *CommandEvent) context.Context {
+	if h.Before == nil {
+		return ctx
+	}
+	return h.Before(ctx, event)
+}
+
+func (h HookFuncs) AfterCommand(ctx context.Context, event *CommandEvent) {
+	if h.After != nil {
+		h.After(ctx, event)
+	}
+}
+
+// doHooked issues the command on conn with doContext, invoking the hooks of the client around it.
+// The error is classified before the hooks see it.
+func (client *Client) doHooked(ctx context.Context, conn redis.Conn, node, key, cmd string, args ...interface{}) (interface{}, error) {
+	hooked := beforeCommand(ctx, client.Hooks, node, key, cmd, len(args))
+	reply, err := doContext(ctx, conn, cmd, args...)
+	err = classifyError(err)
+	hooked.after(reply, err)
+	return reply, err
+}
+
+// failedCommand invokes the hooks of a command which could not be sent, borrowing a connection for it failed with err
+func failedCommand(ctx context.Context, hooks []Hook, node, key, cmd string, argCount int, err error) {
+	beforeCommand(ctx, hooks, node, key, cmd, argCount).after(nil, err)
+}
+
+// hookedCommand is a command whose hooks were invoked before it was sent, awaiting their AfterCommand
+type hookedCommand struct {
+	hooks    []Hook
+	event    CommandEvent
+	contexts []context.Context
+	start    time.Time
+	done     bool
+}
+
+// beforeCommand invokes the BeforeCommand of hooks for a command, returning nil when there is no hook
+func beforeCommand(ctx context.Context, hooks []Hook, node, key, cmd string, argCount int) *hookedCommand {
+	if len(hooks) == 0 {
+		return nil
+	}
+	c := &hookedCommand{hooks: hooks, event: CommandEvent{Command: cmd, Key: key, Node: node, ArgCount: argCount},
+		contexts: make([]context.Context, len(hooks))}
+	hookCtx := ctx
+	for i, hook := range hooks {
+		hookCtx = hook.BeforeCommand(hookCtx, &c.event)
+		c.contexts[i] = hookCtx
+	}
+	c.start = time.Now()
+	return c
+}
+
+// after invokes the AfterCommand of the hooks, in reverse order, once the command completed with reply and the
+// classified err. It does nothing on a nil command, or once called.
+func (c *hookedCommand) after(reply interface{}, err error) {
+	if c == nil || c.done {
+		return
+	}
+	c.done = true
+	c.event.Duration, c.event.Err, c.event.ReplySize = time.Since(c.start), err, replySize(reply)
+	for i := len(c.hooks) - 1; i >= 0; i-- {
+		c.hooks[i].AfterCommand(c.contexts[i], &c.event)
+	}
+}
+
+// replySize returns the approximate size in bytes of the payload of a reply
+func replySize(reply interface{}) int {
+	switch reply := reply.(type) {
+	case []byte:
+		return len(reply)
+	case string:
+		return len(reply)
+	case redis.Error:
+		return len(reply)
+	case int64:
+		return 8
+	case []interface{}:
+		size := 0
+		for _, element := range reply {
+			size += replySize(element)
+		}
+		return size
+	}
+	return 0
+}
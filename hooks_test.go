@@ -0,0 +1,218 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func hookedClient(hooks ...Hook) *Client {
+	return &Client{
+		Pool: &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+			if cmd == GET_CMD {
+				return nil, redis.Error("ERR TSDB: the key does not exist")
+			}
+			return []interface{}{[]byte("a"), []byte("bb")}, nil
+		}},
+		Name:  "test",
+		Hooks: hooks,
+	}
+}
+
+type hookTestKey string
+
+func TestClient_Hooks(t *testing.T) {
+	var calls []string
+	var events []CommandEvent
+	hook := func(name string) Hook {
+		return HookFuncs{
+			Before: func(ctx context.Context, event *CommandEvent) context.Context {
+				calls = append(calls, "before "+name)
+				return context.WithValue(ctx, hookTestKey(name), name)
+			},
+			After: func(ctx context.Context, event *CommandEvent) {
+				calls = append(calls, "after "+name+" "+ctx.Value(hookTestKey(name)).(string))
+				if name == "outer" {
+					events = append(events, *event)
+				}
+			},
+		}
+	}
+	c := hookedClient(hook("outer"), hook("inner"))
+
+	keys, err := c.QueryIndex("a=1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "bb"}, keys)
+	_, err = c.Get("missing")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	assert.Equal(t, []string{
+		"before outer", "before inner", "after inner inner", "after outer outer",
+		"before outer", "before inner", "after inner inner", "after outer outer",
+	}, calls)
+	assert.Len(t, events, 2)
+	assert.Equal(t, QUERYINDEX_CMD, events[0].Command)
+	assert.Equal(t, "", events[0].Key)
+	assert.Equal(t, 1, events[0].ArgCount)
+	assert.Equal(t, 3, events[0].ReplySize)
+	assert.Nil(t, events[0].Err)
+	assert.True(t, events[0].Duration > 0)
+	assert.Equal(t, GET_CMD, events[1].Command)
+	assert.Equal(t, "missing", events[1].Key)
+	assert.True(t, errors.Is(events[1].Err, ErrKeyNotFound))
+}
+
+func TestClient_Hooks_Queued(t *testing.T) {
+	ruleErr := redis.Error("ERR TSDB: the destination key already has a rule")
+	tests := []struct {
+		name       string
+		handler    func(cmd string, args ...interface{}) (interface{}, error)
+		exec       func(c *Client) error
+		wantEvents []string
+	}{
+		{"pipeline", hookedClient().Pool.(*stubPool).handler, func(c *Client) error {
+			p := c.Pipeline()
+			p.QueryIndex("a=1")
+			p.Get("missing")
+			_, err := p.Exec()
+			return err
+		}, []string{QUERYINDEX_CMD + " <nil>", GET_CMD + " ERR TSDB: the key does not exist"}},
+		{"transaction", txHandler([]interface{}{"OK", ruleErr}, nil), func(c *Client) error {
+			tx, err := c.Tx()
+			assert.Nil(t, err)
+			tx.CreateKeyWithOptions("dest", DefaultCreateOptions)
+			tx.CreateRule("source", AvgAggregation, 60, "dest")
+			_, err = tx.Exec()
+			return err
+		}, []string{CREATE_CMD + " <nil>", CREATERULE_CMD + " " + string(ruleErr)}},
+		{"aborted transaction", txHandler(nil, nil), func(c *Client) error {
+			tx, err := c.Tx()
+			assert.Nil(t, err)
+			tx.Add("source", 10, 1)
+			_, err = tx.Exec()
+			return err
+		}, []string{ADD_CMD + " " + ErrTxAborted.Error()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := 0
+			var events []string
+			c := &Client{Pool: &stubPool{handler: tt.handler}, Name: "test", Hooks: []Hook{HookFuncs{
+				Before: func(ctx context.Context, event *CommandEvent) context.Context {
+					before++
+					return ctx
+				},
+				After: func(ctx context.Context, event *CommandEvent) {
+					message := "<nil>"
+					if event.Err != nil {
+						message = event.Err.Error()
+					}
+					events = append(events, event.Command+" "+message)
+				},
+			}}}
+			tt.exec(c) //nolint:errcheck
+			assert.Equal(t, tt.wantEvents, events)
+			assert.Equal(t, len(tt.wantEvents), before)
+		})
+	}
+}
+
+// exhaustedPool is a pool from which no connection can be borrowed
+type exhaustedPool struct {
+	stubPool
+}
+
+func (p *exhaustedPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	return nil, redis.ErrPoolExhausted
+}
+
+func TestClient_Hooks_BorrowFailure(t *testing.T) {
+	var events []CommandEvent
+	c := &Client{Pool: &exhaustedPool{}, Name: "test", Hooks: []Hook{HookFuncs{
+		After: func(ctx context.Context, event *CommandEvent) {
+			events = append(events, *event)
+		},
+	}}}
+	_, err := c.Get("a")
+	assert.Equal(t, redis.ErrPoolExhausted, err)
+	p := c.Pipeline()
+	p.QueryIndex("a=1")
+	p.Add("a", 1, 1)
+	_, err = p.Exec()
+	assert.Equal(t, redis.ErrPoolExhausted, err)
+
+	assert.Len(t, events, 3)
+	for i, command := range []string{GET_CMD, QUERYINDEX_CMD, ADD_CMD} {
+		assert.Equal(t, command, events[i].Command)
+		assert.Equal(t, redis.ErrPoolExhausted, events[i].Err)
+		assert.Equal(t, 0, events[i].ReplySize)
+	}
+	assert.Equal(t, "a", events[0].Key)
+}
+
+func TestMetricsHook(t *testing.T) {
+	registry := NewHistogramRegistry()
+	c := hookedClient(NewMetricsHook(registry))
+	for n := 0; n < 3; n++ {
+		c.QueryIndex("a=1") //nolint:errcheck
+	}
+	c.Get("missing") //nolint:errcheck
+
+	snapshots := registry.Snapshot()
+	assert.Equal(t, uint64(3), snapshots[QUERYINDEX_CMD+".duration_seconds"].Count)
+	assert.Equal(t, float64(9), snapshots[QUERYINDEX_CMD+".reply_bytes"].Sum)
+	assert.Equal(t, float64(3), snapshots[QUERYINDEX_CMD+".args"].Sum)
+	assert.Equal(t, DefaultCountBuckets, snapshots[QUERYINDEX_CMD+".args"].Bounds)
+	assert.NotContains(t, snapshots, QUERYINDEX_CMD+".errors")
+	assert.Equal(t, uint64(1), snapshots[GET_CMD+".errors"].Count)
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{10, 1, 100})
+	for _, value := range []float64{0.5, 1, 5, 5, 50, 500} {
+		h.Observe(value)
+	}
+	s := h.Snapshot()
+	assert.Equal(t, []float64{1, 10, 100}, s.Bounds)
+	assert.Equal(t, []uint64{2, 2, 1, 1}, s.Counts)
+	assert.Equal(t, uint64(6), s.Count)
+	assert.Equal(t, 561.5/6, s.Mean())
+	assert.Equal(t, float64(1), s.Quantile(0))
+	assert.Equal(t, float64(10), s.Quantile(0.5))
+	assert.Equal(t, float64(100), s.Quantile(0.8))
+	assert.True(t, math.IsInf(s.Quantile(1), 1))
+	assert.Equal(t, float64(0), HistogramSnapshot{}.Quantile(0.5))
+}
+
+func TestTracingHook(t *testing.T) {
+	tracer := NewRecordingTracer()
+	c := hookedClient(NewTracingHook(tracer))
+	ctx, parent := tracer.Start(context.Background(), "request")
+	_, err := c.GetCtx(ctx, "missing")
+	assert.NotNil(t, err)
+	_, err = c.QueryIndexCtx(ctx, "a=1")
+	assert.Nil(t, err)
+	parent.End()
+
+	spans := tracer.Spans()
+	assert.Len(t, spans, 3)
+	assert.Equal(t, GET_CMD, spans[0].Name)
+	assert.Equal(t, "request", spans[0].Parent)
+	assert.Equal(t, map[string]interface{}{
+		DbSystemAttribute:     "redis",
+		DbOperationAttribute:  GET_CMD,
+		DbRedisKeyAttribute:   "missing",
+		DbRedisArgsAttribute:  1,
+		DbRedisReplyAttribute: 0,
+	}, spans[0].Attributes)
+	assert.Len(t, spans[0].Errors, 1)
+	assert.Equal(t, QUERYINDEX_CMD, spans[1].Name)
+	assert.Empty(t, spans[1].Errors)
+	assert.Equal(t, 3, spans[1].Attributes[DbRedisReplyAttribute])
+	assert.Equal(t, "request", spans[2].Name)
+	assert.Equal(t, "", spans[2].Parent)
+}
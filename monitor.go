@@ -0,0 +1,289 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// MonitorOptions are the options of a Monitor
+type MonitorOptions struct {
+	// Interval is the period of the reports
+	Interval time.Duration
+	// KeyPrefix prefixes the keys of the series written by the Monitor
+	KeyPrefix string
+	// Quantiles are the latency quantiles reported for each command, between 0 and 1
+	Quantiles []float64
+	// Labels are added to every series written by the Monitor, such as the name of the application
+	Labels map[string]string
+	// RetentionMSecs is the retention of the series created by the Monitor, zero keeping the samples forever
+	RetentionMSecs time.Duration
+	// ErrorHandler, when set, is called with the errors of the reports written in the background
+	ErrorHandler func(err error)
+}
+
+func NewMonitorOptions() *MonitorOptions {
+	return &MonitorOptions{
+		Interval:       10 * time.Second,
+		KeyPrefix:      "redistimeseries_client",
+		Quantiles:      []float64{0.5, 0.9, 0.99},
+		Labels:         map[string]string{},
+		RetentionMSecs: 0,
+		ErrorHandler:   nil,
+	}
+}
+
+// DefaultMonitorOptions report the median, 90th and 99th latency percentiles every 10 seconds
+var DefaultMonitorOptions = *NewMonitorOptions()
+
+// SetInterval sets the period of the reports
+func (options *MonitorOptions) SetInterval(interval time.Duration) *MonitorOptions {
+	options.Interval = interval
+	return options
+}
+
+// SetKeyPrefix sets the prefix of the keys of the series written by the Monitor
+func (options *MonitorOptions) SetKeyPrefix(keyPrefix string) *MonitorOptions {
+	options.KeyPrefix = keyPrefix
+	return options
+}
+
+// SetQuantiles sets the latency quantiles reported for each command
+func (options *MonitorOptions) SetQuantiles(quantiles ...float64) *MonitorOptions {
+	options.Quantiles = quantiles
+	return options
+}
+
+// SetLabels sets the labels added to every series written by the Monitor
+func (options *MonitorOptions) SetLabels(labels map[string]string) *MonitorOptions {
+	options.Labels = labels
+	return options
+}
+
+// SetRetention sets the retention of the series created by the Monitor
+func (options *MonitorOptions) SetRetention(retention time.Duration) *MonitorOptions {
+	options.RetentionMSecs = retention
+	return options
+}
+
+// SetErrorHandler sets the function called with the errors of the reports written in the background
+func (options *MonitorOptions) SetErrorHandler(errorHandler func(err error)) *MonitorOptions {
+	options.ErrorHandler = errorHandler
+	return options
+}
+
+// Monitor periodically writes the health of a Client into its own time-series, with MultiAdd.
+// Each report covers the interval since the previous one. For each command recorded by a MetricsHook of the client into
+// its registry, it writes the latency percentiles into <prefix>:command:<command>:latency_ms:p<percentile>, the number
+// of commands into <prefix>:command:<command>:calls, and the fraction which failed into <prefix>:command:<command>:error_rate.
+// The open connections of the pool of the client, idle or in use as in redis.PoolStats.ActiveCount, and its idle ones
+// are written into <prefix>:pool:<host>:active and idle for a StatsConnPool, or <prefix>:pool:active and idle for
+// a redis.Pool, and the queue depth and dropped samples of the watched
+// BufferedWriters into <prefix>:writer:<name>:pending and dropped.
+// The series are created on first write, labeled with metric, and with command, quantile, host and writer
+// where they apply, along with MonitorOptions.Labels. Latencies falling above the largest bucket are not reported.
+type Monitor struct {
+	mu       sync.Mutex
+	client   *Client
+	registry *HistogramRegistry
+	options  MonitorOptions
+	writers  map[string]*BufferedWriter
+	// previous are the snapshots of the last report, the reported values covering the interval since
+	previous map[string]HistogramSnapshot
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewMonitor creates a Monitor writing into client the metrics recorded into registry, which is usually the one
+// of a MetricsHook of the client. The reports are written once Start is called.
+func NewMonitor(client *Client, registry *HistogramRegistry, options MonitorOptions) *Monitor {
+	return &Monitor{
+		client:   client,
+		registry: registry,
+		options:  options,
+		writers:  map[string]*BufferedWriter{},
+		previous: map[string]HistogramSnapshot{},
+	}
+}
+
+// WatchBufferedWriter adds the queue depth and dropped samples of writer to the reports, labeled with name
+func (m *Monitor) WatchBufferedWriter(name string, writer *BufferedWriter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writers[name] = writer
+}
+
+// Start writes a report each Interval in the background, until Close is called
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop, m.done = make(chan struct{}), make(chan struct{})
+	go m.run(m.stop, m.done)
+}
+
+// Close stops the background reports, waiting for the one in progress
+func (m *Monitor) Close() {
+	m.mu.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (m *Monitor) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(m.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), m.options.Interval)
+			err := m.Report(ctx)
+			cancel()
+			if err != nil && m.options.ErrorHandler != nil {
+				m.options.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// Report writes a report straight away, covering the interval since the previous one
+func (m *Monitor) Report(ctx context.Context) error {
+	samples := m.collect(time.Now())
+	if len(samples) == 0 {
+		return nil
+	}
+	results, err := m.client.MultiAddWithOptionsCtx(ctx, samples...)
+	if err != nil {
+		return err
+	}
+	if failed := results.Failed(); len(failed) > 0 {
+		return fmt.Errorf("failed writing %d monitoring samples, first error: %v", len(failed), failed[0].Err)
+	}
+	return nil
+}
+
+// collect returns the samples of a report taken at now
+func (m *Monitor) collect(now time.Time) []MultiAddSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	samples := []MultiAddSample{}
+	add := func(key string, value float64, labels ...string) {
+		options := CreateOptions{RetentionMSecs: m.options.RetentionMSecs, Labels: map[string]string{}}
+		for name, value := range m.options.Labels {
+			options.Labels[name] = value
+		}
+		for i := 0; i+1 < len(labels); i += 2 {
+			options.Labels[labels[i]] = labels[i+1]
+		}
+		samples = append(samples, MultiAddSample{
+			Sample:        Sample{Key: m.options.KeyPrefix + ":" + key, DataPoint: DataPoint{Timestamp: timestamp, Value: value}},
+			CreateOptions: &options,
+		})
+	}
+
+	snapshots := m.registry.Snapshot()
+	commands := []string{}
+	for name := range snapshots {
+		if strings.HasSuffix(name, ".duration_seconds") {
+			commands = append(commands, strings.TrimSuffix(name, ".duration_seconds"))
+		}
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		latency := m.interval(command+".duration_seconds", snapshots)
+		failures := m.interval(command+".errors", snapshots)
+		prefix := "command:" + command + ":"
+		add(prefix+"calls", float64(latency.Count), "metric", "calls", "command", command)
+		errorRate := 0.0
+		if latency.Count > 0 {
+			errorRate = float64(failures.Count) / float64(latency.Count)
+		}
+		add(prefix+"error_rate", errorRate, "metric", "error_rate", "command", command)
+		if latency.Count == 0 {
+			continue
+		}
+		for _, q := range m.options.Quantiles {
+			if value := latency.Quantile(q); !math.IsInf(value, 1) {
+				percentile := strconv.FormatFloat(math.Round(q*100000)/1000, 'f', -1, 64)
+				add(prefix+"latency_ms:p"+percentile, value*1000,
+					"metric", "latency_ms", "command", command, "quantile", strconv.FormatFloat(q, 'f', -1, 64))
+			}
+		}
+	}
+
+	stats := poolStats(m.client.Pool)
+	hosts := make([]string, 0, len(stats))
+	for host := range stats {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		key, labels := "pool:", []string{}
+		if host != "" {
+			key, labels = "pool:"+host+":", []string{"host", host}
+		}
+		add(key+"active", float64(stats[host].ActiveCount), append([]string{"metric", "pool_active"}, labels...)...)
+		add(key+"idle", float64(stats[host].IdleCount), append([]string{"metric", "pool_idle"}, labels...)...)
+	}
+
+	names := make([]string, 0, len(m.writers))
+	for name := range m.writers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writerStats := m.writers[name].Stats()
+		add("writer:"+name+":pending", float64(writerStats.Pending), "metric", "writer_pending", "writer", name)
+		add("writer:"+name+":dropped", float64(writerStats.Dropped), "metric", "writer_dropped", "writer", name)
+	}
+	return samples
+}
+
+// interval returns the snapshot of the histogram name, minus the one of the previous report
+func (m *Monitor) interval(name string, snapshots map[string]HistogramSnapshot) HistogramSnapshot {
+	current := snapshots[name]
+	previous, found := m.previous[name]
+	m.previous[name] = current
+	if !found || len(previous.Counts) != len(current.Counts) {
+		return current
+	}
+	delta := HistogramSnapshot{
+		Bounds: current.Bounds,
+		Counts: make([]uint64, len(current.Counts)),
+		Count:  current.Count - previous.Count,
+		Sum:    current.Sum - previous.Sum,
+	}
+	for i := range current.Counts {
+		delta.Counts[i] = current.Counts[i] - previous.Counts[i]
+	}
+	return delta
+}
+
+// poolStats returns the statistics of the connection pools of pool, by host address.
+// The host is empty for a redis.Pool, which does not know its address.
+func poolStats(pool ConnPool) map[string]PoolStats {
+	if pool, ok := pool.(StatsConnPool); ok {
+		return pool.ConnStats().Hosts
+	}
+	if pool, ok := pool.(*redis.Pool); ok {
+		return map[string]PoolStats{"": {PoolStats: pool.Stats()}}
+	}
+	return map[string]PoolStats{}
+}
@@ -0,0 +1,135 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// monitorValues returns the values of samples by key, and their labels
+func monitorValues(samples []MultiAddSample) (map[string]float64, map[string]map[string]string) {
+	values, labels := map[string]float64{}, map[string]map[string]string{}
+	for _, sample := range samples {
+		values[sample.Sample.Key] = sample.Sample.DataPoint.Value
+		labels[sample.Sample.Key] = sample.CreateOptions.Labels
+	}
+	return values, labels
+}
+
+func TestMonitor_Collect(t *testing.T) {
+	registry := NewHistogramRegistry()
+	latency := registry.Histogram(ADD_CMD+".duration_seconds", DefaultLatencyBuckets)
+	failures := registry.Histogram(ADD_CMD+".errors", DefaultLatencyBuckets)
+	for n := 0; n < 3; n++ {
+		latency.Observe(0.001)
+	}
+	latency.Observe(10)
+	failures.Observe(10)
+	c := &Client{Pool: &stubPool{}, Name: "test"}
+	m := NewMonitor(c, registry, *NewMonitorOptions().SetKeyPrefix("app").SetQuantiles(0.5, 0.999).
+		SetLabels(map[string]string{"app": "test"}))
+
+	values, labels := monitorValues(m.collect(time.Now()))
+	assert.Equal(t, map[string]float64{
+		"app:command:TS.ADD:calls":          4,
+		"app:command:TS.ADD:error_rate":     0.25,
+		"app:command:TS.ADD:latency_ms:p50": 1,
+	}, values)
+	assert.Equal(t, map[string]string{
+		"app": "test", "metric": "latency_ms", "command": ADD_CMD, "quantile": "0.5",
+	}, labels["app:command:TS.ADD:latency_ms:p50"])
+
+	// the second report only covers the commands issued since the first one
+	latency.Observe(0.02)
+	values, _ = monitorValues(m.collect(time.Now()))
+	assert.Equal(t, map[string]float64{
+		"app:command:TS.ADD:calls":            1,
+		"app:command:TS.ADD:error_rate":       0,
+		"app:command:TS.ADD:latency_ms:p50":   25,
+		"app:command:TS.ADD:latency_ms:p99.9": 25,
+	}, values)
+	values, _ = monitorValues(m.collect(time.Now()))
+	assert.Equal(t, map[string]float64{
+		"app:command:TS.ADD:calls":      0,
+		"app:command:TS.ADD:error_rate": 0,
+	}, values)
+}
+
+func TestMonitor_PoolStats(t *testing.T) {
+	s := newRespServer(t, pongHandler)
+	defer s.close()
+	pool := NewMultiHostPool([]string{s.addr()}, nil)
+	defer pool.Close()
+	conn := pool.Get()
+	_, err := conn.Do("PING")
+	assert.Nil(t, err)
+
+	m := NewMonitor(&Client{Pool: pool, Name: "test"}, NewHistogramRegistry(), DefaultMonitorOptions)
+	values, labels := monitorValues(m.collect(time.Now()))
+	active := "redistimeseries_client:pool:" + s.addr() + ":active"
+	assert.Equal(t, map[string]float64{active: 1, "redistimeseries_client:pool:" + s.addr() + ":idle": 0}, values)
+	assert.Equal(t, map[string]string{"metric": "pool_active", "host": s.addr()}, labels[active])
+
+	conn.Close()
+	values, _ = monitorValues(m.collect(time.Now()))
+	assert.Equal(t, float64(1), values[active])
+	assert.Equal(t, float64(1), values["redistimeseries_client:pool:"+s.addr()+":idle"])
+
+	single := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", s.addr()) }}
+	defer single.Close()
+	m = NewMonitor(&Client{Pool: single, Name: "test"}, NewHistogramRegistry(), DefaultMonitorOptions)
+	values, _ = monitorValues(m.collect(time.Now()))
+	assert.Equal(t, map[string]float64{"redistimeseries_client:pool:active": 0, "redistimeseries_client:pool:idle": 0}, values)
+}
+
+func TestMonitor_Report(t *testing.T) {
+	var mu sync.Mutex
+	var keys []interface{}
+	recorder := &maddRecorder{reply: func(args []interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for i := 0; i < len(args); i += 3 {
+			keys = append(keys, args[i])
+		}
+		return okMultiAddReply(args), nil
+	}}
+	c := &Client{Pool: &stubPool{handler: recorder.handle}, Name: "test"}
+	w := NewBufferedWriter(c, *NewBufferedWriterOptions().SetFlushInterval(time.Hour))
+	defer w.Close()
+	assert.Nil(t, w.Write(testSamples(3)...))
+
+	m := NewMonitor(c, NewHistogramRegistry(), *NewMonitorOptions().SetInterval(10 * time.Millisecond))
+	m.WatchBufferedWriter("ingest", w)
+	assert.Nil(t, m.Report(context.Background()))
+	assert.Equal(t, []interface{}{"redistimeseries_client:writer:ingest:pending", "redistimeseries_client:writer:ingest:dropped"}, keys)
+
+	m.Start()
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(keys) >= 6
+	})
+	m.Close()
+	m.Close()
+
+	failing := &Client{Pool: &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return nil, errors.New("connection refused")
+	}}, Name: "test"}
+	reported := make(chan error, 1)
+	m = NewMonitor(failing, NewHistogramRegistry(), *NewMonitorOptions().SetInterval(10 * time.Millisecond).
+		SetErrorHandler(func(err error) {
+			select {
+			case reported <- err:
+			default:
+			}
+		}))
+	m.WatchBufferedWriter("ingest", w)
+	m.Start()
+	defer m.Close()
+	assert.EqualError(t, <-reported, "connection refused")
+}
@@ -0,0 +1,134 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// MultiAddSample is a Sample along with the options used to create its time-series when it does not exist
+type MultiAddSample struct {
+	Sample
+	// CreateOptions, when set, creates the missing time-series with TS.ADD semantics, using these options
+	// for its retention, labels, chunk size and duplicate policy.
+	// When nil, a sample added to a missing time-series fails with ErrKeyNotFound.
+	CreateOptions *CreateOptions
+}
+
+// MultiAddResult is the outcome of adding a single sample with MultiAddWithResults or MultiAddWithOptions
+type MultiAddResult struct {
+	Sample MultiAddSample
+	// Timestamp is the timestamp stored by the server, valid when Err is nil
+	Timestamp int64
+	// Err is the classified error which made the sample fail, such as ErrKeyNotFound or ErrDuplicateSampleBlocked
+	Err error
+}
+
+// MultiAddResults holds the outcome of each sample, in the same order as the added samples
+type MultiAddResults []MultiAddResult
+
+// Failed returns the results of the samples which could not be added
+func (results MultiAddResults) Failed() MultiAddResults {
+	failed := MultiAddResults{}
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// FailedSamples returns the samples which could not be added, along with their options,
+// so that they can be retried with MultiAddWithOptions
+func (results MultiAddResults) FailedSamples() []MultiAddSample {
+	samples := []MultiAddSample{}
+	for _, result := range results {
+		if result.Err != nil {
+			samples = append(samples, result.Sample)
+		}
+	}
+	return samples
+}
+
+// MultiAddWithResults appends new samples to a list of series, like MultiAdd, and reports the outcome of each sample
+func (client *Client) MultiAddWithResults(samples ...Sample) (results MultiAddResults, err error) {
+	return client.MultiAddWithResultsCtx(context.Background(), samples...)
+}
+
+// MultiAddWithResultsCtx is like MultiAddWithResults, honoring the deadline and cancellation of ctx
+func (client *Client) MultiAddWithResultsCtx(ctx context.Context, samples ...Sample) (results MultiAddResults, err error) {
+	multiAddSamples := make([]MultiAddSample, len(samples))
+	for i, sample := range samples {
+		multiAddSamples[i] = MultiAddSample{Sample: sample}
+	}
+	return client.MultiAddWithOptionsCtx(ctx, multiAddSamples...)
+}
+
+// MultiAddWithOptions appends new samples to a list of series, and reports the outcome of each sample.
+// The samples are added with a single TS.MADD. The ones rejected because their series does not exist,
+// and which carry CreateOptions, are then added with TS.ADD, creating their series, in a single pipeline.
+// The returned error is only set when TS.MADD itself failed, the failures of single samples are reported by their result.
+func (client *Client) MultiAddWithOptions(samples ...MultiAddSample) (results MultiAddResults, err error) {
+	return client.MultiAddWithOptionsCtx(context.Background(), samples...)
+}
+
+// MultiAddWithOptionsCtx is like MultiAddWithOptions, honoring the deadline and cancellation of ctx
+func (client *Client) MultiAddWithOptionsCtx(ctx context.Context, samples ...MultiAddSample) (results MultiAddResults, err error) {
+	results = MultiAddResults{}
+	if len(samples) == 0 {
+		return
+	}
+	plainSamples := make([]Sample, len(samples))
+	for i, sample := range samples {
+		plainSamples[i] = sample.Sample
+	}
+	timestamps, err := client.MultiAddCtx(ctx, plainSamples...)
+	if err != nil {
+		return nil, err
+	}
+	if len(timestamps) != len(samples) {
+		return nil, fmt.Errorf("TS.MADD returned %d replies for %d samples", len(timestamps), len(samples))
+	}
+	results = make(MultiAddResults, len(samples))
+	missing := []int{}
+	for i, sample := range samples {
+		results[i] = parseMultiAddResult(sample, timestamps[i])
+		if sample.CreateOptions != nil && errors.Is(results[i].Err, ErrKeyNotFound) {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) > 0 {
+		client.addMissing(ctx, results, missing)
+	}
+	return
+}
+
+// addMissing adds again the samples whose series did not exist with TS.ADD, creating their series.
+// The samples are added in order, so that later samples of a series are added once it was created.
+func (client *Client) addMissing(ctx context.Context, results MultiAddResults, missing []int) {
+	p := client.Pipeline()
+	for _, i := range missing {
+		sample := results[i].Sample
+		p.AddWithOptions(sample.Key, sample.DataPoint.Timestamp, sample.DataPoint.Value, *sample.CreateOptions)
+	}
+	addResults, err := p.ExecCtx(ctx)
+	for j, i := range missing {
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Timestamp, results[i].Err = addResults[j].Int64()
+	}
+}
+
+func parseMultiAddResult(sample MultiAddSample, reply interface{}) MultiAddResult {
+	result := MultiAddResult{Sample: sample}
+	if err, ok := reply.(error); ok {
+		result.Err = err
+		return result
+	}
+	result.Timestamp, result.Err = redis.Int64(reply, nil)
+	return result
+}
@@ -0,0 +1,86 @@
+package redis_timeseries_go
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_MultiAddWithOptions(t *testing.T) {
+	notFound := redis.Error("ERR TSDB: the key does not exist")
+	var added [][]interface{}
+	pool := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case MADD_CMD:
+			return []interface{}{int64(1), notFound, redis.Error("ERR TSDB: Timestamp is older than retention"), notFound}, nil
+		case ADD_CMD:
+			added = append(added, args)
+			return args[1], nil
+		}
+		return nil, errors.New("unexpected command " + cmd)
+	}}
+	c := &Client{Pool: pool, Name: "test"}
+	options := CreateOptions{Labels: map[string]string{"sensor": "2"}}
+	results, err := c.MultiAddWithOptions(
+		MultiAddSample{Sample: Sample{Key: "a", DataPoint: DataPoint{Timestamp: 1, Value: 1}}},
+		MultiAddSample{Sample: Sample{Key: "b", DataPoint: DataPoint{Timestamp: 2, Value: 2}}, CreateOptions: &options},
+		MultiAddSample{Sample: Sample{Key: "c", DataPoint: DataPoint{Timestamp: 3, Value: 3}}, CreateOptions: &options},
+		MultiAddSample{Sample: Sample{Key: "d", DataPoint: DataPoint{Timestamp: 4, Value: 4}}},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]interface{}{{"b", int64(2), "2", "LABELS", "sensor", "2"}}, added)
+
+	tests := []struct {
+		key           string
+		wantTimestamp int64
+		wantErr       error
+	}{
+		{"a", 1, nil},
+		{"b", 2, nil},
+		{"c", 0, ErrTimestampTooOld},
+		{"d", 0, ErrKeyNotFound},
+	}
+	assert.Len(t, results, len(tests))
+	for i, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.key, results[i].Sample.Key)
+			assert.Equal(t, tt.wantTimestamp, results[i].Timestamp)
+			if tt.wantErr == nil {
+				assert.Nil(t, results[i].Err)
+			} else {
+				assert.True(t, errors.Is(results[i].Err, tt.wantErr))
+			}
+		})
+	}
+
+	failed := results.Failed()
+	assert.Len(t, failed, 2)
+	samples := results.FailedSamples()
+	assert.Equal(t, []MultiAddSample{results[2].Sample, results[3].Sample}, samples)
+	assert.Equal(t, &options, samples[0].CreateOptions)
+}
+
+func TestClient_MultiAddWithResults(t *testing.T) {
+	pool := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return nil, redis.Error("ERR wrong number of arguments for 'TS.MADD' command")
+	}}
+	c := &Client{Pool: pool, Name: "test"}
+	results, err := c.MultiAddWithResults(Sample{Key: "a", DataPoint: DataPoint{Timestamp: 1, Value: 1}})
+	assert.NotNil(t, err)
+	assert.Nil(t, results)
+
+	pool.handler = func(cmd string, args ...interface{}) (interface{}, error) {
+		return []interface{}{int64(1)}, nil
+	}
+	results, err = c.MultiAddWithResults(Sample{Key: "a", DataPoint: DataPoint{Timestamp: 1, Value: 1}},
+		Sample{Key: "b", DataPoint: DataPoint{Timestamp: 2, Value: 2}})
+	assert.EqualError(t, err, "TS.MADD returned 1 replies for 2 samples")
+	assert.Nil(t, results)
+
+	results, err = c.MultiAddWithResults()
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+	assert.Empty(t, results.FailedSamples())
+}
@@ -0,0 +1,199 @@
+package redis_timeseries_go
+
+import "strings"
+
+// NamespaceOptions are the options of the key namespace of a Client, enabled by setting Client.Namespace.
+// Every key given to the Client is then prefixed with Client.Name and Separator, and the prefix is stripped
+// from the keys it returns, so that several tenants or environments can share the same database.
+type NamespaceOptions struct {
+	// Separator is inserted between Client.Name and the keys
+	Separator string
+	// Label, when not empty, is a label set to Client.Name on the series created through the Client,
+	// and added as a filter to TS.MRANGE, TS.MREVRANGE, TS.MGET and TS.QUERYINDEX, so that they never
+	// return the series of another namespace
+	Label string
+}
+
+func NewNamespaceOptions() *NamespaceOptions {
+	return &NamespaceOptions{
+		Separator: ":",
+		Label:     "",
+	}
+}
+
+// DefaultNamespaceOptions are the default options of a key namespace, prefixing the keys with Client.Name and ":"
+var DefaultNamespaceOptions = *NewNamespaceOptions()
+
+// SetSeparator sets the separator inserted between Client.Name and the keys
+func (options *NamespaceOptions) SetSeparator(separator string) *NamespaceOptions {
+	options.Separator = separator
+	return options
+}
+
+// SetLabel sets the label identifying the series of the namespace
+func (options *NamespaceOptions) SetLabel(label string) *NamespaceOptions {
+	options.Label = label
+	return options
+}
+
+// namespace rewrites the keys, options and filters of the commands issued in a namespace.
+// Its methods are no-ops on a nil namespace, which is the one of Clients without Namespace.
+type namespace struct {
+	prefix string
+	label  string
+	value  string
+}
+
+// namespace returns the namespace of the client, nil when Namespace is not set
+func (client *Client) namespace() *namespace {
+	if client.Namespace == nil {
+		return nil
+	}
+	return &namespace{
+		prefix: client.Name + client.Namespace.Separator,
+		label:  client.Namespace.Label,
+		value:  client.Name,
+	}
+}
+
+func (ns *namespace) key(key string) string {
+	if ns == nil {
+		return key
+	}
+	return ns.prefix + key
+}
+
+func (ns *namespace) stripKey(key string) string {
+	if ns == nil {
+		return key
+	}
+	return strings.TrimPrefix(key, ns.prefix)
+}
+
+func (ns *namespace) hasLabel() bool {
+	return ns != nil && ns.label != ""
+}
+
+// createOptions adds the namespace label to the labels of a created series
+func (ns *namespace) createOptions(options CreateOptions) CreateOptions {
+	if !ns.hasLabel() {
+		return options
+	}
+	labels := make(map[string]string, len(options.Labels)+1)
+	for name, value := range options.Labels {
+		labels[name] = value
+	}
+	labels[ns.label] = ns.value
+	options.Labels = labels
+	return options
+}
+
+// alterOptions adds the namespace label when TS.ALTER replaces the labels, so that the series stays in the namespace
+func (ns *namespace) alterOptions(options CreateOptions) CreateOptions {
+	if len(options.Labels) == 0 {
+		return options
+	}
+	return ns.createOptions(options)
+}
+
+// labelArgs appends the namespace label to the arguments of a TS.ADD without options
+func (ns *namespace) labelArgs(args []interface{}) []interface{} {
+	if !ns.hasLabel() {
+		return args
+	}
+	return append(args, "LABELS", ns.label, ns.value)
+}
+
+func (ns *namespace) filters(filters []string) []string {
+	if !ns.hasLabel() || len(filters) == 0 {
+		return filters
+	}
+	return append(append(make([]string, 0, len(filters)+1), filters...), ns.label+"="+ns.value)
+}
+
+func (ns *namespace) samples(samples []Sample) []Sample {
+	if ns == nil {
+		return samples
+	}
+	prefixed := make([]Sample, len(samples))
+	for i, sample := range samples {
+		prefixed[i] = Sample{Key: ns.key(sample.Key), DataPoint: sample.DataPoint}
+	}
+	return prefixed
+}
+
+// stripRanges strips the namespace from the names of the series, and from the keys listed by the __source__ label
+// of the series grouped by GROUPBY
+func (ns *namespace) stripRanges(ranges []Range) []Range {
+	if ns == nil {
+		return ranges
+	}
+	for i := range ranges {
+		ranges[i].Name = ns.stripKey(ranges[i].Name)
+		if sources, found := ranges[i].Labels[sourceLabel]; found {
+			keys := strings.Split(sources, ",")
+			ranges[i].Labels[sourceLabel] = strings.Join(ns.stripKeys(keys), ",")
+		}
+	}
+	return ranges
+}
+
+func (ns *namespace) stripKeys(keys []string) []string {
+	if ns == nil {
+		return keys
+	}
+	for i := range keys {
+		keys[i] = ns.stripKey(keys[i])
+	}
+	return keys
+}
+
+func (ns *namespace) stripInfo(info KeyInfo) KeyInfo {
+	if ns == nil {
+		return info
+	}
+	for i := range info.Rules {
+		info.Rules[i].DestKey = ns.stripKey(info.Rules[i].DestKey)
+	}
+	return info
+}
+
+// parseRanges, parseKeys and parseInfo wrap the parsing of queued commands replies, stripping the namespace from the keys
+func (ns *namespace) parseRanges(parse func(reply interface{}) (interface{}, error)) func(reply interface{}) (interface{}, error) {
+	if ns == nil {
+		return parse
+	}
+	return func(reply interface{}) (interface{}, error) {
+		value, err := parse(reply)
+		if err != nil {
+			return nil, err
+		}
+		return ns.stripRanges(value.([]Range)), nil
+	}
+}
+
+func (ns *namespace) parseKeys(parse func(reply interface{}) (interface{}, error)) func(reply interface{}) (interface{}, error) {
+	if ns == nil {
+		return parse
+	}
+	return func(reply interface{}) (interface{}, error) {
+		value, err := parse(reply)
+		if err != nil {
+			return nil, err
+		}
+		return ns.stripKeys(value.([]string)), nil
+	}
+}
+
+func (ns *namespace) parseInfo(parse func(reply interface{}) (interface{}, error)) func(reply interface{}) (interface{}, error) {
+	if ns == nil {
+		return parse
+	}
+	return func(reply interface{}) (interface{}, error) {
+		value, err := parse(reply)
+		if err != nil {
+			return nil, err
+		}
+		return ns.stripInfo(value.(KeyInfo)), nil
+	}
+}
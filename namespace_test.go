@@ -0,0 +1,118 @@
+package redis_timeseries_go
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// namespaceHandler records the issued commands, and replies with keys of both the tenant and another namespace
+func namespaceHandler(commands *[]string) func(cmd string, args ...interface{}) (interface{}, error) {
+	return func(cmd string, args ...interface{}) (interface{}, error) {
+		*commands = append(*commands, strings.TrimSpace(fmt.Sprintln(append([]interface{}{cmd}, args...)...)))
+		switch cmd {
+		case QUERYINDEX_CMD:
+			return []interface{}{[]byte("tenant:a"), []byte("tenant:b")}, nil
+		case MGET_CMD:
+			return []interface{}{[]interface{}{[]byte("tenant:a"), []interface{}{}, []interface{}{int64(1), []byte("1")}}}, nil
+		case MRANGE_CMD:
+			return []interface{}{[]interface{}{[]byte("region=east"), []interface{}{
+				[]interface{}{[]byte("region"), []byte("east")},
+				[]interface{}{[]byte(reducerLabel), []byte("sum")},
+				[]interface{}{[]byte(sourceLabel), []byte("tenant:a,tenant:b")},
+			}, []interface{}{[]interface{}{int64(1), []byte("3")}}}}, nil
+		case MADD_CMD:
+			return []interface{}{int64(1), int64(2)}, nil
+		case INFO_CMD:
+			return []interface{}{[]byte("rules"), []interface{}{[]interface{}{[]byte("tenant:avg"), int64(60), []byte("AVG")}}}, nil
+		case ADD_CMD:
+			return int64(1), nil
+		}
+		return "OK", nil
+	}
+}
+
+func TestClient_Namespace(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace *NamespaceOptions
+		run       func(c *Client) (interface{}, error)
+		want      interface{}
+		wantCmds  []string
+	}{
+		{"add without namespace", nil,
+			func(c *Client) (interface{}, error) { return c.Add("a", 1, 2) },
+			int64(1), []string{"TS.ADD a 1 2"}},
+		{"add", NewNamespaceOptions(),
+			func(c *Client) (interface{}, error) { return c.Add("a", 1, 2) },
+			int64(1), []string{"TS.ADD tenant:a 1 2"}},
+		{"add with label", NewNamespaceOptions().SetLabel("ns"),
+			func(c *Client) (interface{}, error) { return c.Add("a", 1, 2) },
+			int64(1), []string{"TS.ADD tenant:a 1 2 LABELS ns tenant"}},
+		{"custom separator", NewNamespaceOptions().SetSeparator("/"),
+			func(c *Client) (interface{}, error) { return nil, c.DeleteSerie("a") },
+			nil, []string{"DEL tenant/a"}},
+		{"create with label", NewNamespaceOptions().SetLabel("ns"),
+			func(c *Client) (interface{}, error) {
+				return nil, c.CreateKeyWithOptions("a", CreateOptions{})
+			},
+			nil, []string{"TS.CREATE tenant:a LABELS ns tenant"}},
+		{"alter retention only", NewNamespaceOptions().SetLabel("ns"),
+			func(c *Client) (interface{}, error) {
+				return nil, c.AlterKeyWithOptions("a", CreateOptions{RetentionMSecs: 1000000})
+			},
+			nil, []string{"TS.ALTER tenant:a RETENTION 1"}},
+		{"create rule", NewNamespaceOptions(),
+			func(c *Client) (interface{}, error) { return nil, c.CreateRule("a", AvgAggregation, 60, "avg") },
+			nil, []string{"TS.CREATERULE tenant:a tenant:avg AGGREGATION AVG 60"}},
+		{"multi add", NewNamespaceOptions(),
+			func(c *Client) (interface{}, error) {
+				return c.MultiAdd(Sample{Key: "a", DataPoint: DataPoint{1, 1}}, Sample{Key: "b", DataPoint: DataPoint{2, 2}})
+			},
+			[]interface{}{int64(1), int64(2)}, []string{"TS.MADD tenant:a 1 1 tenant:b 2 2"}},
+		{"query index", NewNamespaceOptions().SetLabel("ns"),
+			func(c *Client) (interface{}, error) { return c.QueryIndex("sensor=1") },
+			[]string{"a", "b"}, []string{"TS.QUERYINDEX sensor=1 ns=tenant"}},
+		{"multi get", NewNamespaceOptions(),
+			func(c *Client) (interface{}, error) { return c.MultiGet("sensor=1") },
+			[]Range{{Name: "a", Labels: map[string]string{}, DataPoints: []DataPoint{{1, 1}}}}, []string{"TS.MGET FILTER sensor=1"}},
+		{"group by", NewNamespaceOptions(),
+			func(c *Client) (interface{}, error) {
+				return c.MultiRangeWithOptions(0, 10, *NewMultiRangeOptions().SetGroupByReduce("region", SumReducer), "region=east")
+			},
+			[]Range{{Name: "region=east", Labels: map[string]string{"region": "east", reducerLabel: "sum", sourceLabel: "a,b"},
+				DataPoints: []DataPoint{{1, 3}}}}, []string{"TS.MRANGE 0 10 FILTER region=east GROUPBY region REDUCE SUM"}},
+		{"info", NewNamespaceOptions(),
+			func(c *Client) (interface{}, error) {
+				info, err := c.Info("a")
+				return info.Rules, err
+			},
+			[]Rule{{DestKey: "avg", BucketSizeSec: 60, AggType: AvgAggregation}}, []string{"TS.INFO tenant:a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var commands []string
+			c := &Client{Pool: &stubPool{handler: namespaceHandler(&commands)}, Name: "tenant", Namespace: tt.namespace}
+			got, err := tt.run(c)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantCmds, commands)
+		})
+	}
+}
+
+func TestPipeline_Namespace(t *testing.T) {
+	var commands []string
+	c := &Client{Pool: &stubPool{handler: namespaceHandler(&commands)}, Name: "tenant", Namespace: NewNamespaceOptions().SetLabel("ns")}
+	p := c.Pipeline()
+	p.AddAutoTs("a", 1)
+	p.QueryIndex("sensor=1")
+	results, err := p.Exec()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"TS.ADD tenant:a * 1 LABELS ns tenant", "TS.QUERYINDEX sensor=1 ns=tenant"}, commands)
+	keys, err := results[1].Strings()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys)
+}
@@ -0,0 +1,544 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// CommandResult holds the outcome of a single command queued on a Pipeline.
+// Value holds the parsed reply, whose type depends on the queued command:
+// int64 for TS.ADD, TS.INCRBY, TS.DECRBY and TS.DEL, []DataPoint for TS.RANGE/TS.REVRANGE, *DataPoint for TS.GET,
+// []Range for TS.MRANGE/TS.MREVRANGE/TS.MGET, KeyInfo for TS.INFO, []string for TS.QUERYINDEX,
+// []interface{} for TS.MADD and nil for the remaining commands.
+type CommandResult struct {
+	Command string
+	Value   interface{}
+	Err     error
+}
+
+// Int64 returns the reply of TS.ADD, TS.INCRBY, TS.DECRBY or TS.DEL
+func (r CommandResult) Int64() (int64, error) {
+	if r.Err != nil {
+		return 0, r.Err
+	}
+	v, ok := r.Value.(int64)
+	if !ok {
+		return 0, r.typeError("int64")
+	}
+	return v, nil
+}
+
+// DataPoints returns the reply of TS.RANGE or TS.REVRANGE
+func (r CommandResult) DataPoints() ([]DataPoint, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	v, ok := r.Value.([]DataPoint)
+	if !ok {
+		return nil, r.typeError("[]DataPoint")
+	}
+	return v, nil
+}
+
+// DataPoint returns the reply of TS.GET
+func (r CommandResult) DataPoint() (*DataPoint, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	v, ok := r.Value.(*DataPoint)
+	if !ok {
+		return nil, r.typeError("*DataPoint")
+	}
+	return v, nil
+}
+
+// Ranges returns the reply of TS.MRANGE, TS.MREVRANGE or TS.MGET
+func (r CommandResult) Ranges() ([]Range, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	v, ok := r.Value.([]Range)
+	if !ok {
+		return nil, r.typeError("[]Range")
+	}
+	return v, nil
+}
+
+// KeyInfo returns the reply of TS.INFO
+func (r CommandResult) KeyInfo() (KeyInfo, error) {
+	if r.Err != nil {
+		return KeyInfo{}, r.Err
+	}
+	v, ok := r.Value.(KeyInfo)
+	if !ok {
+		return KeyInfo{}, r.typeError("KeyInfo")
+	}
+	return v, nil
+}
+
+// Strings returns the reply of TS.QUERYINDEX
+func (r CommandResult) Strings() ([]string, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	v, ok := r.Value.([]string)
+	if !ok {
+		return nil, r.typeError("[]string")
+	}
+	return v, nil
+}
+
+// Values returns the reply of TS.MADD
+func (r CommandResult) Values() ([]interface{}, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	v, ok := r.Value.([]interface{})
+	if !ok {
+		return nil, r.typeError("[]interface{}")
+	}
+	return v, nil
+}
+
+func (r CommandResult) typeError(want string) error {
+	return fmt.Errorf("%s result is of type %T, not %s", r.Command, r.Value, want)
+}
+
+// queuedCommand is a command waiting to be sent, along with the parser for its reply
+type queuedCommand struct {
+	// key is the key the command acts on, used for routing on a ShardedConnPool
+	key   string
+	name  string
+	args  []interface{}
+	parse func(reply interface{}) (interface{}, error)
+	// run executes commands acting on several keys through the Client when they can not be pipelined on a single node
+	run func(ctx context.Context, client *Client) (interface{}, error)
+	// err holds an argument serialization error. The command is not sent when set
+	err error
+	// skip marks commands which are answered locally, without a round trip, such as TS.MGET without filters
+	skip bool
+	// value is the result of a skipped command, the empty result the matching Client method returns
+	value interface{}
+}
+
+func parseNothing(reply interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func parseInt64(reply interface{}) (interface{}, error) {
+	return redis.Int64(reply, nil)
+}
+
+func parseDataPoints(reply interface{}) (interface{}, error) {
+	return ParseDataPoints(reply)
+}
+
+func parseDataPoint(reply interface{}) (interface{}, error) {
+	return ParseDataPoint(reply)
+}
+
+func parseRanges(reply interface{}) (interface{}, error) {
+	return ParseRanges(reply)
+}
+
+func parseRangesSingleDataPoint(reply interface{}) (interface{}, error) {
+	return ParseRangesSingleDataPoint(reply)
+}
+
+func parseInfo(reply interface{}) (interface{}, error) {
+	return ParseInfo(reply, nil)
+}
+
+func parseStrings(reply interface{}) (interface{}, error) {
+	return redis.Strings(reply, nil)
+}
+
+func parseMultiAdd(reply interface{}) (interface{}, error) {
+	return classifyReplies(redis.Values(reply, nil))
+}
+
+// commandQueue holds the TimeSeries commands queued for a later round trip.
+// The queuing methods mirror the Client ones, and the results are reported by the owner of the queue in queuing order.
+type commandQueue struct {
+	cmds []queuedCommand
+	// ns is the namespace of the client the queue was created from
+	ns *namespace
+}
+
+func (q *commandQueue) queue(key string, name string, args []interface{}, parse func(reply interface{}) (interface{}, error)) {
+	q.cmds = append(q.cmds, queuedCommand{key: key, name: name, args: args, parse: parse})
+}
+
+func (q *commandQueue) queueUnkeyed(name string, args []interface{}, parse func(reply interface{}) (interface{}, error), run func(ctx context.Context, client *Client) (interface{}, error)) {
+	q.cmds = append(q.cmds, queuedCommand{name: name, args: args, parse: parse, run: run})
+}
+
+// queueSkipped queues a command answered locally with value
+func (q *commandQueue) queueSkipped(name string, value interface{}) {
+	q.cmds = append(q.cmds, queuedCommand{name: name, skip: true, value: value})
+}
+
+func (q *commandQueue) queueErr(name string, err error) {
+	q.cmds = append(q.cmds, queuedCommand{name: name, err: err})
+}
+
+// Len returns the number of queued commands
+func (q *commandQueue) Len() int {
+	return len(q.cmds)
+}
+
+// CreateKeyWithOptions queues the creation of a new time-series
+func (q *commandQueue) CreateKeyWithOptions(key string, options CreateOptions) {
+	key, options = q.ns.key(key), q.ns.createOptions(options)
+	args, err := options.SerializeSeriesOptions(CREATE_CMD, []interface{}{key})
+	if err != nil {
+		q.queueErr(CREATE_CMD, err)
+		return
+	}
+	q.queue(key, CREATE_CMD, args, parseNothing)
+}
+
+// AlterKeyWithOptions queues the update of the retention, labels of an existing key
+func (q *commandQueue) AlterKeyWithOptions(key string, options CreateOptions) {
+	key, options = q.ns.key(key), q.ns.alterOptions(options)
+	args, err := options.SerializeSeriesOptions(ALTER_CMD, []interface{}{key})
+	if err != nil {
+		q.queueErr(ALTER_CMD, err)
+		return
+	}
+	q.queue(key, ALTER_CMD, args, parseNothing)
+}
+
+// Add queues the append of a new sample to the series
+func (q *commandQueue) Add(key string, timestamp int64, value float64) {
+	key = q.ns.key(key)
+	q.queue(key, ADD_CMD, q.ns.labelArgs([]interface{}{key, timestamp, floatToStr(value)}), parseInt64)
+}
+
+// AddAutoTs queues the append of a new sample to the series, with DB automatic timestamp
+func (q *commandQueue) AddAutoTs(key string, value float64) {
+	key = q.ns.key(key)
+	q.queue(key, ADD_CMD, q.ns.labelArgs([]interface{}{key, "*", floatToStr(value)}), parseInt64)
+}
+
+// AddWithOptions queues the append of a new sample to the series, with the specified CreateOptions
+func (q *commandQueue) AddWithOptions(key string, timestamp int64, value float64, options CreateOptions) {
+	key, options = q.ns.key(key), q.ns.createOptions(options)
+	args, err := options.SerializeSeriesOptions(ADD_CMD, []interface{}{key, timestamp, floatToStr(value)})
+	if err != nil {
+		q.queueErr(ADD_CMD, err)
+		return
+	}
+	q.queue(key, ADD_CMD, args, parseInt64)
+}
+
+// AddAutoTsWithOptions queues the append of a new sample to the series, with the specified CreateOptions and DB automatic timestamp
+func (q *commandQueue) AddAutoTsWithOptions(key string, value float64, options CreateOptions) {
+	key, options = q.ns.key(key), q.ns.createOptions(options)
+	args, err := options.SerializeSeriesOptions(ADD_CMD, []interface{}{key, "*", floatToStr(value)})
+	if err != nil {
+		q.queueErr(ADD_CMD, err)
+		return
+	}
+	q.queue(key, ADD_CMD, args, parseInt64)
+}
+
+// MultiAdd queues the append of new samples to a list of series
+func (q *commandQueue) MultiAdd(samples ...Sample) {
+	if len(samples) == 0 {
+		q.queueSkipped(MADD_CMD, []interface{}(nil))
+		return
+	}
+	q.queueUnkeyed(MADD_CMD, multiAddArgs(q.ns.samples(samples)), parseMultiAdd, func(ctx context.Context, client *Client) (interface{}, error) {
+		return client.MultiAddCtx(ctx, samples...)
+	})
+}
+
+// IncrBy queues the creation of a new sample that increments the latest sample's value
+func (q *commandQueue) IncrBy(key string, timestamp int64, value float64, options CreateOptions) {
+	q.queueCounter(INCRBY_CMD, key, timestamp, value, options)
+}
+
+// IncrByAutoTs queues the creation of a new sample that increments the latest sample's value with an auto timestamp
+func (q *commandQueue) IncrByAutoTs(key string, value float64, options CreateOptions) {
+	q.queueCounter(INCRBY_CMD, key, -1, value, options)
+}
+
+// DecrBy queues the creation of a new sample that decrements the latest sample's value
+func (q *commandQueue) DecrBy(key string, timestamp int64, value float64, options CreateOptions) {
+	q.queueCounter(DECRBY_CMD, key, timestamp, value, options)
+}
+
+// DecrByAutoTs queues the creation of a new sample that decrements the latest sample's value with an auto timestamp
+func (q *commandQueue) DecrByAutoTs(key string, value float64, options CreateOptions) {
+	q.queueCounter(DECRBY_CMD, key, -1, value, options)
+}
+
+func (q *commandQueue) queueCounter(cmd string, key string, timestamp int64, value float64, options CreateOptions) {
+	key, options = q.ns.key(key), q.ns.createOptions(options)
+	args, err := AddCounterArgs(key, timestamp, value, options)
+	if err != nil {
+		q.queueErr(cmd, err)
+		return
+	}
+	q.queue(key, cmd, args, parseInt64)
+}
+
+// DeleteSerie queues the deletion of the series given the time series key name
+func (q *commandQueue) DeleteSerie(key string) {
+	key = q.ns.key(key)
+	q.queue(key, DEL_CMD, []interface{}{key}, parseNothing)
+}
+
+// DeleteRange queues the deletion of data points for a given timeseries and interval range
+func (q *commandQueue) DeleteRange(key string, fromTimestamp int64, toTimestamp int64) {
+	key = q.ns.key(key)
+	q.queue(key, TS_DEL_CMD, []interface{}{key, fromTimestamp, toTimestamp}, parseInt64)
+}
+
+// CreateRule queues the creation of a compaction rule
+func (q *commandQueue) CreateRule(sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) {
+	sourceKey, destinationKey = q.ns.key(sourceKey), q.ns.key(destinationKey)
+	q.queue(sourceKey, CREATERULE_CMD, []interface{}{sourceKey, destinationKey, "AGGREGATION", aggType, bucketSizeMSec}, parseNothing)
+}
+
+// DeleteRule queues the deletion of a compaction rule
+func (q *commandQueue) DeleteRule(sourceKey string, destinationKey string) {
+	sourceKey, destinationKey = q.ns.key(sourceKey), q.ns.key(destinationKey)
+	q.queue(sourceKey, DELETERULE_CMD, []interface{}{sourceKey, destinationKey}, parseNothing)
+}
+
+// RangeWithOptions queues a timestamp range query on a specific time-series
+func (q *commandQueue) RangeWithOptions(key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) {
+	key = q.ns.key(key)
+	q.queue(key, RANGE_CMD, createRangeCmdArguments(key, fromTimestamp, toTimestamp, rangeOptions), parseDataPoints)
+}
+
+// ReverseRangeWithOptions queues a timestamp range query on a specific time-series in reverse order
+func (q *commandQueue) ReverseRangeWithOptions(key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) {
+	key = q.ns.key(key)
+	q.queue(key, REVRANGE_CMD, createRangeCmdArguments(key, fromTimestamp, toTimestamp, rangeOptions), parseDataPoints)
+}
+
+// MultiRangeWithOptions queues a timestamp range query across multiple time-series by filters
+func (q *commandQueue) MultiRangeWithOptions(fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) {
+	q.queueUnkeyed(MRANGE_CMD, createMultiRangeCmdArguments(fromTimestamp, toTimestamp, mrangeOptions, q.ns.filters(filters)), q.ns.parseRanges(parseRanges), func(ctx context.Context, client *Client) (interface{}, error) {
+		return client.MultiRangeWithOptionsCtx(ctx, fromTimestamp, toTimestamp, mrangeOptions, filters...)
+	})
+}
+
+// MultiReverseRangeWithOptions queues a timestamp range query across multiple time-series by filters, in reverse direction
+func (q *commandQueue) MultiReverseRangeWithOptions(fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) {
+	q.queueUnkeyed(MREVRANGE_CMD, createMultiRangeCmdArguments(fromTimestamp, toTimestamp, mrangeOptions, q.ns.filters(filters)), q.ns.parseRanges(parseRanges), func(ctx context.Context, client *Client) (interface{}, error) {
+		return client.MultiReverseRangeWithOptionsCtx(ctx, fromTimestamp, toTimestamp, mrangeOptions, filters...)
+	})
+}
+
+// Get queues the retrieval of the last sample of a time-series
+func (q *commandQueue) Get(key string) {
+	key = q.ns.key(key)
+	q.queue(key, GET_CMD, []interface{}{key}, parseDataPoint)
+}
+
+// MultiGetWithOptions queues the retrieval of the last samples matching the specific filters
+func (q *commandQueue) MultiGetWithOptions(multiGetOptions MultiGetOptions, filters ...string) {
+	if len(filters) == 0 {
+		q.queueSkipped(MGET_CMD, []Range(nil))
+		return
+	}
+	q.queueUnkeyed(MGET_CMD, createMultiGetCmdArguments(multiGetOptions, q.ns.filters(filters)), q.ns.parseRanges(parseRangesSingleDataPoint), func(ctx context.Context, client *Client) (interface{}, error) {
+		return client.MultiGetWithOptionsCtx(ctx, multiGetOptions, filters...)
+	})
+}
+
+// Info queues the retrieval of information and statistics on the time-series
+func (q *commandQueue) Info(key string) {
+	key = q.ns.key(key)
+	q.queue(key, INFO_CMD, []interface{}{key}, q.ns.parseInfo(parseInfo))
+}
+
+// QueryIndex queues the retrieval of all the keys matching the filter list
+func (q *commandQueue) QueryIndex(filters ...string) {
+	if len(filters) == 0 {
+		q.queueSkipped(QUERYINDEX_CMD, []string(nil))
+		return
+	}
+	args := make([]interface{}, 0, len(filters))
+	for _, filter := range q.ns.filters(filters) {
+		args = append(args, filter)
+	}
+	q.queueUnkeyed(QUERYINDEX_CMD, args, q.ns.parseKeys(parseStrings), func(ctx context.Context, client *Client) (interface{}, error) {
+		return client.QueryIndexCtx(ctx, filters...)
+	})
+}
+
+// Pipeline queues TimeSeries commands so that they are sent to the server in a single round trip by Exec
+type Pipeline struct {
+	commandQueue
+	client *Client
+}
+
+// Pipeline returns an empty Pipeline sending its commands through the client pool
+func (client *Client) Pipeline() *Pipeline {
+	return &Pipeline{commandQueue: commandQueue{ns: client.namespace()}, client: client}
+}
+
+// Exec sends all the queued commands in a single round trip, and returns their results in queuing order.
+// The returned error is only set when the round trip itself failed. Per-command failures are reported by CommandResult.Err.
+// The pipeline is emptied, so it can be reused afterwards.
+func (p *Pipeline) Exec() ([]CommandResult, error) {
+	return p.ExecCtx(context.Background())
+}
+
+// ExecCtx is like Exec, honoring the deadline and cancellation of ctx
+func (p *Pipeline) ExecCtx(ctx context.Context) (results []CommandResult, err error) {
+	cmds := p.cmds
+	p.cmds = nil
+	if err = p.client.checkQueued(ctx, cmds); err != nil {
+		return nil, err
+	}
+	results = make([]CommandResult, len(cmds))
+	for i, cmd := range cmds {
+		results[i] = CommandResult{Command: cmd.name, Value: cmd.value, Err: cmd.err}
+	}
+	if !hasSendableCommands(cmds) {
+		return
+	}
+	if sharded, ok := p.client.Pool.(ShardedConnPool); ok {
+		err = p.execSharded(ctx, sharded, cmds, results)
+		if err != nil {
+			return nil, err
+		}
+		return
+	}
+	conn, err := getContext(ctx, p.client.Pool)
+	if err != nil {
+		failedQueued(ctx, p.client.Hooks, "", cmds, err)
+		return nil, err
+	}
+	err = runWithContext(ctx, conn, func(conn redis.Conn) error {
+		return sendQueued(ctx, conn, p.client.Hooks, "", cmds, results)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func hasSendableCommands(cmds []queuedCommand) bool {
+	for _, cmd := range cmds {
+		if cmd.err == nil && !cmd.skip {
+			return true
+		}
+	}
+	return false
+}
+
+// sendQueued pipelines the sendable commands on conn, and stores each parsed reply into the matching result.
+// The hooks are invoked around each command, the ones left without a reply completing with the connection error.
+func sendQueued(ctx context.Context, conn redis.Conn, hooks []Hook, node string, cmds []queuedCommand, results []CommandResult) (err error) {
+	hooked := make([]*hookedCommand, len(cmds))
+	defer func() {
+		for _, c := range hooked {
+			c.after(nil, err)
+		}
+	}()
+	for i, cmd := range cmds {
+		if cmd.err != nil || cmd.skip {
+			continue
+		}
+		hooked[i] = beforeCommand(ctx, hooks, node, cmd.key, cmd.name, len(cmd.args))
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for i, cmd := range cmds {
+		if cmd.err != nil || cmd.skip {
+			continue
+		}
+		reply, err := receiveWithDeadline(ctx, conn)
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				return err
+			}
+			results[i].Err = classifyError(err)
+			hooked[i].after(nil, results[i].Err)
+			continue
+		}
+		hooked[i].after(reply, nil)
+		results[i].Value, results[i].Err = cmd.parse(reply)
+	}
+	return nil
+}
+
+// failedQueued invokes the hooks of the sendable commands, borrowing a connection to send them failed with err
+func failedQueued(ctx context.Context, hooks []Hook, node string, cmds []queuedCommand, err error) {
+	for _, cmd := range cmds {
+		if cmd.err == nil && !cmd.skip {
+			failedCommand(ctx, hooks, node, cmd.key, cmd.name, len(cmd.args), err)
+		}
+	}
+}
+
+// execSharded pipelines the keyed commands on the node owning their key, one round trip per node,
+// while the commands acting on several keys are issued through the Client so that they are split or fanned out
+func (p *Pipeline) execSharded(ctx context.Context, pool ShardedConnPool, cmds []queuedCommand, results []CommandResult) error {
+	byNode := make(map[string][]int)
+	for i, cmd := range cmds {
+		if cmd.err != nil || cmd.skip || cmd.run != nil {
+			continue
+		}
+		node, err := pool.NodeForKeyContext(ctx, cmd.key)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		byNode[node] = append(byNode[node], i)
+	}
+	errs := make(chan error, len(byNode))
+	for node, indexes := range byNode {
+		go func(node string, indexes []int) {
+			nodeCmds := make([]queuedCommand, len(indexes))
+			nodeResults := make([]CommandResult, len(indexes))
+			for j, i := range indexes {
+				nodeCmds[j] = cmds[i]
+				nodeResults[j] = results[i]
+			}
+			conn, err := getNodeContext(ctx, pool, node)
+			if err != nil {
+				failedQueued(ctx, p.client.Hooks, node, nodeCmds, err)
+			} else {
+				err = runWithContext(ctx, conn, func(conn redis.Conn) error {
+					return sendQueued(ctx, conn, p.client.Hooks, node, nodeCmds, nodeResults)
+				})
+			}
+			if err == nil {
+				for j, i := range indexes {
+					results[i] = nodeResults[j]
+				}
+			}
+			errs <- err
+		}(node, indexes)
+	}
+	var firstErr error
+	for range byNode {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	for i, cmd := range cmds {
+		if cmd.err != nil || cmd.skip || cmd.run == nil {
+			continue
+		}
+		results[i].Value, results[i].Err = cmd.run(ctx, p.client)
+	}
+	return nil
+}
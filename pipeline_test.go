@@ -0,0 +1,141 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline_Exec(t *testing.T) {
+	var sent []string
+	pool := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		sent = append(sent, cmd)
+		switch cmd {
+		case ADD_CMD:
+			return int64(10), nil
+		case RANGE_CMD:
+			return []interface{}{[]interface{}{int64(10), []byte("1.5")}}, nil
+		case QUERYINDEX_CMD:
+			return []interface{}{[]byte("a"), []byte("b")}, nil
+		case INFO_CMD:
+			return nil, redis.Error("ERR TSDB: the key does not exist")
+		}
+		return "OK", nil
+	}}
+	c := &Client{Pool: pool, Name: "test"}
+	p := c.Pipeline()
+	p.Add("a", 10, 1.5)
+	p.CreateKeyWithOptions("b", CreateOptions{RetentionMSecs: time.Microsecond})
+	p.RangeWithOptions("a", 0, 100, DefaultRangeOptions)
+	p.MultiGetWithOptions(DefaultMultiGetOptions)
+	p.Info("missing")
+	p.QueryIndex("a=1")
+	assert.Equal(t, 6, p.Len())
+
+	results, err := p.Exec()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, p.Len())
+	assert.Equal(t, []string{ADD_CMD, RANGE_CMD, INFO_CMD, QUERYINDEX_CMD}, sent)
+	assert.Len(t, results, 6)
+
+	ts, err := results[0].Int64()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), ts)
+	assert.NotNil(t, results[1].Err)
+	assert.Equal(t, CREATE_CMD, results[1].Command)
+	dataPoints, err := results[2].DataPoints()
+	assert.Nil(t, err)
+	assert.Equal(t, []DataPoint{{10, 1.5}}, dataPoints)
+	assert.Nil(t, results[3].Err)
+	assert.Nil(t, results[3].Value)
+	_, err = results[4].KeyInfo()
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+	assert.EqualError(t, err, "ERR TSDB: the key does not exist")
+	keys, err := results[5].Strings()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, keys)
+	_, err = results[5].Ranges()
+	assert.NotNil(t, err)
+}
+
+func TestPipeline_ExecCtx(t *testing.T) {
+	c := &Client{Pool: &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return int64(1), nil
+	}}, Name: "test"}
+	p := c.Pipeline()
+	results, err := p.Exec()
+	assert.Nil(t, err)
+	assert.Len(t, results, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Add("a", 1, 1)
+	results, err = p.ExecCtx(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, results)
+}
+
+func TestPipeline_Skipped(t *testing.T) {
+	c := &Client{Pool: &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		t.Errorf("%s sent", cmd)
+		return nil, nil
+	}}, Name: "test"}
+	p := c.Pipeline()
+	p.MultiAdd()
+	p.MultiGetWithOptions(DefaultMultiGetOptions)
+	p.QueryIndex()
+	results, err := p.Exec()
+	assert.Nil(t, err)
+
+	values, err := results[0].Values()
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+	ranges, err := results[1].Ranges()
+	assert.Nil(t, err)
+	assert.Empty(t, ranges)
+	keys, err := results[2].Strings()
+	assert.Nil(t, err)
+	assert.Empty(t, keys)
+
+	clientValues, err := c.MultiAdd()
+	assert.Nil(t, err)
+	assert.Equal(t, clientValues, values)
+	clientRanges, err := c.MultiGetWithOptions(DefaultMultiGetOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, clientRanges, ranges)
+	clientKeys, err := c.QueryIndex()
+	assert.Nil(t, err)
+	assert.Equal(t, clientKeys, keys)
+}
+
+func TestPipeline_MultiAddErrors(t *testing.T) {
+	maddReply := func() []interface{} {
+		return []interface{}{int64(1), redis.Error("ERR TSDB: the key does not exist")}
+	}
+	pool := &stubPool{handler: func(cmd string, args ...interface{}) (interface{}, error) {
+		return maddReply(), nil
+	}}
+	c := &Client{Pool: pool, Name: "test"}
+	p := c.Pipeline()
+	p.MultiAdd(Sample{"a", DataPoint{1, 1}}, Sample{"missing", DataPoint{1, 1}})
+	results, err := p.Exec()
+	assert.Nil(t, err)
+	values, err := results[0].Values()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), values[0])
+	assert.True(t, errors.Is(values[1].(error), ErrKeyNotFound))
+
+	c.Pool = &stubPool{handler: txHandler([]interface{}{maddReply()}, nil)}
+	tx, err := c.Tx()
+	assert.Nil(t, err)
+	tx.MultiAdd(Sample{"a", DataPoint{1, 1}}, Sample{"missing", DataPoint{1, 1}})
+	results, err = tx.Exec()
+	assert.Nil(t, err)
+	values, err = results[0].Values()
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(values[1].(error), ErrKeyNotFound))
+}
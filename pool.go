@@ -1,91 +1,217 @@
 package redis_timeseries_go
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"net"
 	"sync"
-	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
 type ConnPool interface {
 	Get() redis.Conn
+	// GetContext gets a connection, honoring the context deadline and cancellation while waiting or dialing
+	GetContext(ctx context.Context) (redis.Conn, error)
 	Close() error
 }
 
+// ShardedConnPool is implemented by pools spreading the keyspace across several nodes.
+// Client routes the commands acting on a single key to the node owning it, splits TS.MADD per node,
+// and fans TS.MRANGE, TS.MREVRANGE, TS.MGET and TS.QUERYINDEX out to every node, merging the results.
+type ShardedConnPool interface {
+	ConnPool
+	// NodeForKeyContext returns the address of the node owning key, honoring ctx when the nodes must be discovered
+	NodeForKeyContext(ctx context.Context, key string) (string, error)
+	// NodesContext returns the addresses of all the nodes sharing the keyspace, honoring ctx when they must be discovered
+	NodesContext(ctx context.Context) ([]string, error)
+	// GetNodeContext gets a connection to the given node
+	GetNodeContext(ctx context.Context, node string) (redis.Conn, error)
+}
+
+// ReadOnlyConnPool is implemented by pools able to serve read-only commands from replicas.
+// Client borrows the connections for TS.RANGE, TS.REVRANGE, TS.MRANGE, TS.MREVRANGE, TS.GET, TS.MGET, TS.INFO
+// and TS.QUERYINDEX through GetReadOnlyContext.
+type ReadOnlyConnPool interface {
+	ConnPool
+	// GetReadOnlyContext gets a connection suitable for read-only commands
+	GetReadOnlyContext(ctx context.Context) (redis.Conn, error)
+}
+
+// readOnlyCommands are the commands which may be served by replicas
+var readOnlyCommands = map[string]bool{
+	RANGE_CMD:      true,
+	REVRANGE_CMD:   true,
+	MRANGE_CMD:     true,
+	MREVRANGE_CMD:  true,
+	GET_CMD:        true,
+	MGET_CMD:       true,
+	INFO_CMD:       true,
+	QUERYINDEX_CMD: true,
+}
+
 type SingleHostPool struct {
 	*redis.Pool
+	host     string
+	counters *poolCounters
 }
 
 func NewSingleHostPool(host string, authPass *string) *SingleHostPool {
-	ret := &redis.Pool{
-		Dial:         dialFuncWrapper(host, authPass),
-		TestOnBorrow: testOnBorrow,
-		MaxIdle:      maxConns,
-	}
+	return NewSingleHostPoolWithOptions(host, withAuthPass(authPass))
+}
+
+// NewSingleHostPoolWithOptions creates a pool of connections to host, dialed and limited according to options
+func NewSingleHostPoolWithOptions(host string, options ClientOptions) *SingleHostPool {
+	counters := &poolCounters{}
+	return &SingleHostPool{Pool: counters.instrument(options.newPool(host)), host: host, counters: counters}
+}
+
+func (p *SingleHostPool) Get() redis.Conn {
+	return abortablePool{p.Pool}.Get()
+}
 
-	return &SingleHostPool{ret}
+// GetContext borrows a connection to the host, which is discarded when a command is abandoned on cancellation
+func (p *SingleHostPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	return getPooled(ctx, p.Pool)
 }
 
+// MultiHostPool spreads the connections across several hosts serving the same data.
+// Each connection is borrowed from a host selected by the Strategy of its options, among the hosts which are not ejected
+// by the health checks, and whose circuit breaker is not open when ClientOptions.CircuitBreaker is set.
+// When dialing the selected host fails, the connection is borrowed from another one.
 type MultiHostPool struct {
 	sync.Mutex
-	pools    map[string]*redis.Pool
-	hosts    []string
-	authPass *string
+	pools   map[string]*redis.Pool
+	hosts   []string
+	options ClientOptions
+	// health of the hosts, by address
+	health map[string]*hostHealth
+	// stop ends the health checks, nil when they are disabled
+	stop chan struct{}
 }
 
 func NewMultiHostPool(hosts []string, authPass *string) *MultiHostPool {
-	return &MultiHostPool{
-		pools:    make(map[string]*redis.Pool, len(hosts)),
-		hosts:    hosts,
-		authPass: authPass,
+	return NewMultiHostPoolWithOptions(hosts, withAuthPass(authPass))
+}
+
+// NewMultiHostPoolWithOptions creates a pool per host, each dialed and limited according to options.
+// When options.HealthCheckInterval is set, the hosts are checked in the background until the pool is closed.
+func NewMultiHostPoolWithOptions(hosts []string, options ClientOptions) *MultiHostPool {
+	p := &MultiHostPool{
+		pools:   make(map[string]*redis.Pool, len(hosts)),
+		hosts:   hosts,
+		options: options,
+		health:  make(map[string]*hostHealth, len(hosts)),
+	}
+	if options.HealthCheckInterval > 0 {
+		p.stop = make(chan struct{})
+		go p.healthCheck(options.HealthCheckInterval, p.stop)
 	}
+	return p
 }
 
 func (p *MultiHostPool) Get() redis.Conn {
-	p.Lock()
-	defer p.Unlock()
-
-	host := p.hosts[rand.Intn(len(p.hosts))]
-	pool, found := p.pools[host]
+	conn, err := p.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return conn
+}
 
-	if !found {
-		pool = &redis.Pool{
-			Dial:         dialFuncWrapper(host, p.authPass),
-			TestOnBorrow: testOnBorrow,
-			MaxIdle:      maxConns,
+// GetContext borrows a connection from the host selected by the strategy, retrying on up to BorrowRetries other hosts
+// when the borrow fails. Only the hosts which can not be dialed are ejected, a host whose pool is exhausted being busy
+// rather than down. ErrCircuitOpen is returned when the circuit breaker of every host is open.
+func (p *MultiHostPool) GetContext(ctx context.Context) (conn redis.Conn, err error) {
+	tried := make(map[string]bool, p.options.BorrowRetries+1)
+	for attempt := 0; attempt <= p.options.BorrowRetries; attempt++ {
+		host, pool, breaker := p.pickPool(tried)
+		if pool == nil {
+			break
 		}
-		p.pools[host] = pool
+		if breaker != nil {
+			conn, err = breaker.borrow(ctx, abortablePool{pool})
+		} else {
+			conn, err = getPooled(ctx, pool)
+		}
+		if err == nil || ctx.Err() != nil {
+			return conn, err
+		}
+		tried[host] = true
+		if isDialError(err) {
+			p.ejectHost(host)
+		}
+	}
+	if err == nil {
+		if p.circuitsOpen() {
+			return nil, ErrCircuitOpen
+		}
+		err = fmt.Errorf("no host available among %v", p.hosts)
 	}
+	return nil, err
+}
 
-	return pool.Get()
+// isDialError tells whether err is a network error, telling the host can not be reached
+func isDialError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
 
-func dialFuncWrapper(host string, authPass *string) func() (redis.Conn, error) {
-	return func() (redis.Conn, error) {
-		conn, err := redis.Dial("tcp", host)
-		if err != nil {
-			return conn, err
+// pickPool selects a host with the strategy, and returns it along with its pool and its circuit breaker, if any.
+// The ejected hosts are only selected when every other one is, while the excluded ones and the ones whose
+// circuit breaker is open never are.
+func (p *MultiHostPool) pickPool(exclude map[string]bool) (string, *redis.Pool, *CircuitBreaker) {
+	p.Lock()
+	defer p.Unlock()
+
+	candidates := make([]HostStatus, 0, len(p.hosts))
+	for _, host := range p.hosts {
+		if status := p.hostStatus(host); !exclude[host] && !status.Ejected && status.Circuit != CircuitOpen {
+			candidates = append(candidates, status)
 		}
-		if authPass != nil {
-			_, err = conn.Do("AUTH", *authPass)
+	}
+	if len(candidates) == 0 {
+		// every host is down, try them anyway rather than failing outright
+		for _, host := range p.hosts {
+			if status := p.hostStatus(host); !exclude[host] && status.Circuit != CircuitOpen {
+				candidates = append(candidates, status)
+			}
 		}
-		return conn, err
 	}
+	if len(candidates) == 0 {
+		return "", nil, nil
+	}
+	strategy := p.options.Strategy
+	if strategy == nil {
+		strategy = randomStrategy{}
+	}
+	host := candidates[strategy.Pick(candidates)].Addr
+	return host, p.hostPool(host), p.hostHealth(host).breaker
 }
 
-func testOnBorrow(c redis.Conn, t time.Time) (err error) {
-	if time.Since(t) > time.Millisecond {
-		_, err = c.Do("PING")
+// hostPool returns the pool of host, creating it on first use. The lock must be held.
+func (p *MultiHostPool) hostPool(host string) *redis.Pool {
+	pool, found := p.pools[host]
+	if !found {
+		pool = p.hostHealth(host).counters.instrument(p.options.newPool(host))
+		p.pools[host] = pool
 	}
-	return err
+	return pool
 }
 
 func (p *MultiHostPool) Close() (err error) {
 	p.Lock()
 	defer p.Unlock()
-	for host, pool := range p.pools {
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	return closePools(p.pools)
+}
+
+// closePools closes every pool, reporting the hosts whose pool failed to close
+func closePools(pools map[string]*redis.Pool) (err error) {
+	for host, pool := range pools {
 		poolErr := pool.Close()
 		//preserve pool error if not nil but continue
 		if poolErr != nil {
@@ -98,3 +224,14 @@ func (p *MultiHostPool) Close() (err error) {
 	}
 	return
 }
+
+// errorConn is a redis.Conn failing every operation with the same error.
+// It is returned by the Get methods which can not report errors on their own.
+type errorConn struct{ err error }
+
+func (ec errorConn) Do(string, ...interface{}) (interface{}, error) { return nil, ec.err }
+func (ec errorConn) Send(string, ...interface{}) error              { return ec.err }
+func (ec errorConn) Err() error                                     { return ec.err }
+func (ec errorConn) Close() error                                   { return nil }
+func (ec errorConn) Flush() error                                   { return ec.err }
+func (ec errorConn) Receive() (interface{}, error)                  { return nil, ec.err }
@@ -0,0 +1,112 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// PoolStats are the statistics of the connections to a host
+type PoolStats struct {
+	// PoolStats holds the active, idle and waited for connections, as reported by redis.Pool.
+	// ActiveCount includes the idle connections and the connections in use.
+	redis.PoolStats
+	// DialFailures is the number of connections which could not be dialed
+	DialFailures uint64
+	// TestFailures is the number of idle connections which failed the test on borrow, and were closed
+	TestFailures uint64
+}
+
+// add adds the statistics of other to stats
+func (stats *PoolStats) add(other PoolStats) {
+	stats.ActiveCount += other.ActiveCount
+	stats.IdleCount += other.IdleCount
+	stats.WaitCount += other.WaitCount
+	stats.WaitDuration += other.WaitDuration
+	stats.DialFailures += other.DialFailures
+	stats.TestFailures += other.TestFailures
+}
+
+// ConnPoolStats are the statistics of a pool, summed over its hosts, along with the ones of each host by address
+type ConnPoolStats struct {
+	PoolStats
+	Hosts map[string]PoolStats
+}
+
+// StatsConnPool is implemented by the pools reporting statistics about their connections,
+// such as SingleHostPool and MultiHostPool
+type StatsConnPool interface {
+	ConnPool
+	// ConnStats returns the statistics of the connections of the pool
+	ConnStats() ConnPoolStats
+}
+
+// poolCounters counts the failures of the connections of a redis.Pool, which it does not report itself
+type poolCounters struct {
+	dialFailures uint64
+	testFailures uint64
+}
+
+// instrument wraps the dial and test on borrow functions of pool to count their failures, and returns pool
+func (counters *poolCounters) instrument(pool *redis.Pool) *redis.Pool {
+	if dial := pool.DialContext; dial != nil {
+		pool.DialContext = func(ctx context.Context) (redis.Conn, error) {
+			conn, err := dial(ctx)
+			if err != nil {
+				atomic.AddUint64(&counters.dialFailures, 1)
+			}
+			return conn, err
+		}
+	}
+	if test := pool.TestOnBorrow; test != nil {
+		pool.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+			err := test(c, t)
+			if err != nil {
+				atomic.AddUint64(&counters.testFailures, 1)
+			}
+			return err
+		}
+	}
+	return pool
+}
+
+// stats returns the statistics of pool, along with the failures counted
+func (counters *poolCounters) stats(pool *redis.Pool) PoolStats {
+	return PoolStats{
+		PoolStats:    pool.Stats(),
+		DialFailures: atomic.LoadUint64(&counters.dialFailures),
+		TestFailures: atomic.LoadUint64(&counters.testFailures),
+	}
+}
+
+// ConnStats returns the statistics of the connections to the host of the pool, along with the failures counted.
+// Stats still returns the redis.PoolStats of the embedded redis.Pool.
+func (p *SingleHostPool) ConnStats() ConnPoolStats {
+	counters := p.counters
+	if counters == nil {
+		counters = &poolCounters{}
+	}
+	stats := counters.stats(p.Pool)
+	return ConnPoolStats{PoolStats: stats, Hosts: map[string]PoolStats{p.host: stats}}
+}
+
+// ConnStats returns the statistics of the connections to each host, the hosts which were not used yet reporting
+// no connection
+func (p *MultiHostPool) ConnStats() ConnPoolStats {
+	p.Lock()
+	defer p.Unlock()
+	stats := ConnPoolStats{Hosts: make(map[string]PoolStats, len(p.hosts))}
+	for _, host := range p.hosts {
+		hostStats := PoolStats{}
+		if pool, found := p.pools[host]; found {
+			hostStats = p.hostHealth(host).counters.stats(pool)
+		}
+		stats.Hosts[host] = hostStats
+	}
+	for _, hostStats := range stats.Hosts {
+		stats.add(hostStats)
+	}
+	return stats
+}
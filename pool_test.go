@@ -1,6 +1,9 @@
 package redis_timeseries_go
 
 import (
+	"context"
+	"sync/atomic"
+
 	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -94,3 +97,151 @@ func TestMultiHostPool_Close(t *testing.T) {
 		})
 	}
 }
+
+// stubConn is an in-memory redis.Conn whose replies are produced by a handler function
+type stubConn struct {
+	handler func(cmd string, args ...interface{}) (interface{}, error)
+	pending []interface{}
+	closed  int32
+}
+
+func (c *stubConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+func (c *stubConn) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+func (c *stubConn) Err() error {
+	return nil
+}
+
+func (c *stubConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		return nil, nil
+	}
+	return c.handler(cmd, args...)
+}
+
+func (c *stubConn) Send(cmd string, args ...interface{}) error {
+	reply, err := c.handler(cmd, args...)
+	if err != nil {
+		if rerr, ok := err.(redis.Error); ok {
+			c.pending = append(c.pending, rerr)
+			return nil
+		}
+		return err
+	}
+	c.pending = append(c.pending, reply)
+	return nil
+}
+
+func (c *stubConn) Flush() error {
+	return nil
+}
+
+func (c *stubConn) Receive() (interface{}, error) {
+	reply := c.pending[0]
+	c.pending = c.pending[1:]
+	if rerr, ok := reply.(redis.Error); ok {
+		return nil, rerr
+	}
+	return reply, nil
+}
+
+// stubPool is a ConnPool handing out stubConn instances sharing the same handler
+type stubPool struct {
+	handler func(cmd string, args ...interface{}) (interface{}, error)
+}
+
+func (p *stubPool) Get() redis.Conn {
+	return &stubConn{handler: p.handler}
+}
+
+func (p *stubPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.Get(), nil
+}
+
+func (p *stubPool) Close() error {
+	return nil
+}
+
+func TestMultiHostPool_GetContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := NewMultiHostPool([]string{"localhost:6379", "localhost:6380"}, nil)
+	defer p.Close()
+	conn, err := getContext(ctx, p)
+	assert.Nil(t, conn)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestSingleHostPool_Stats(t *testing.T) {
+	var failing int32
+	s := newRespServer(t, func(args []string) interface{} {
+		if atomic.LoadInt32(&failing) == 1 {
+			return redis.Error("LOADING")
+		}
+		return respStatus("PONG")
+	})
+	defer s.close()
+	pool := NewSingleHostPoolWithOptions(s.addr(), *NewClientOptions().SetTestOnBorrowInterval(0))
+	defer pool.Close()
+
+	conn := pool.Get()
+	_, err := conn.Do("PING")
+	assert.Nil(t, err)
+	stats := pool.ConnStats()
+	assert.Equal(t, 1, stats.ActiveCount)
+	assert.Equal(t, 0, stats.IdleCount)
+	assert.Equal(t, map[string]PoolStats{s.addr(): stats.PoolStats}, stats.Hosts)
+
+	conn.Close()
+	atomic.StoreInt32(&failing, 1)
+	conn = pool.Get()
+	conn.Close()
+	stats = pool.ConnStats()
+	assert.Equal(t, uint64(1), stats.TestFailures)
+	assert.Equal(t, uint64(0), stats.DialFailures)
+	// the redigo statistics are still promoted from the embedded redis.Pool
+	var redisStats redis.PoolStats = pool.Stats()
+	assert.Equal(t, stats.PoolStats.PoolStats, redisStats)
+
+	dead := NewSingleHostPool(deadAddr(t), nil)
+	defer dead.Close()
+	_, err = dead.Get().Do("PING")
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(1), dead.ConnStats().DialFailures)
+	assert.Equal(t, 0, dead.ConnStats().ActiveCount)
+}
+
+func TestMultiHostPool_Stats(t *testing.T) {
+	s := newRespServer(t, pongHandler)
+	defer s.close()
+	dead := deadAddr(t)
+	pool := NewMultiHostPoolWithOptions([]string{dead, s.addr()}, *NewClientOptions().SetStrategy(NewRoundRobinStrategy()))
+	defer pool.Close()
+	assert.Equal(t, ConnPoolStats{Hosts: map[string]PoolStats{dead: {}, s.addr(): {}}}, pool.ConnStats())
+
+	var conns []redis.Conn
+	for n := 0; n < 2; n++ {
+		conn, err := pool.GetContext(context.Background())
+		assert.Nil(t, err)
+		conns = append(conns, conn)
+	}
+	stats := pool.ConnStats()
+	assert.Len(t, stats.Hosts, 2)
+	assert.Equal(t, 2, stats.Hosts[s.addr()].ActiveCount)
+	assert.True(t, stats.Hosts[dead].DialFailures > 0)
+	assert.Equal(t, 2, stats.ActiveCount)
+	assert.Equal(t, stats.Hosts[dead].DialFailures, stats.DialFailures)
+	for _, conn := range conns {
+		conn.Close()
+	}
+	assert.Equal(t, 2, pool.ConnStats().IdleCount)
+}
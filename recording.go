@@ -0,0 +1,490 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrReplayMismatch is returned by the connections of a ReplayPool when a command differs from the recorded one,
+// or when the recording is exhausted
+var ErrReplayMismatch = errors.New("command does not match the recording")
+
+// Exchange is a command sent to the server along with its reply, as captured by a RecordingPool.
+// The arguments are recorded as sent on the wire, so that the recordings lock down the exact serialization.
+type Exchange struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Reply is the reply of the server, nil for a nil reply, encoded by its RESP type as
+	// {"status": "OK"}, {"integer": 1}, {"bulk": "value"}, {"error": "ERR message"} or {"array": [...]}
+	Reply *RecordedReply `json:"reply"`
+	// Err is the message of the error which prevented receiving a reply, such as a network error
+	Err string `json:"err,omitempty"`
+}
+
+// RecordedReply is the JSON encoding of a reply, where exactly one of the fields is set
+type RecordedReply struct {
+	Status  *string `json:"status,omitempty"`
+	Integer *int64  `json:"integer,omitempty"`
+	Bulk    *string `json:"bulk,omitempty"`
+	Error   *string `json:"error,omitempty"`
+	// Array is a pointer so that an empty array is still encoded
+	Array *[]*RecordedReply `json:"array,omitempty"`
+}
+
+// recordReply encodes a reply as returned by redigo, the redis.Error replies being given as err
+func recordReply(reply interface{}, err error) (*RecordedReply, string) {
+	if rerr, ok := err.(redis.Error); ok {
+		message := string(rerr)
+		return &RecordedReply{Error: &message}, ""
+	}
+	if err != nil {
+		return nil, err.Error()
+	}
+	return encodeReply(reply), ""
+}
+
+func encodeReply(reply interface{}) *RecordedReply {
+	switch reply := reply.(type) {
+	case nil:
+		return nil
+	case string:
+		return &RecordedReply{Status: &reply}
+	case int64:
+		return &RecordedReply{Integer: &reply}
+	case []byte:
+		bulk := string(reply)
+		return &RecordedReply{Bulk: &bulk}
+	case redis.Error:
+		message := string(reply)
+		return &RecordedReply{Error: &message}
+	case []interface{}:
+		array := make([]*RecordedReply, len(reply))
+		for i, element := range reply {
+			array[i] = encodeReply(element)
+		}
+		return &RecordedReply{Array: &array}
+	}
+	bulk := fmt.Sprint(reply)
+	return &RecordedReply{Bulk: &bulk}
+}
+
+// value decodes the reply as returned by redigo, the error replies being returned as redis.Error
+func (r *RecordedReply) value() interface{} {
+	switch {
+	case r == nil:
+		return nil
+	case r.Status != nil:
+		return *r.Status
+	case r.Integer != nil:
+		return *r.Integer
+	case r.Bulk != nil:
+		return []byte(*r.Bulk)
+	case r.Error != nil:
+		return redis.Error(*r.Error)
+	}
+	if r.Array == nil {
+		return nil
+	}
+	array := make([]interface{}, len(*r.Array))
+	for i, element := range *r.Array {
+		array[i] = element.value()
+	}
+	return array
+}
+
+// reply returns the recorded reply and error of the exchange, as returned by Conn.Do
+func (e Exchange) reply() (interface{}, error) {
+	if e.Err != "" {
+		return nil, errors.New(e.Err)
+	}
+	reply := e.Reply.value()
+	if rerr, ok := reply.(redis.Error); ok {
+		return nil, rerr
+	}
+	return reply, nil
+}
+
+func (e Exchange) String() string {
+	return strings.TrimSpace(e.Command + " " + strings.Join(e.Args, " "))
+}
+
+// formatArg formats a command argument as redigo writes it on the wire
+func formatArg(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case []byte:
+		return string(arg)
+	case int:
+		return strconv.Itoa(arg)
+	case int64:
+		return strconv.FormatInt(arg, 10)
+	case float64:
+		return strconv.FormatFloat(arg, 'g', -1, 64)
+	case bool:
+		if arg {
+			return "1"
+		}
+		return "0"
+	case nil:
+		return ""
+	case redis.Argument:
+		return formatArg(arg.RedisArg())
+	}
+	return fmt.Sprint(arg)
+}
+
+func newExchange(cmd string, args []interface{}) Exchange {
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		formatted[i] = formatArg(arg)
+	}
+	return Exchange{Command: cmd, Args: formatted}
+}
+
+// ReadExchanges reads the exchanges of a golden file written by RecordingPool.WriteGolden
+func ReadExchanges(path string) ([]Exchange, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var exchanges []Exchange
+	if err := json.Unmarshal(data, &exchanges); err != nil {
+		return nil, fmt.Errorf("invalid golden file %s: %v", path, err)
+	}
+	return exchanges, nil
+}
+
+// RecordingPool wraps a ConnPool and captures every command sent through its connections along with the reply,
+// in the order the replies are received. The transcript can be written to a golden file and served by a ReplayPool.
+// It only exposes the ConnPool interface of the pool it wraps, so sharded pools should not be wrapped.
+type RecordingPool struct {
+	ConnPool
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+// NewRecordingPool wraps pool with a RecordingPool
+func NewRecordingPool(pool ConnPool) *RecordingPool {
+	return &RecordingPool{ConnPool: pool}
+}
+
+func (p *RecordingPool) Get() redis.Conn {
+	return &recordingConn{Conn: p.ConnPool.Get(), pool: p}
+}
+
+func (p *RecordingPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	conn, err := p.ConnPool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Conn: conn, pool: p}, nil
+}
+
+// Exchanges returns a copy of the exchanges recorded so far
+func (p *RecordingPool) Exchanges() []Exchange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Exchange(nil), p.exchanges...)
+}
+
+// Reset discards the exchanges recorded so far
+func (p *RecordingPool) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.exchanges = nil
+}
+
+// WriteGolden writes the exchanges recorded so far to path as indented JSON, to be read by ReadExchanges
+func (p *RecordingPool) WriteGolden(path string) error {
+	data, err := json.MarshalIndent(p.Exchanges(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func (p *RecordingPool) record(exchange Exchange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.exchanges = append(p.exchanges, exchange)
+}
+
+// recordingConn records the commands it issues, the pipelined ones once their reply is received
+type recordingConn struct {
+	redis.Conn
+	pool    *RecordingPool
+	pending []Exchange
+}
+
+func (c *recordingConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(cmd, args, func() (interface{}, error) { return c.Conn.Do(cmd, args...) })
+}
+
+func (c *recordingConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(cmd, args, func() (interface{}, error) { return redis.DoWithTimeout(c.Conn, timeout, cmd, args...) })
+}
+
+// do issues a command, or flushes the pipelined ones for an empty cmd. Once commands are pipelined,
+// they are sent along with cmd and their replies received one by one, so that each of them is recorded.
+func (c *recordingConn) do(cmd string, args []interface{}, do func() (interface{}, error)) (interface{}, error) {
+	if len(c.pending) == 0 {
+		if cmd == "" {
+			return do()
+		}
+		reply, err := do()
+		exchange := newExchange(cmd, args)
+		exchange.Reply, exchange.Err = recordReply(reply, err)
+		c.pool.record(exchange)
+		return reply, err
+	}
+	if cmd != "" {
+		if err := c.Send(cmd, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	return receivePending(c, len(c.pending))
+}
+
+func (c *recordingConn) Send(cmd string, args ...interface{}) error {
+	if err := c.Conn.Send(cmd, args...); err != nil {
+		return err
+	}
+	c.pending = append(c.pending, newExchange(cmd, args))
+	return nil
+}
+
+func (c *recordingConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.received(reply, err)
+	return reply, err
+}
+
+func (c *recordingConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := redis.ReceiveWithTimeout(c.Conn, timeout)
+	c.received(reply, err)
+	return reply, err
+}
+
+// abort aborts the wrapped connection, whose in-flight exchange is recorded with the resulting error
+func (c *recordingConn) abort() {
+	abortConn(c.Conn)
+}
+
+// received records the reply of the oldest pipelined command
+func (c *recordingConn) received(reply interface{}, err error) {
+	if len(c.pending) == 0 {
+		return
+	}
+	exchange := c.pending[0]
+	c.pending = c.pending[1:]
+	exchange.Reply, exchange.Err = recordReply(reply, err)
+	c.pool.record(exchange)
+}
+
+// receivePending receives the replies of n pipelined commands, and returns the last one along with the first
+// error reply, as redigo does when flushing them with Do
+func receivePending(conn redis.Conn, n int) (interface{}, error) {
+	var reply interface{}
+	var replyErr error
+	for i := 0; i < n; i++ {
+		r, err := conn.Receive()
+		if _, ok := err.(redis.Error); !ok && err != nil {
+			return nil, err
+		}
+		if replyErr == nil {
+			replyErr = err
+		}
+		reply = r
+	}
+	return reply, replyErr
+}
+
+// ReplayMismatchError is returned when a command differs from the recorded one, or when the recording is exhausted
+type ReplayMismatchError struct {
+	// Index is the position of the command in the recording
+	Index int
+	// Got is the command issued
+	Got Exchange
+	// Want is the recorded command, nil when the recording is exhausted
+	Want *Exchange
+}
+
+func (e *ReplayMismatchError) Error() string {
+	if e.Want == nil {
+		return fmt.Sprintf("replay: unexpected command #%d %q, the recording is exhausted", e.Index, e.Got.String())
+	}
+	return fmt.Sprintf("replay: command #%d %q does not match the recorded %q", e.Index, e.Got.String(), e.Want.String())
+}
+
+// Unwrap returns ErrReplayMismatch
+func (e *ReplayMismatchError) Unwrap() error {
+	return ErrReplayMismatch
+}
+
+// ReplayPool serves the replies of recorded exchanges, in order, without any server.
+// Each command must match the next recorded one, command name and arguments as sent on the wire,
+// or a *ReplayMismatchError is returned and kept as the error of the pool.
+// The commands are matched in order whatever the connection they are issued on.
+type ReplayPool struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+	next      int
+	err       error
+}
+
+// NewReplayPool returns a ReplayPool serving exchanges
+func NewReplayPool(exchanges []Exchange) *ReplayPool {
+	return &ReplayPool{exchanges: exchanges}
+}
+
+// NewReplayPoolFromFile returns a ReplayPool serving the exchanges of a golden file written by RecordingPool.WriteGolden
+func NewReplayPoolFromFile(path string) (*ReplayPool, error) {
+	exchanges, err := ReadExchanges(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayPool(exchanges), nil
+}
+
+func (p *ReplayPool) Get() redis.Conn {
+	return &replayConn{pool: p}
+}
+
+func (p *ReplayPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &replayConn{pool: p}, nil
+}
+
+func (p *ReplayPool) Close() error {
+	return nil
+}
+
+// Err returns the first mismatch, if any
+func (p *ReplayPool) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Remaining returns the recorded exchanges which were not replayed yet
+func (p *ReplayPool) Remaining() []Exchange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Exchange(nil), p.exchanges[p.next:]...)
+}
+
+// replay matches a command against the next recorded exchange, and consumes it
+func (p *ReplayPool) replay(cmd string, args []interface{}) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	got := newExchange(cmd, args)
+	if p.next >= len(p.exchanges) {
+		return nil, p.mismatch(&ReplayMismatchError{Index: p.next, Got: got})
+	}
+	want := p.exchanges[p.next]
+	if got.Command != want.Command || !sameArgs(got.Args, want.Args) {
+		return nil, p.mismatch(&ReplayMismatchError{Index: p.next, Got: got, Want: &want})
+	}
+	p.next++
+	return want.reply()
+}
+
+func sameArgs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *ReplayPool) mismatch(err *ReplayMismatchError) error {
+	if p.err == nil {
+		p.err = err
+	}
+	return err
+}
+
+// replayConn serves the recorded replies, the pipelined commands being matched as they are sent
+type replayConn struct {
+	pool    *ReplayPool
+	pending []replayed
+	closed  bool
+}
+
+type replayed struct {
+	reply interface{}
+	err   error
+}
+
+func (c *replayConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *replayConn) Err() error {
+	if c.closed {
+		return errors.New("redigo: closed connection")
+	}
+	return nil
+}
+
+func (c *replayConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd != "" {
+		if len(c.pending) == 0 {
+			return c.pool.replay(cmd, args)
+		}
+		if err := c.Send(cmd, args...); err != nil {
+			return nil, err
+		}
+	}
+	return receivePending(c, len(c.pending))
+}
+
+func (c *replayConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return c.Do(cmd, args...)
+}
+
+func (c *replayConn) Send(cmd string, args ...interface{}) error {
+	reply, err := c.pool.replay(cmd, args)
+	if _, ok := err.(*ReplayMismatchError); ok {
+		return err
+	}
+	c.pending = append(c.pending, replayed{reply, err})
+	return nil
+}
+
+func (c *replayConn) Flush() error {
+	return nil
+}
+
+func (c *replayConn) Receive() (interface{}, error) {
+	if len(c.pending) == 0 {
+		return nil, errors.New("replay: no pending reply to receive")
+	}
+	r := c.pending[0]
+	c.pending = c.pending[1:]
+	return r.reply, r.err
+}
+
+func (c *replayConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return c.Receive()
+}
@@ -0,0 +1,168 @@
+package redis_timeseries_go
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RedisTimeSeries/redistimeseries-go/tstest"
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of testdata")
+
+// recordingScenario issues commands covering the serialization of the options, returning their results
+func recordingScenario(t *testing.T, client *Client) []interface{} {
+	options := CreateOptions{RetentionMSecs: time.Hour, ChunkSize: 128, DuplicatePolicy: LastDuplicatePolicy,
+		Labels: map[string]string{"sensor": "1", "area": "north", "kind": "temperature"}}
+	assert.NoError(t, client.CreateKeyWithOptions("temperature:1", options))
+	options.Labels = map[string]string{"sensor": "2", "area": "south", "kind": "temperature"}
+	assert.NoError(t, client.CreateKeyWithOptions("temperature:2", options))
+	_, err := client.MultiAdd(Sample{"temperature:1", DataPoint{1000, 20.5}}, Sample{"temperature:2", DataPoint{1000, 18}},
+		Sample{"temperature:1", DataPoint{2000, 21.25}}, Sample{"temperature:2", DataPoint{2500, -1.5}})
+	assert.NoError(t, err)
+	_, err = client.AddWithOptions("temperature:1", 1000, 22, CreateOptions{DuplicatePolicy: MaxDuplicatePolicy})
+	assert.NoError(t, err)
+
+	points, err := client.RangeWithOptions("temperature:1", 0, 3000,
+		*NewRangeOptions().SetAggregation(AvgAggregation, 1000).SetAlign(500).SetCount(10))
+	assert.NoError(t, err)
+	filtered, err := client.ReverseRangeWithOptions("temperature:2", 0, 3000,
+		*NewRangeOptions().SetFilterByTs([]int64{1000, 2500}).SetFilterByValue(-2, 20))
+	assert.NoError(t, err)
+	ranges, err := client.MultiRangeWithOptions(0, 3000,
+		*NewMultiRangeOptions().SetSelectedLabels([]string{"sensor", "area"}).SetAggregation(MaxAggregation, 2000),
+		"kind=temperature", "area!=west")
+	assert.NoError(t, err)
+	grouped, err := client.MultiReverseRangeWithOptions(0, 3000,
+		*NewMultiRangeOptions().SetGroupByReduce("kind", SumReducer).SetWithLabels(true), "kind=temperature")
+	assert.NoError(t, err)
+	info, err := client.Info("temperature:2")
+	assert.NoError(t, err)
+	_, err = client.Info("missing")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	pipeline := client.Pipeline()
+	pipeline.Get("temperature:1")
+	pipeline.QueryIndex("area=north")
+	results, err := pipeline.Exec()
+	assert.NoError(t, err)
+	last, err := results[0].DataPoint()
+	assert.NoError(t, err)
+	keys, err := results[1].Strings()
+	assert.NoError(t, err)
+	return []interface{}{points, filtered, ranges, grouped, info, last, keys}
+}
+
+func TestRecordingPool_Golden(t *testing.T) {
+	golden := filepath.Join("testdata", "recording.golden.json")
+	server := tstest.NewServer()
+	defer server.Close()
+	server.SetModuleVersion(tstest.ModuleVersion16)
+	pool := NewRecordingPool(NewSingleHostPool(server.Addr(), nil))
+	defer pool.Close()
+	recorded := recordingScenario(t, NewClientFromConnPool(pool, "recording"))
+
+	if *updateGolden {
+		assert.NoError(t, os.MkdirAll("testdata", 0755))
+		assert.NoError(t, pool.WriteGolden(golden))
+	}
+	exchanges, err := ReadExchanges(golden)
+	assert.NoError(t, err)
+	assert.Equal(t, exchanges, pool.Exchanges(), "wire format changed, run go test -run TestRecordingPool_Golden -update")
+
+	replay, err := NewReplayPoolFromFile(golden)
+	assert.NoError(t, err)
+	replayed := recordingScenario(t, NewClientFromConnPool(replay, "replay"))
+	assert.NoError(t, replay.Err())
+	assert.Empty(t, replay.Remaining())
+	assert.Equal(t, recorded, replayed)
+}
+
+func TestRecordingPool_WriteGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	pool := NewRecordingPool(&stubPool{func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "TS.GET":
+			return []interface{}{int64(1), []byte("2.5")}, nil
+		case "TS.QUERYINDEX":
+			return []interface{}{}, nil
+		}
+		return nil, errors.New("connection reset")
+	}})
+	conn := pool.Get()
+	conn.Do("TS.GET", "key")                  //nolint:errcheck
+	conn.Do("TS.QUERYINDEX", "a=b", []byte{}) //nolint:errcheck
+	conn.Do("TS.INFO", 1.5, true, int64(3))   //nolint:errcheck
+	conn.Close()
+
+	path := filepath.Join(dir, "golden.json")
+	assert.NoError(t, pool.WriteGolden(path))
+	exchanges, err := ReadExchanges(path)
+	assert.NoError(t, err)
+	assert.Equal(t, pool.Exchanges(), exchanges)
+	assert.Equal(t, []string{"a=b", ""}, exchanges[1].Args)
+	assert.Equal(t, []string{"1.5", "1", "3"}, exchanges[2].Args)
+	assert.Equal(t, "connection reset", exchanges[2].Err)
+
+	replay := NewReplayPool(exchanges)
+	conn = replay.Get()
+	reply, err := conn.Do("TS.GET", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(1), []byte("2.5")}, reply)
+	reply, err = conn.Do("TS.QUERYINDEX", "a=b", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{}, reply)
+	_, err = conn.Do("TS.INFO", "1.5", "1", "3")
+	assert.EqualError(t, err, "connection reset")
+	pool.Reset()
+	assert.Empty(t, pool.Exchanges())
+}
+
+func TestReplayPool_Mismatch(t *testing.T) {
+	status := "OK"
+	exchanges := []Exchange{
+		{Command: "TS.CREATE", Args: []string{"key", "RETENTION", "1000"}, Reply: &RecordedReply{Status: &status}},
+		{Command: "TS.CREATE", Args: []string{"other"}, Reply: &RecordedReply{Status: &status}},
+	}
+	tests := []struct {
+		name      string
+		cmd       string
+		args      []interface{}
+		wantIndex int
+	}{
+		{"matching", "TS.CREATE", []interface{}{"key", "RETENTION", 1000}, -1},
+		{"different argument", "TS.CREATE", []interface{}{"other", "RETENTION", 1000}, 1},
+		{"different command", "TS.ALTER", []interface{}{"other"}, 1},
+		{"matching after a mismatch", "TS.CREATE", []interface{}{"other"}, -1},
+		{"exhausted", "TS.CREATE", []interface{}{"other"}, 2},
+	}
+	replay := NewReplayPool(exchanges)
+	conn := replay.Get()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := conn.Do(tt.cmd, tt.args...)
+			if tt.wantIndex < 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, "OK", reply)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrReplayMismatch))
+			var mismatch *ReplayMismatchError
+			assert.True(t, errors.As(err, &mismatch))
+			assert.Equal(t, tt.wantIndex, mismatch.Index)
+		})
+	}
+	assert.EqualError(t, replay.Err(),
+		`replay: command #1 "TS.CREATE other RETENTION 1000" does not match the recorded "TS.CREATE other"`)
+
+	client := NewClientFromConnPool(NewReplayPool(exchanges), "replay")
+	err := client.CreateKeyWithOptions("key", CreateOptions{RetentionMSecs: 2 * time.Second})
+	assert.True(t, errors.Is(err, ErrReplayMismatch))
+}
@@ -0,0 +1,102 @@
+package redis_timeseries_go
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/RedisTimeSeries/redistimeseries-go/tstest"
+)
+
+// respStatus is replied as a RESP simple string, while plain strings are replied as bulk strings
+type respStatus = tstest.Status
+
+// respServer is a minimal RESP server answering every command with the reply computed by its handler,
+// which is written by tstest.WriteReply
+type respServer struct {
+	ln      net.Listener
+	handler func(args []string) interface{}
+	mu      sync.Mutex
+	conns   []net.Conn
+}
+
+func newRespServer(t *testing.T, handler func(args []string) interface{}) *respServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	return startRespServer(ln, handler)
+}
+
+// newTLSRespServer is like newRespServer, serving the connections with TLS
+func newTLSRespServer(t *testing.T, config *tls.Config, handler func(args []string) interface{}) *respServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed listening: %v", err)
+	}
+	return startRespServer(tls.NewListener(ln, config), handler)
+}
+
+func startRespServer(ln net.Listener, handler func(args []string) interface{}) *respServer {
+	s := &respServer{ln: ln, handler: handler}
+	go s.serve()
+	return s
+}
+
+func (s *respServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *respServer) host() string {
+	host, _, _ := net.SplitHostPort(s.addr())
+	return host
+}
+
+func (s *respServer) port() int64 {
+	_, port, _ := net.SplitHostPort(s.addr())
+	p, _ := strconv.ParseInt(port, 10, 64)
+	return p
+}
+
+func (s *respServer) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *respServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *respServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := tstest.ReadCommand(r)
+		if err != nil {
+			return
+		}
+		tstest.WriteReply(w, s.handler(args))
+		if r.Buffered() == 0 {
+			if w.Flush() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy retries the idempotent commands failing with a transient error, such as a connection failure
+// or a LOADING or BUSY reply, waiting for an exponential backoff with jitter between attempts.
+// The idempotent commands are TS.RANGE, TS.REVRANGE, TS.MRANGE, TS.MREVRANGE, TS.GET, TS.MGET, TS.INFO, TS.QUERYINDEX,
+// and TS.ADD with an explicit timestamp and the LAST or FIRST duplicate policy, as sending them twice has the same effect
+// as sending them once. The other commands are only retried when listed in RetryCommands.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a command is sent, including the first one
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled on each following retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of each wait which is randomized, between 0 and 1, so that clients do not retry in lockstep
+	Jitter float64
+	// Retryable tells whether a failed command may be sent again, the transient errors being retried when nil
+	Retryable func(err error) bool
+	// RetryCommands are the non-idempotent commands retried anyway, such as INCRBY_CMD, at the risk of applying them twice
+	RetryCommands []string
+}
+
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Jitter:         0.2,
+		Retryable:      nil,
+		RetryCommands:  []string{},
+	}
+}
+
+// DefaultRetryPolicy sends the idempotent commands up to 3 times, waiting 50ms before the first retry
+var DefaultRetryPolicy = *NewRetryPolicy()
+
+// SetMaxAttempts sets the maximum number of times a command is sent, including the first one
+func (policy *RetryPolicy) SetMaxAttempts(maxAttempts int) *RetryPolicy {
+	policy.MaxAttempts = maxAttempts
+	return policy
+}
+
+// SetBackoff sets the wait before the first retry, its maximum, and the randomized fraction of each wait
+func (policy *RetryPolicy) SetBackoff(initial, max time.Duration, jitter float64) *RetryPolicy {
+	policy.InitialBackoff = initial
+	policy.MaxBackoff = max
+	policy.Jitter = jitter
+	return policy
+}
+
+// SetRetryable sets the function telling whether a failed command may be sent again
+func (policy *RetryPolicy) SetRetryable(retryable func(err error) bool) *RetryPolicy {
+	policy.Retryable = retryable
+	return policy
+}
+
+// SetRetryCommands opts non-idempotent commands in, such as INCRBY_CMD or MADD_CMD
+func (policy *RetryPolicy) SetRetryCommands(commands ...string) *RetryPolicy {
+	policy.RetryCommands = commands
+	return policy
+}
+
+// retries tells whether the command with the given arguments may be retried
+func (policy *RetryPolicy) retries(cmd string, args []interface{}) bool {
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return false
+	}
+	if readOnlyCommands[cmd] {
+		return true
+	}
+	for _, retried := range policy.RetryCommands {
+		if retried == cmd {
+			return true
+		}
+	}
+	return cmd == ADD_CMD && isIdempotentAdd(args)
+}
+
+// isIdempotentAdd tells whether the arguments of a TS.ADD have an explicit timestamp and the LAST or FIRST duplicate policy
+func isIdempotentAdd(args []interface{}) bool {
+	if len(args) < 2 || args[1] == "*" {
+		return false
+	}
+	for i := 3; i+1 < len(args); i++ {
+		if args[i] == "ON_DUPLICATE" {
+			return args[i+1] == string(LastDuplicatePolicy) || args[i+1] == string(FirstDuplicatePolicy)
+		}
+	}
+	return false
+}
+
+func (policy *RetryPolicy) isRetryable(err error) bool {
+	if policy.Retryable != nil {
+		return policy.Retryable(err)
+	}
+	return isTransientError(err)
+}
+
+// wait returns the backoff before the given retry, counting from 1
+func (policy *RetryPolicy) wait(retry int) time.Duration {
+	backoff := policy.InitialBackoff
+	for n := 1; n < retry && backoff < policy.MaxBackoff; n++ {
+		backoff *= 2
+	}
+	if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	if policy.Jitter > 0 {
+		backoff -= time.Duration(policy.Jitter * rand.Float64() * float64(backoff))
+	}
+	return backoff
+}
+
+// retry runs the command issued by run, sending it again according to the RetryPolicy of the client
+// until it succeeds, fails with an error which is not retried, the attempts are exhausted, or ctx is done.
+// The error of the last attempt is returned.
+func (client *Client) retry(ctx context.Context, cmd string, args []interface{}, run func() error) error {
+	policy := client.RetryPolicy
+	if !policy.retries(cmd, args) {
+		return run()
+	}
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || attempt >= policy.MaxAttempts || !policy.isRetryable(err) {
+			return err
+		}
+		timer := time.NewTimer(policy.wait(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
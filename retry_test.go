@@ -0,0 +1,106 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingHandler fails the first failures commands with err, and then replies with reply
+func failingHandler(failures int, err error, reply interface{}, attempts *int) func(cmd string, args ...interface{}) (interface{}, error) {
+	return func(cmd string, args ...interface{}) (interface{}, error) {
+		*attempts++
+		if *attempts <= failures {
+			return nil, err
+		}
+		return reply, nil
+	}
+}
+
+func TestClient_RetryPolicy(t *testing.T) {
+	loading := redis.Error("LOADING Redis is loading the dataset in memory")
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	policy := NewRetryPolicy().SetBackoff(time.Millisecond, 4*time.Millisecond, 0.5)
+	last := CreateOptions{DuplicatePolicy: LastDuplicatePolicy}
+	tests := []struct {
+		name         string
+		policy       *RetryPolicy
+		failures     int
+		err          error
+		reply        interface{}
+		run          func(c *Client) error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"no policy", nil, 1, loading, int64(1),
+			func(c *Client) error { _, err := c.Get("a"); return err }, 1, true},
+		{"get retried", policy, 1, loading, []interface{}{int64(1), []byte("1")},
+			func(c *Client) error { _, err := c.Get("a"); return err }, 2, false},
+		{"range retried on connection failure", policy, 2, reset, []interface{}{},
+			func(c *Client) error { _, err := c.Range("a", 0, 1); return err }, 3, false},
+		{"attempts exhausted", policy, 3, loading, []interface{}{},
+			func(c *Client) error { _, err := c.QueryIndex("a=1"); return err }, 3, true},
+		{"open circuit not retried", policy, 1, ErrCircuitOpen, []interface{}{},
+			func(c *Client) error { _, err := c.Range("a", 0, 1); return err }, 1, true},
+		{"exhausted pool not retried", policy, 1, redis.ErrPoolExhausted, []interface{}{},
+			func(c *Client) error { _, err := c.Range("a", 0, 1); return err }, 1, true},
+		{"error not retried", policy, 1, redis.Error("ERR TSDB: the key does not exist"), int64(1),
+			func(c *Client) error { _, err := c.Info("a"); return err }, 1, true},
+		{"add with last policy retried", policy, 1, loading, int64(1),
+			func(c *Client) error { _, err := c.AddWithOptions("a", 1, 1, last); return err }, 2, false},
+		{"add with auto timestamp not retried", policy, 1, loading, int64(1),
+			func(c *Client) error { _, err := c.AddAutoTsWithOptions("a", 1, last); return err }, 1, true},
+		{"add without policy not retried", policy, 1, loading, int64(1),
+			func(c *Client) error { _, err := c.Add("a", 1, 1); return err }, 1, true},
+		{"incrby not retried", policy, 1, loading, int64(1),
+			func(c *Client) error { _, err := c.IncrBy("a", 1, 1, DefaultCreateOptions); return err }, 1, true},
+		{"incrby opted in", NewRetryPolicy().SetBackoff(time.Millisecond, time.Millisecond, 0).SetRetryCommands(INCRBY_CMD), 1, loading, int64(1),
+			func(c *Client) error { _, err := c.IncrBy("a", 1, 1, DefaultCreateOptions); return err }, 2, false},
+		{"custom classification", NewRetryPolicy().SetBackoff(time.Millisecond, time.Millisecond, 0).SetRetryable(func(err error) bool {
+			return errors.Is(err, ErrKeyNotFound)
+		}), 1, redis.Error("ERR TSDB: the key does not exist"), []interface{}{int64(1), []byte("1")},
+			func(c *Client) error { _, err := c.Get("a"); return err }, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			c := &Client{Pool: &stubPool{handler: failingHandler(tt.failures, tt.err, tt.reply, &attempts)}, Name: "test", RetryPolicy: tt.policy}
+			err := tt.run(c)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func TestClient_RetryPolicy_Context(t *testing.T) {
+	attempts := 0
+	c := &Client{
+		Pool:        &stubPool{handler: failingHandler(10, redis.Error("BUSY"), int64(1), &attempts)},
+		Name:        "test",
+		RetryPolicy: NewRetryPolicy().SetMaxAttempts(10).SetBackoff(time.Hour, time.Hour, 0),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.GetCtx(ctx, "a")
+	// the retries stop with the context, reporting the last failure
+	assert.EqualError(t, err, "BUSY")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryPolicy_Wait(t *testing.T) {
+	policy := NewRetryPolicy().SetBackoff(10*time.Millisecond, 50*time.Millisecond, 0)
+	assert.Equal(t, 10*time.Millisecond, policy.wait(1))
+	assert.Equal(t, 20*time.Millisecond, policy.wait(2))
+	assert.Equal(t, 40*time.Millisecond, policy.wait(3))
+	assert.Equal(t, 50*time.Millisecond, policy.wait(10))
+	policy.Jitter = 0.5
+	for n := 0; n < 100; n++ {
+		wait := policy.wait(1)
+		assert.True(t, wait > 5*time.Millisecond && wait <= 10*time.Millisecond, wait)
+	}
+}
@@ -0,0 +1,279 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Timeout applied to each sentinel query, so that an unresponsive sentinel does not block the master resolution
+var sentinelTimeout = 2 * time.Second
+
+// SentinelPool is a ConnPool connecting to the current master of a Redis Sentinel deployment.
+// The master is discovered with SENTINEL get-master-addr-by-name, and resolved again whenever a connection
+// to it fails or it replies READONLY, meaning it was demoted to replica by a failover.
+// When enabled with SetReadFromReplicas, read-only commands are served by the known replicas.
+type SentinelPool struct {
+	sync.Mutex
+	sentinels        []string
+	masterName       string
+	authPass         *string
+	readFromReplicas bool
+	master           string
+	replicas         []string
+	pools            map[string]*redis.Pool
+}
+
+// NewSentinelPool creates a SentinelPool resolving the master named masterName through the given sentinel addresses
+func NewSentinelPool(sentinels []string, masterName string, authPass *string) *SentinelPool {
+	return &SentinelPool{
+		sentinels:  sentinels,
+		masterName: masterName,
+		authPass:   authPass,
+		pools:      make(map[string]*redis.Pool),
+	}
+}
+
+// NewSentinelClient creates a new client connecting to the master named masterName, using the given name as key prefix.
+// Addr is a comma separated list of host:port sentinel addresses.
+func NewSentinelClient(addr, masterName, name string, authPass *string) *Client {
+	return NewClientFromConnPool(NewSentinelPool(strings.Split(addr, ","), masterName, authPass), name)
+}
+
+// SetReadFromReplicas enables serving read-only commands from replicas, falling back to the master when none is available
+func (p *SentinelPool) SetReadFromReplicas(value bool) *SentinelPool {
+	p.Lock()
+	defer p.Unlock()
+	p.readFromReplicas = value
+	return p
+}
+
+// MasterAddr returns the address of the current master, resolving it through the sentinels when unknown
+func (p *SentinelPool) MasterAddr(ctx context.Context) (string, error) {
+	p.Lock()
+	master := p.master
+	p.Unlock()
+	if master != "" {
+		return master, nil
+	}
+	return p.resolve(ctx)
+}
+
+// resolve asks the sentinels in turn for the master address, and refreshes the known replicas.
+// The sentinel which answered is moved to the front, so that it is asked first next time.
+func (p *SentinelPool) resolve(ctx context.Context) (master string, err error) {
+	p.Lock()
+	sentinels := append([]string{}, p.sentinels...)
+	p.Unlock()
+	err = errors.New("no sentinel configured")
+	for i, sentinel := range sentinels {
+		var replicas []string
+		master, replicas, err = p.querySentinel(ctx, sentinel)
+		if err == nil {
+			p.Lock()
+			p.master = master
+			p.replicas = replicas
+			if i > 0 {
+				p.sentinels[0], p.sentinels[i] = p.sentinels[i], p.sentinels[0]
+			}
+			p.Unlock()
+			return master, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+	return "", fmt.Errorf("failed resolving master %s: %v", p.masterName, err)
+}
+
+func (p *SentinelPool) querySentinel(ctx context.Context, sentinel string) (master string, replicas []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, sentinelTimeout)
+	defer cancel()
+	conn, err := redis.DialContext(ctx, "tcp", sentinel,
+		redis.DialReadTimeout(sentinelTimeout), redis.DialWriteTimeout(sentinelTimeout))
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	addr, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", p.masterName))
+	if err == redis.ErrNil {
+		err = fmt.Errorf("sentinel %s does not know master %s", sentinel, p.masterName)
+		return
+	}
+	if err != nil {
+		return
+	}
+	if len(addr) != 2 {
+		err = fmt.Errorf("sentinel %s replied an invalid master address %v", sentinel, addr)
+		return
+	}
+	master = net.JoinHostPort(addr[0], addr[1])
+	replicas, err = queryReplicas(conn, p.masterName)
+	return
+}
+
+// queryReplicas returns the addresses of the healthy replicas known by the sentinel
+func queryReplicas(conn redis.Conn, masterName string) ([]string, error) {
+	values, err := redis.Values(conn.Do("SENTINEL", "replicas", masterName))
+	if _, ok := err.(redis.Error); ok {
+		// sentinels older than Redis 5 only know the SLAVES subcommand
+		values, err = redis.Values(conn.Do("SENTINEL", "slaves", masterName))
+	}
+	if err != nil {
+		return nil, err
+	}
+	replicas := make([]string, 0, len(values))
+	for _, value := range values {
+		fields, err := redis.StringMap(value, nil)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(fields["flags"], "down") || strings.Contains(fields["flags"], "disconnected") {
+			continue
+		}
+		replicas = append(replicas, net.JoinHostPort(fields["ip"], fields["port"]))
+	}
+	return replicas, nil
+}
+
+// invalidate forgets the master when it still is addr, so that the next borrow resolves it again
+func (p *SentinelPool) invalidate(addr string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.master == addr {
+		p.master = ""
+	}
+}
+
+func (p *SentinelPool) hostPool(addr string) *redis.Pool {
+	p.Lock()
+	defer p.Unlock()
+	pool, found := p.pools[addr]
+	if !found {
+		pool = withAuthPass(p.authPass).newPool(addr)
+		p.pools[addr] = pool
+	}
+	return pool
+}
+
+// Get gets a connection to the current master
+func (p *SentinelPool) Get() redis.Conn {
+	conn, err := p.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return conn
+}
+
+// GetContext gets a connection to the current master
+func (p *SentinelPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	master, err := p.MasterAddr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := getPooled(ctx, p.hostPool(master))
+	if err != nil {
+		p.invalidate(master)
+		return nil, err
+	}
+	return &sentinelConn{Conn: conn, pool: p, addr: master}, nil
+}
+
+// GetReadOnlyContext gets a connection to a random replica when reading from replicas is enabled,
+// and to the master otherwise or when no replica is reachable
+func (p *SentinelPool) GetReadOnlyContext(ctx context.Context) (redis.Conn, error) {
+	if _, err := p.MasterAddr(ctx); err != nil {
+		return nil, err
+	}
+	p.Lock()
+	readFromReplicas := p.readFromReplicas
+	replicas := p.replicas
+	p.Unlock()
+	if readFromReplicas && len(replicas) > 0 {
+		replica := replicas[rand.Intn(len(replicas))]
+		if conn, err := getPooled(ctx, p.hostPool(replica)); err == nil {
+			return conn, nil
+		}
+	}
+	return p.GetContext(ctx)
+}
+
+func (p *SentinelPool) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	return closePools(p.pools)
+}
+
+// sentinelConn is a connection to the master, invalidating it on connection errors and READONLY replies.
+// Commands rejected with READONLY were not executed, so Do issues them again on the newly resolved master.
+type sentinelConn struct {
+	redis.Conn
+	pool *SentinelPool
+	addr string
+}
+
+func (c *sentinelConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(cmd, args...)
+	return c.checkReply(reply, err, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do(cmd, args...)
+	})
+}
+
+func (c *sentinelConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+	return c.checkReply(reply, err, func(conn redis.Conn) (interface{}, error) {
+		return redis.DoWithTimeout(conn, timeout, cmd, args...)
+	})
+}
+
+func (c *sentinelConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.checkErr(err)
+	return reply, err
+}
+
+func (c *sentinelConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := redis.ReceiveWithTimeout(c.Conn, timeout)
+	c.checkErr(err)
+	return reply, err
+}
+
+// abort aborts the wrapped connection, making its in-flight command fail
+func (c *sentinelConn) abort() {
+	abortConn(c.Conn)
+}
+
+// checkErr invalidates the master on connection errors and READONLY replies, and reports whether the latter happened
+func (c *sentinelConn) checkErr(err error) (readOnly bool) {
+	if err == nil {
+		return false
+	}
+	if rerr, ok := err.(redis.Error); ok {
+		if !strings.HasPrefix(string(rerr), "READONLY") {
+			return false
+		}
+		readOnly = true
+	}
+	c.pool.invalidate(c.addr)
+	return
+}
+
+func (c *sentinelConn) checkReply(reply interface{}, err error, do func(conn redis.Conn) (interface{}, error)) (interface{}, error) {
+	if !c.checkErr(err) {
+		return reply, err
+	}
+	master, resolveErr := c.pool.resolve(context.Background())
+	if resolveErr != nil || master == c.addr {
+		return reply, err
+	}
+	conn := c.pool.hostPool(master).Get()
+	defer conn.Close()
+	return do(conn)
+}
@@ -0,0 +1,129 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSentinel monitors a master and its replicas, and can promote another node on demand
+type fakeSentinel struct {
+	mu       sync.Mutex
+	master   *respServer
+	replicas []*respServer
+}
+
+func (s *fakeSentinel) failover(master *respServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.master = master
+}
+
+func (s *fakeSentinel) handle(args []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(args) != 3 || args[0] != "SENTINEL" || args[2] != "mymaster" {
+		return nil
+	}
+	switch args[1] {
+	case "get-master-addr-by-name":
+		return []interface{}{s.master.host(), strconv.FormatInt(s.master.port(), 10)}
+	case "replicas":
+		replies := make([]interface{}, 0, len(s.replicas))
+		for _, replica := range s.replicas {
+			replies = append(replies, []interface{}{
+				"ip", replica.host(), "port", strconv.FormatInt(replica.port(), 10), "flags", "slave",
+			})
+		}
+		return replies
+	}
+	return redis.Error("ERR unknown sentinel subcommand")
+}
+
+// fakeNode replies to TimeSeries writes with the given reply, and to reads with a single data point holding its value
+func fakeNode(t *testing.T, writeReply interface{}, value string) *respServer {
+	return newRespServer(t, func(args []string) interface{} {
+		switch args[0] {
+		case ADD_CMD:
+			return writeReply
+		case GET_CMD:
+			return []interface{}{int64(1), value}
+		}
+		return respStatus("OK")
+	})
+}
+
+func TestSentinelPool_Failover(t *testing.T) {
+	oldMaster := fakeNode(t, redis.Error("READONLY You can't write against a read only replica."), "1")
+	defer oldMaster.close()
+	newMaster := fakeNode(t, int64(2), "2")
+	defer newMaster.close()
+	sentinel := &fakeSentinel{master: oldMaster}
+	sentinelServer := newRespServer(t, sentinel.handle)
+	defer sentinelServer.close()
+
+	pool := NewSentinelPool([]string{"127.0.0.1:1", sentinelServer.addr()}, "mymaster", nil)
+	defer pool.Close()
+	client := NewClientFromConnPool(pool, "sentinel")
+
+	master, err := pool.MasterAddr(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, oldMaster.addr(), master)
+
+	sentinel.failover(newMaster)
+	timestamp, err := client.Add("key", 2, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), timestamp)
+
+	master, err = pool.MasterAddr(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, newMaster.addr(), master)
+	dataPoint, err := client.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, 2.0, dataPoint.Value)
+}
+
+func TestSentinelPool_ReadFromReplicas(t *testing.T) {
+	master := fakeNode(t, int64(1), "1")
+	defer master.close()
+	replica := fakeNode(t, int64(1), "2")
+	defer replica.close()
+	sentinel := &fakeSentinel{master: master, replicas: []*respServer{replica}}
+	sentinelServer := newRespServer(t, sentinel.handle)
+	defer sentinelServer.close()
+
+	tests := []struct {
+		name             string
+		readFromReplicas bool
+		want             float64
+	}{
+		{"master", false, 1},
+		{"replica", true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewSentinelPool([]string{sentinelServer.addr()}, "mymaster", nil).SetReadFromReplicas(tt.readFromReplicas)
+			defer pool.Close()
+			client := NewClientFromConnPool(pool, "sentinel")
+			dataPoint, err := client.Get("key")
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, dataPoint.Value)
+			_, err = client.Add("key", 1, 1)
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestSentinelPool_UnknownMaster(t *testing.T) {
+	sentinel := &fakeSentinel{}
+	sentinelServer := newRespServer(t, sentinel.handle)
+	defer sentinelServer.close()
+	pool := NewSentinelPool([]string{sentinelServer.addr()}, "othermaster", nil)
+	defer pool.Close()
+	_, err := NewClientFromConnPool(pool, "sentinel").Add("key", 1, 1)
+	assert.NotNil(t, err)
+}
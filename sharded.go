@@ -0,0 +1,147 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Number of virtual nodes placed on the hash ring for each host of a ShardedPool, when not specified
+const DefaultVirtualNodes = 160
+
+// ringPoint is a virtual node of a host on the hash ring
+type ringPoint struct {
+	hash uint32
+	host string
+}
+
+// ShardedPool is a ShardedConnPool spreading the keyspace across independent servers with consistent hashing.
+// Each host is placed on a hash ring as several virtual nodes, and a key is owned by the first virtual node
+// following its hash on the ring, so that adding or removing a host only moves the keys it gains or loses.
+// As with Redis Cluster, only the hash tag within curly braces is hashed when a key has one,
+// so that keys sharing it live on the same host.
+type ShardedPool struct {
+	sync.Mutex
+	hosts   []string
+	options ClientOptions
+	pools   map[string]*redis.Pool
+	ring    []ringPoint
+}
+
+// NewShardedPool creates a ShardedPool over the given hosts, each placed virtualNodes times on the hash ring,
+// or DefaultVirtualNodes times when virtualNodes is not positive. The connections are dialed and limited according to options.
+func NewShardedPool(hosts []string, virtualNodes int, options ClientOptions) *ShardedPool {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	hosts = uniqueSorted(hosts)
+	ring := make([]ringPoint, 0, len(hosts)*virtualNodes)
+	for _, host := range hosts {
+		for i := 0; i < virtualNodes; i++ {
+			ring = append(ring, ringPoint{hash: crc32.ChecksumIEEE([]byte(host + "-" + strconv.Itoa(i))), host: host})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash == ring[j].hash {
+			return ring[i].host < ring[j].host
+		}
+		return ring[i].hash < ring[j].hash
+	})
+	return &ShardedPool{
+		hosts:   hosts,
+		options: options,
+		pools:   make(map[string]*redis.Pool, len(hosts)),
+		ring:    ring,
+	}
+}
+
+// NewShardedClient creates a new client sharding the series across independent servers, using the given name as key prefix.
+// Addr is a comma separated list of host:port pairs, and the connections are dialed and limited according to options.
+func NewShardedClient(addr, name string, options ClientOptions) *Client {
+	return &Client{
+		Pool: NewShardedPool(strings.Split(addr, ","), DefaultVirtualNodes, options),
+		Name: name,
+	}
+}
+
+// NodeForKey returns the address of the host owning key on the hash ring
+func (p *ShardedPool) NodeForKey(key string) (string, error) {
+	if len(p.ring) == 0 {
+		return "", errors.New("no host in sharded pool")
+	}
+	hash := crc32.ChecksumIEEE([]byte(hashTag(key)))
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= hash })
+	if i == len(p.ring) {
+		i = 0
+	}
+	return p.ring[i].host, nil
+}
+
+// NodeForKeyContext is like NodeForKey, the hosts of the ring being known without any round trip
+func (p *ShardedPool) NodeForKeyContext(ctx context.Context, key string) (string, error) {
+	return p.NodeForKey(key)
+}
+
+// Nodes returns the addresses of all the hosts
+func (p *ShardedPool) Nodes() ([]string, error) {
+	return append([]string{}, p.hosts...), nil
+}
+
+// NodesContext is like Nodes
+func (p *ShardedPool) NodesContext(ctx context.Context) ([]string, error) {
+	return p.Nodes()
+}
+
+// GetNodeContext gets a connection to the given host
+func (p *ShardedPool) GetNodeContext(ctx context.Context, node string) (redis.Conn, error) {
+	pool, err := p.nodePool(node)
+	if err != nil {
+		return nil, err
+	}
+	return getPooled(ctx, pool)
+}
+
+func (p *ShardedPool) nodePool(node string) (*redis.Pool, error) {
+	if i := sort.SearchStrings(p.hosts, node); i == len(p.hosts) || p.hosts[i] != node {
+		return nil, fmt.Errorf("unknown host %s in sharded pool", node)
+	}
+	p.Lock()
+	defer p.Unlock()
+	pool, found := p.pools[node]
+	if !found {
+		pool = p.options.newPool(node)
+		p.pools[node] = pool
+	}
+	return pool, nil
+}
+
+// Get gets a connection to a random host
+func (p *ShardedPool) Get() redis.Conn {
+	conn, err := p.GetContext(context.Background())
+	if err != nil {
+		return errorConn{err}
+	}
+	return conn
+}
+
+// GetContext gets a connection to a random host, for the commands which do not act on a key
+func (p *ShardedPool) GetContext(ctx context.Context) (redis.Conn, error) {
+	if len(p.hosts) == 0 {
+		return nil, errors.New("no host in sharded pool")
+	}
+	return p.GetNodeContext(ctx, p.hosts[rand.Intn(len(p.hosts))])
+}
+
+func (p *ShardedPool) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	return closePools(p.pools)
+}
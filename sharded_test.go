@@ -0,0 +1,137 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedPool_NodeForKey(t *testing.T) {
+	hosts := []string{"h1:6379", "h2:6379", "h3:6379"}
+	pool := NewShardedPool(hosts, 0, DefaultClientOptions)
+	grown := NewShardedPool(append(hosts, "h4:6379"), 0, DefaultClientOptions)
+
+	owned := map[string]int{}
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := "series:" + strconv.Itoa(i)
+		node, err := pool.NodeForKey(key)
+		assert.Nil(t, err)
+		owned[node]++
+		grownNode, err := grown.NodeForKey(key)
+		assert.Nil(t, err)
+		if grownNode != node {
+			// adding a host only moves keys to it
+			assert.Equal(t, "h4:6379", grownNode)
+			moved++
+		}
+	}
+	for _, host := range hosts {
+		assert.InDelta(t, 3333, owned[host], 1000, host)
+	}
+	assert.InDelta(t, 2500, moved, 1000)
+
+	// keys sharing a hash tag live on the same host
+	a, _ := pool.NodeForKey("{sensor:1}:temperature")
+	b, _ := pool.NodeForKey("{sensor:1}:humidity")
+	assert.Equal(t, a, b)
+
+	nodes, err := NewShardedPool([]string{"b", "a", "b"}, 1, DefaultClientOptions).Nodes()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, nodes)
+	_, err = NewShardedPool(nil, 0, DefaultClientOptions).NodeForKey("key")
+	assert.NotNil(t, err)
+	_, err = pool.GetNodeContext(context.Background(), "h5:6379")
+	assert.NotNil(t, err)
+}
+
+// fakeShard records the keys it received, and answers the multi-key commands with its own series
+type fakeShard struct {
+	*respServer
+	mu   sync.Mutex
+	keys []string
+}
+
+func (s *fakeShard) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.keys...)
+}
+
+func newFakeShard(t *testing.T, name string) *fakeShard {
+	s := &fakeShard{}
+	s.respServer = newRespServer(t, func(args []string) interface{} {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case MADD_CMD:
+			replies := []interface{}{}
+			for i := 1; i < len(args); i += 3 {
+				s.keys = append(s.keys, args[i])
+				replies = append(replies, args[i+1])
+			}
+			return replies
+		case QUERYINDEX_CMD:
+			return []interface{}{name}
+		case MRANGE_CMD:
+			return []interface{}{
+				[]interface{}{"region=eu",
+					[]interface{}{[]interface{}{"region", "eu"}, []interface{}{"__reducer__", "sum"}, []interface{}{"__source__", name}},
+					[]interface{}{[]interface{}{int64(1), "1"}}},
+			}
+		}
+		s.keys = append(s.keys, args[1])
+		return int64(1)
+	})
+	return s
+}
+
+func TestShardedClient(t *testing.T) {
+	a, b := newFakeShard(t, "a"), newFakeShard(t, "b")
+	defer a.close()
+	defer b.close()
+	c := NewShardedClient(a.addr()+","+b.addr(), "test", DefaultClientOptions)
+	defer c.Pool.Close()
+	pool := c.Pool.(*ShardedPool)
+
+	// find a key owned by each shard
+	keys := map[string]string{}
+	for i := 0; len(keys) < 2; i++ {
+		key := "key" + strconv.Itoa(i)
+		node, err := pool.NodeForKey(key)
+		assert.Nil(t, err)
+		if _, found := keys[node]; !found {
+			keys[node] = key
+		}
+	}
+
+	_, err := c.Add(keys[a.addr()], 1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{keys[a.addr()]}, a.received())
+
+	// TS.MADD is split per shard
+	timestamps, err := c.MultiAdd(
+		Sample{Key: keys[b.addr()], DataPoint: DataPoint{Timestamp: 2, Value: 1}},
+		Sample{Key: keys[a.addr()], DataPoint: DataPoint{Timestamp: 3, Value: 1}},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{[]byte("2"), []byte("3")}, timestamps)
+	assert.Equal(t, []string{keys[a.addr()], keys[a.addr()]}, a.received())
+	assert.Equal(t, []string{keys[b.addr()]}, b.received())
+
+	// TS.QUERYINDEX is scattered to every shard, and gathered
+	found, err := c.QueryIndex("region=eu")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, found)
+
+	// the groups of both shards are reduced again
+	ranges, err := c.MultiRangeWithOptions(0, 100, *NewMultiRangeOptions().SetGroupByReduce("region", SumReducer), "region!=")
+	assert.Nil(t, err)
+	assert.Len(t, ranges, 1)
+	assert.Equal(t, []DataPoint{{1, 2}}, ranges[0].DataPoints)
+	assert.Equal(t, "a,b", ranges[0].Labels["__source__"])
+}
@@ -0,0 +1,72 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"time"
+)
+
+// TimeSeriesClient holds the operations of a Client, so that a fake can be substituted to the Client,
+// or decorators wrap it, such as LoggingClient, ReadOnlyClient and NamespaceClient.
+// The forms without a context run with context.Background(), like the ones of the Client.
+// Pipeline and Tx are not part of it, being bound to the connections of the Client.
+type TimeSeriesClient interface {
+	CreateKey(key string, retentionTime time.Duration) error
+	CreateKeyWithOptions(key string, options CreateOptions) error
+	CreateKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) error
+	AlterKeyWithOptions(key string, options CreateOptions) error
+	AlterKeyWithOptionsCtx(ctx context.Context, key string, options CreateOptions) error
+	Add(key string, timestamp int64, value float64) (int64, error)
+	AddCtx(ctx context.Context, key string, timestamp int64, value float64) (int64, error)
+	AddAutoTs(key string, value float64) (int64, error)
+	AddAutoTsCtx(ctx context.Context, key string, value float64) (int64, error)
+	AddWithOptions(key string, timestamp int64, value float64, options CreateOptions) (int64, error)
+	AddWithOptionsCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error)
+	AddAutoTsWithOptions(key string, value float64, options CreateOptions) (int64, error)
+	AddAutoTsWithOptionsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error)
+	AddWithRetention(key string, timestamp int64, value float64, duration int64) (int64, error)
+	IncrBy(key string, timestamp int64, value float64, options CreateOptions) (int64, error)
+	IncrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error)
+	IncrByAutoTs(key string, value float64, options CreateOptions) (int64, error)
+	IncrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error)
+	DecrBy(key string, timestamp int64, value float64, options CreateOptions) (int64, error)
+	DecrByCtx(ctx context.Context, key string, timestamp int64, value float64, options CreateOptions) (int64, error)
+	DecrByAutoTs(key string, value float64, options CreateOptions) (int64, error)
+	DecrByAutoTsCtx(ctx context.Context, key string, value float64, options CreateOptions) (int64, error)
+	MultiAdd(samples ...Sample) ([]interface{}, error)
+	MultiAddCtx(ctx context.Context, samples ...Sample) ([]interface{}, error)
+	MultiAddWithResults(samples ...Sample) (MultiAddResults, error)
+	MultiAddWithResultsCtx(ctx context.Context, samples ...Sample) (MultiAddResults, error)
+	MultiAddWithOptions(samples ...MultiAddSample) (MultiAddResults, error)
+	MultiAddWithOptionsCtx(ctx context.Context, samples ...MultiAddSample) (MultiAddResults, error)
+	Range(key string, fromTimestamp int64, toTimestamp int64) ([]DataPoint, error)
+	AggRange(key string, fromTimestamp int64, toTimestamp int64, aggType AggregationType, bucketSizeSec int) ([]DataPoint, error)
+	RangeWithOptions(key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error)
+	RangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error)
+	ReverseRangeWithOptions(key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error)
+	ReverseRangeWithOptionsCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64, rangeOptions RangeOptions) ([]DataPoint, error)
+	AggMultiRange(fromTimestamp int64, toTimestamp int64, aggType AggregationType, bucketSizeSec int, filters ...string) ([]Range, error)
+	MultiRangeWithOptions(fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error)
+	MultiRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error)
+	MultiReverseRangeWithOptions(fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error)
+	MultiReverseRangeWithOptionsCtx(ctx context.Context, fromTimestamp int64, toTimestamp int64, mrangeOptions MultiRangeOptions, filters ...string) ([]Range, error)
+	Get(key string) (*DataPoint, error)
+	GetCtx(ctx context.Context, key string) (*DataPoint, error)
+	MultiGet(filters ...string) ([]Range, error)
+	MultiGetCtx(ctx context.Context, filters ...string) ([]Range, error)
+	MultiGetWithOptions(multiGetOptions MultiGetOptions, filters ...string) ([]Range, error)
+	MultiGetWithOptionsCtx(ctx context.Context, multiGetOptions MultiGetOptions, filters ...string) ([]Range, error)
+	Info(key string) (KeyInfo, error)
+	InfoCtx(ctx context.Context, key string) (KeyInfo, error)
+	QueryIndex(filters ...string) ([]string, error)
+	QueryIndexCtx(ctx context.Context, filters ...string) ([]string, error)
+	CreateRule(sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) error
+	CreateRuleCtx(ctx context.Context, sourceKey string, aggType AggregationType, bucketSizeMSec uint, destinationKey string) error
+	DeleteRule(sourceKey string, destinationKey string) error
+	DeleteRuleCtx(ctx context.Context, sourceKey string, destinationKey string) error
+	DeleteSerie(key string) error
+	DeleteSerieCtx(ctx context.Context, key string) error
+	DeleteRange(key string, fromTimestamp int64, toTimestamp int64) (int64, error)
+	DeleteRangeCtx(ctx context.Context, key string, fromTimestamp int64, toTimestamp int64) (int64, error)
+}
+
+var _ TimeSeriesClient = (*Client)(nil)
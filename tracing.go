@@ -0,0 +1,153 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Span is the subset of an OpenTelemetry span used by TracingHook.
+// It can be satisfied by a thin adapter over an OpenTelemetry trace.Span, or by an in-process implementation
+// such as the one of RecordingTracer, without any exporter.
+type Span interface {
+	// SetAttribute sets an attribute of the span, the value being a string, an int or an int64
+	SetAttribute(key string, value interface{})
+	// RecordError records err as an event of the span, and marks the span as failed
+	RecordError(err error)
+	// End completes the span
+	End()
+}
+
+// Tracer starts the spans of TracingHook, like an OpenTelemetry trace.Tracer
+type Tracer interface {
+	// Start starts a span named spanName, child of the span of ctx if any, and returns a context holding it
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Attributes set by TracingHook, following the OpenTelemetry semantic conventions for databases where they exist
+const (
+	DbSystemAttribute     = "db.system"
+	DbOperationAttribute  = "db.operation"
+	DbRedisKeyAttribute   = "db.redis.key"
+	DbRedisNodeAttribute  = "db.redis.node"
+	DbRedisArgsAttribute  = "db.redis.args_count"
+	DbRedisReplyAttribute = "db.redis.reply_bytes"
+)
+
+// tracingHookContextKey holds the span of a command in the context passed from BeforeCommand to AfterCommand
+type tracingHookContextKey struct{}
+
+// TracingHook is a Hook wrapping each command into a span, named after the command
+type TracingHook struct {
+	tracer Tracer
+}
+
+// NewTracingHook creates a TracingHook starting its spans with tracer
+func NewTracingHook(tracer Tracer) *TracingHook {
+	return &TracingHook{tracer: tracer}
+}
+
+func (h *TracingHook) BeforeCommand(ctx context.Context, event *CommandEvent) context.Context {
+	ctx, span := h.tracer.Start(ctx, event.Command)
+	span.SetAttribute(DbSystemAttribute, "redis")
+	span.SetAttribute(DbOperationAttribute, event.Command)
+	if event.Key != "" {
+		span.SetAttribute(DbRedisKeyAttribute, event.Key)
+	}
+	if event.Node != "" {
+		span.SetAttribute(DbRedisNodeAttribute, event.Node)
+	}
+	span.SetAttribute(DbRedisArgsAttribute, event.ArgCount)
+	return context.WithValue(ctx, tracingHookContextKey{}, span)
+}
+
+func (h *TracingHook) AfterCommand(ctx context.Context, event *CommandEvent) {
+	span, ok := ctx.Value(tracingHookContextKey{}).(Span)
+	if !ok {
+		return
+	}
+	span.SetAttribute(DbRedisReplyAttribute, event.ReplySize)
+	if event.Err != nil {
+		span.RecordError(event.Err)
+	}
+	span.End()
+}
+
+// RecordedSpan is a span completed by a RecordingTracer
+type RecordedSpan struct {
+	Name string
+	// Parent is the name of the span which was current in the context the span was started with, if any
+	Parent     string
+	Attributes map[string]interface{}
+	Errors     []error
+	Start      time.Time
+	End        time.Time
+}
+
+// RecordingTracer is an in-process Tracer keeping the completed spans in memory, for tests and debugging
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// recordingTracerContextKey holds the current span of a RecordingTracer
+type recordingTracerContextKey struct{}
+
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+func (t *RecordingTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	span := &recordingSpan{
+		tracer: t,
+		span:   RecordedSpan{Name: spanName, Attributes: map[string]interface{}{}, Start: time.Now()},
+	}
+	if parent, ok := ctx.Value(recordingTracerContextKey{}).(*recordingSpan); ok {
+		span.span.Parent = parent.span.Name
+	}
+	return context.WithValue(ctx, recordingTracerContextKey{}, span), span
+}
+
+// Spans returns the completed spans, in completion order
+func (t *RecordingTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]RecordedSpan, len(t.spans))
+	for i, span := range t.spans {
+		spans[i] = *span
+	}
+	return spans
+}
+
+type recordingSpan struct {
+	mu     sync.Mutex
+	tracer *RecordingTracer
+	span   RecordedSpan
+	ended  bool
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Attributes[key] = value
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Errors = append(s.span.Errors, err)
+}
+
+func (s *recordingSpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.span.End = time.Now()
+	s.mu.Unlock()
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, &s.span)
+}
@@ -0,0 +1,839 @@
+package tstest
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// session is the state of a connection
+type session struct {
+	db            int
+	authenticated bool
+	name          string
+	quit          bool
+	// queued are the commands of the transaction started by MULTI, nil outside of a transaction
+	queued [][]string
+	// aborted is set when a command could not be queued, EXEC then failing
+	aborted bool
+	// watched are the versions of the keys watched by WATCH, in the database of the session
+	watched map[string]uint64
+}
+
+type command func(s *Server, session *session, args []string) interface{}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":          ping,
+		"ECHO":          echo,
+		"AUTH":          auth,
+		"SELECT":        selectDb,
+		"CLIENT":        client,
+		"QUIT":          quit,
+		"DEL":           del,
+		"EXISTS":        exists,
+		"TYPE":          typeOf,
+		"GET":           get,
+		"SET":           set,
+		"FLUSHALL":      flushAll,
+		"FLUSHDB":       flushDb,
+		"MODULE":        module,
+		"MULTI":         multi,
+		"EXEC":          exec,
+		"DISCARD":       discard,
+		"WATCH":         watch,
+		"UNWATCH":       unwatch,
+		"TS.CREATE":     tsCreate,
+		"TS.ALTER":      tsAlter,
+		"TS.ADD":        tsAdd,
+		"TS.MADD":       tsMadd,
+		"TS.INCRBY":     tsIncrBy,
+		"TS.DECRBY":     tsDecrBy,
+		"TS.RANGE":      tsRange,
+		"TS.REVRANGE":   tsRevRange,
+		"TS.MRANGE":     tsMrange,
+		"TS.MREVRANGE":  tsMrevRange,
+		"TS.GET":        tsGet,
+		"TS.MGET":       tsMget,
+		"TS.INFO":       tsInfo,
+		"TS.QUERYINDEX": tsQueryIndex,
+		"TS.DEL":        tsDel,
+		"TS.CREATERULE": tsCreateRule,
+		"TS.DELETERULE": tsDeleteRule,
+	}
+}
+
+// transactionCommands are run straight away within a transaction, instead of being queued
+var transactionCommands = map[string]bool{"EXEC": true, "DISCARD": true, "MULTI": true, "WATCH": true, "QUIT": true}
+
+// arity is the minimal number of arguments of the commands, following the command name
+var arity = map[string]int{
+	"ECHO": 1, "AUTH": 1, "SELECT": 1, "CLIENT": 1, "DEL": 1, "EXISTS": 1, "TYPE": 1, "GET": 1, "SET": 2, "MODULE": 1,
+	"WATCH": 1, "TS.CREATE": 1, "TS.ALTER": 1, "TS.ADD": 3, "TS.MADD": 3, "TS.INCRBY": 2, "TS.DECRBY": 2,
+	"TS.RANGE": 3, "TS.REVRANGE": 3, "TS.MRANGE": 3, "TS.MREVRANGE": 3, "TS.GET": 1, "TS.MGET": 1, "TS.INFO": 1,
+	"TS.QUERYINDEX": 1, "TS.DEL": 3, "TS.CREATERULE": 5, "TS.DELETERULE": 2,
+}
+
+// execute runs a command for session, or queues it within a transaction. The lock must be held.
+func (s *Server) execute(session *session, args []string) interface{} {
+	name := strings.ToUpper(args[0])
+	if s.password != "" && !session.authenticated && name != "AUTH" && name != "QUIT" {
+		return replyError("NOAUTH Authentication required.")
+	}
+	cmd, found := commands[name]
+	if !found {
+		session.aborted = session.queued != nil
+		return errorf("ERR unknown command `%s`, with args beginning with: ", args[0])
+	}
+	if len(args)-1 < arity[name] {
+		session.aborted = session.queued != nil
+		return errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0]))
+	}
+	if session.queued != nil && !transactionCommands[name] {
+		session.queued = append(session.queued, args)
+		return Status("QUEUED")
+	}
+	return cmd(s, session, args[1:])
+}
+
+func ping(s *Server, session *session, args []string) interface{} {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return Status("PONG")
+}
+
+func echo(s *Server, session *session, args []string) interface{} {
+	return args[0]
+}
+
+func auth(s *Server, session *session, args []string) interface{} {
+	if s.password == "" {
+		return replyError("ERR Client sent AUTH, but no password is set")
+	}
+	if args[len(args)-1] != s.password {
+		return replyError("WRONGPASS invalid username-password pair")
+	}
+	session.authenticated = true
+	return ok
+}
+
+func selectDb(s *Server, session *session, args []string) interface{} {
+	index, err := strconv.Atoi(args[0])
+	if err != nil || index < 0 || index > 15 {
+		return replyError("ERR DB index is out of range")
+	}
+	session.db, session.watched = index, nil
+	return ok
+}
+
+func client(s *Server, session *session, args []string) interface{} {
+	switch strings.ToUpper(args[0]) {
+	case "SETNAME":
+		if len(args) != 2 {
+			return errSyntax
+		}
+		session.name = args[1]
+		return ok
+	case "GETNAME":
+		if session.name == "" {
+			return nil
+		}
+		return session.name
+	}
+	return errSyntax
+}
+
+func quit(s *Server, session *session, args []string) interface{} {
+	session.quit = true
+	return ok
+}
+
+func del(s *Server, session *session, args []string) interface{} {
+	db := s.db(session.db)
+	deleted := int64(0)
+	for _, key := range args {
+		if db.delete(key) {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+func exists(s *Server, session *session, args []string) interface{} {
+	db := s.db(session.db)
+	count := int64(0)
+	for _, key := range args {
+		if db.exists(key) {
+			count++
+		}
+	}
+	return count
+}
+
+func typeOf(s *Server, session *session, args []string) interface{} {
+	db := s.db(session.db)
+	if _, found := db.series[args[0]]; found {
+		return Status("TSDB-TYPE")
+	}
+	if _, found := db.strings[args[0]]; found {
+		return Status("string")
+	}
+	return Status("none")
+}
+
+func get(s *Server, session *session, args []string) interface{} {
+	db := s.db(session.db)
+	if _, found := db.series[args[0]]; found {
+		return errWrongType
+	}
+	value, found := db.strings[args[0]]
+	if !found {
+		return nil
+	}
+	return value
+}
+
+func set(s *Server, session *session, args []string) interface{} {
+	db := s.db(session.db)
+	db.delete(args[0])
+	db.strings[args[0]] = args[1]
+	db.touch(args[0])
+	return ok
+}
+
+func flushAll(s *Server, session *session, args []string) interface{} {
+	s.flushAll()
+	return ok
+}
+
+func flushDb(s *Server, session *session, args []string) interface{} {
+	s.db(session.db).flush()
+	return ok
+}
+
+func module(s *Server, session *session, args []string) interface{} {
+	if !strings.EqualFold(args[0], "LIST") {
+		return errSyntax
+	}
+	return []interface{}{[]interface{}{"name", "timeseries", "ver", s.moduleVersion}}
+}
+
+func multi(s *Server, session *session, args []string) interface{} {
+	if session.queued != nil {
+		return replyError("ERR MULTI calls can not be nested")
+	}
+	session.queued, session.aborted = [][]string{}, false
+	return ok
+}
+
+func exec(s *Server, session *session, args []string) interface{} {
+	if session.queued == nil {
+		return replyError("ERR EXEC without MULTI")
+	}
+	queued, aborted, watched := session.queued, session.aborted, session.watched
+	session.queued, session.aborted, session.watched = nil, false, nil
+	if aborted {
+		return replyError("EXECABORT Transaction discarded because of previous errors.")
+	}
+	db := s.db(session.db)
+	for key, version := range watched {
+		if db.versions[key] != version {
+			return nilArray{}
+		}
+	}
+	replies := make([]interface{}, len(queued))
+	for i, args := range queued {
+		replies[i] = s.execute(session, args)
+	}
+	return replies
+}
+
+func discard(s *Server, session *session, args []string) interface{} {
+	if session.queued == nil {
+		return replyError("ERR DISCARD without MULTI")
+	}
+	session.queued, session.aborted, session.watched = nil, false, nil
+	return ok
+}
+
+func watch(s *Server, session *session, args []string) interface{} {
+	if session.queued != nil {
+		return replyError("ERR WATCH inside MULTI is not allowed")
+	}
+	if session.watched == nil {
+		session.watched = map[string]uint64{}
+	}
+	db := s.db(session.db)
+	for _, key := range args {
+		session.watched[key] = db.versions[key]
+	}
+	return ok
+}
+
+func unwatch(s *Server, session *session, args []string) interface{} {
+	session.watched = nil
+	return ok
+}
+
+// seriesOptions are the options of TS.CREATE, TS.ALTER, TS.ADD, TS.INCRBY and TS.DECRBY
+type seriesOptions struct {
+	retention       *int64
+	chunkSize       *int64
+	uncompressed    bool
+	duplicatePolicy string
+	onDuplicate     string
+	labels          []label
+	hasLabels       bool
+	timestamp       string
+}
+
+var duplicatePolicies = map[string]bool{"block": true, "first": true, "last": true, "min": true, "max": true, "sum": true}
+
+// parseSeriesOptions parses the options of a series. LABELS takes the remaining arguments.
+func parseSeriesOptions(args []string) (*seriesOptions, error) {
+	options := &seriesOptions{}
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "RETENTION":
+			if i+1 >= len(args) {
+				return nil, errInvalidRetain
+			}
+			retention, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || retention < 0 {
+				return nil, errInvalidRetain
+			}
+			options.retention = &retention
+			i++
+		case "CHUNK_SIZE":
+			if i+1 >= len(args) {
+				return nil, errInvalidChunk
+			}
+			size, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || size%8 != 0 || size < 128 || size > 1048576 {
+				return nil, errInvalidChunk
+			}
+			options.chunkSize = &size
+			i++
+		case "UNCOMPRESSED":
+			options.uncompressed = true
+		case "DUPLICATE_POLICY", "ON_DUPLICATE":
+			if i+1 >= len(args) || !duplicatePolicies[strings.ToLower(args[i+1])] {
+				return nil, errInvalidPolicy
+			}
+			if strings.EqualFold(args[i], "ON_DUPLICATE") {
+				options.onDuplicate = strings.ToLower(args[i+1])
+			} else {
+				options.duplicatePolicy = strings.ToLower(args[i+1])
+			}
+			i++
+		case "TIMESTAMP":
+			if i+1 >= len(args) {
+				return nil, errInvalidTs
+			}
+			options.timestamp = args[i+1]
+			i++
+		case "LABELS":
+			rest := args[i+1:]
+			if len(rest)%2 != 0 {
+				return nil, errInvalidLabels
+			}
+			options.hasLabels = true
+			options.labels = []label{}
+			for j := 0; j < len(rest); j += 2 {
+				if rest[j] == "" || rest[j+1] == "" {
+					return nil, errInvalidLabels
+				}
+				options.labels = append(options.labels, label{name: rest[j], value: rest[j+1]})
+			}
+			return options, nil
+		default:
+			return nil, errSyntax
+		}
+	}
+	return options, nil
+}
+
+func (options *seriesOptions) newSeries() *series {
+	s := &series{chunkSize: defaultChunkSize, uncompressed: options.uncompressed,
+		duplicatePolicy: options.duplicatePolicy, labels: options.labels}
+	if s.labels == nil {
+		s.labels = []label{}
+	}
+	if options.retention != nil {
+		s.retention = *options.retention
+	}
+	if options.chunkSize != nil {
+		s.chunkSize = *options.chunkSize
+	}
+	return s
+}
+
+// policy returns the duplicate policy resolving the conflicts of a sample added to series
+func (s *Server) policy(series *series, options *seriesOptions) string {
+	if options != nil && options.onDuplicate != "" {
+		return options.onDuplicate
+	}
+	if series.duplicatePolicy != "" {
+		return series.duplicatePolicy
+	}
+	return s.duplicatePolicy
+}
+
+// parseSampleTimestamp parses the timestamp of a sample, "*" being the current time
+func (s *Server) parseSampleTimestamp(arg string) (int64, error) {
+	if arg == "*" {
+		return s.now(), nil
+	}
+	ts, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || ts < 0 {
+		return 0, errInvalidTs
+	}
+	return ts, nil
+}
+
+func parseValue(arg string) (float64, error) {
+	value, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(value) {
+		return 0, errInvalidValue
+	}
+	return value, nil
+}
+
+func tsCreate(s *Server, session *session, args []string) interface{} {
+	db := s.db(session.db)
+	if db.exists(args[0]) {
+		return errKeyExists
+	}
+	options, err := parseSeriesOptions(args[1:])
+	if err != nil {
+		return err
+	}
+	db.series[args[0]] = options.newSeries()
+	db.touch(args[0])
+	return ok
+}
+
+func tsAlter(s *Server, session *session, args []string) interface{} {
+	db := s.db(session.db)
+	series, err := db.get(args[0])
+	if err != nil {
+		return err
+	}
+	options, err := parseSeriesOptions(args[1:])
+	if err != nil {
+		return err
+	}
+	if options.retention != nil {
+		series.retention = *options.retention
+		series.trim()
+	}
+	if options.chunkSize != nil {
+		series.chunkSize = *options.chunkSize
+	}
+	if options.duplicatePolicy != "" {
+		series.duplicatePolicy = options.duplicatePolicy
+	}
+	if options.hasLabels {
+		series.labels = options.labels
+	}
+	db.touch(args[0])
+	return ok
+}
+
+// seriesForWrite returns the series of key, created with the options when it does not exist
+func seriesForWrite(db *database, key string, options *seriesOptions) (*series, error) {
+	series, err := db.get(key)
+	if err == errKeyNotFound {
+		series = options.newSeries()
+		db.series[key] = series
+		return series, nil
+	}
+	return series, err
+}
+
+func tsAdd(s *Server, session *session, args []string) interface{} {
+	ts, err := s.parseSampleTimestamp(args[1])
+	if err != nil {
+		return err
+	}
+	value, err := parseValue(args[2])
+	if err != nil {
+		return err
+	}
+	options, err := parseSeriesOptions(args[3:])
+	if err != nil {
+		return err
+	}
+	db := s.db(session.db)
+	series, err := seriesForWrite(db, args[0], options)
+	if err != nil {
+		return err
+	}
+	if err := db.add(args[0], series, ts, value, s.policy(series, options)); err != nil {
+		return err
+	}
+	return ts
+}
+
+func tsMadd(s *Server, session *session, args []string) interface{} {
+	if len(args)%3 != 0 {
+		return replyError("ERR wrong number of arguments for 'ts.madd' command")
+	}
+	db := s.db(session.db)
+	replies := make([]interface{}, 0, len(args)/3)
+	for i := 0; i < len(args); i += 3 {
+		replies = append(replies, s.madd(db, args[i], args[i+1], args[i+2]))
+	}
+	return replies
+}
+
+func (s *Server) madd(db *database, key, timestamp, rawValue string) interface{} {
+	series, err := db.get(key)
+	if err != nil {
+		return err
+	}
+	ts, err := s.parseSampleTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+	value, err := parseValue(rawValue)
+	if err != nil {
+		return err
+	}
+	if err := db.add(key, series, ts, value, s.policy(series, nil)); err != nil {
+		return err
+	}
+	return ts
+}
+
+func tsIncrBy(s *Server, session *session, args []string) interface{} {
+	return s.incrBy(session, args, 1)
+}
+
+func tsDecrBy(s *Server, session *session, args []string) interface{} {
+	return s.incrBy(session, args, -1)
+}
+
+// incrBy adds sign times the value to the last sample, at the current time or the given TIMESTAMP
+func (s *Server) incrBy(session *session, args []string, sign float64) interface{} {
+	value, err := parseValue(args[1])
+	if err != nil {
+		return err
+	}
+	options, err := parseSeriesOptions(args[2:])
+	if err != nil {
+		return err
+	}
+	ts := s.now()
+	if options.timestamp != "" {
+		if ts, err = s.parseSampleTimestamp(options.timestamp); err != nil {
+			return err
+		}
+	}
+	db := s.db(session.db)
+	series, err := seriesForWrite(db, args[0], options)
+	if err != nil {
+		return err
+	}
+	value *= sign
+	if last, found := series.last(); found {
+		if ts < last.ts {
+			return errNotLatest
+		}
+		value += last.value
+	}
+	if err := db.add(args[0], series, ts, value, "last"); err != nil {
+		return err
+	}
+	return ts
+}
+
+func samplesReply(samples []sample) []interface{} {
+	reply := make([]interface{}, len(samples))
+	for i, s := range samples {
+		reply[i] = []interface{}{s.ts, formatValue(s.value)}
+	}
+	return reply
+}
+
+func tsRange(s *Server, session *session, args []string) interface{} {
+	return s.rangeOf(session, args, false)
+}
+
+func tsRevRange(s *Server, session *session, args []string) interface{} {
+	return s.rangeOf(session, args, true)
+}
+
+func (s *Server) rangeOf(session *session, args []string, reverse bool) interface{} {
+	series, err := s.db(session.db).get(args[0])
+	if err != nil {
+		return err
+	}
+	q, err := parseRangeQuery(args[1:], reverse, false, s.moduleVersion)
+	if err != nil {
+		return err
+	}
+	return samplesReply(q.run(series))
+}
+
+func tsMrange(s *Server, session *session, args []string) interface{} {
+	return s.multiRange(session, args, false)
+}
+
+func tsMrevRange(s *Server, session *session, args []string) interface{} {
+	return s.multiRange(session, args, true)
+}
+
+func (s *Server) multiRange(session *session, args []string, reverse bool) interface{} {
+	q, err := parseRangeQuery(args, reverse, true, s.moduleVersion)
+	if err != nil {
+		return err
+	}
+	matchers, err := parseFilters(q.filters)
+	if err != nil {
+		return err
+	}
+	db := s.db(session.db)
+	keys := db.query(matchers)
+	results := make(map[string][]sample, len(keys))
+	for _, key := range keys {
+		results[key] = q.run(db.series[key])
+	}
+	reply := []interface{}{}
+	if q.groupBy != "" {
+		for _, g := range q.reduceGroups(db, keys, results) {
+			labels := []interface{}{
+				[]interface{}{q.groupBy, g.value},
+				[]interface{}{"__reducer__", q.reduce},
+				[]interface{}{"__source__", strings.Join(g.keys, ",")},
+			}
+			reply = append(reply, []interface{}{q.groupBy + "=" + g.value, labels, samplesReply(q.reduced(g))})
+		}
+		return reply
+	}
+	for _, key := range keys {
+		labels := labelsReply(db.series[key], q.withLabels, q.selectedLabels)
+		reply = append(reply, []interface{}{key, labels, samplesReply(results[key])})
+	}
+	return reply
+}
+
+// labelsReply returns every label of s with withLabels, or the selected ones, nil when s does not have them
+func labelsReply(s *series, withLabels bool, selected []string) []interface{} {
+	reply := []interface{}{}
+	if withLabels {
+		for _, l := range s.labels {
+			reply = append(reply, []interface{}{l.name, l.value})
+		}
+		return reply
+	}
+	for _, name := range selected {
+		if value, found := s.label(name); found {
+			reply = append(reply, []interface{}{name, value})
+		} else {
+			reply = append(reply, []interface{}{name, nil})
+		}
+	}
+	return reply
+}
+
+func lastReply(s *series) []interface{} {
+	last, found := s.last()
+	if !found {
+		return []interface{}{}
+	}
+	return []interface{}{last.ts, formatValue(last.value)}
+}
+
+func tsGet(s *Server, session *session, args []string) interface{} {
+	series, err := s.db(session.db).get(args[0])
+	if err != nil {
+		return err
+	}
+	return lastReply(series)
+}
+
+func tsMget(s *Server, session *session, args []string) interface{} {
+	withLabels, selected := false, []string(nil)
+	i := 0
+	for ; i < len(args) && !strings.EqualFold(args[i], "FILTER"); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WITHLABELS":
+			withLabels = true
+		case "SELECTED_LABELS":
+			if s.moduleVersion < ModuleVersion16 {
+				return errSyntax
+			}
+			for i+1 < len(args) && !strings.EqualFold(args[i+1], "FILTER") {
+				selected = append(selected, args[i+1])
+				i++
+			}
+		default:
+			return errSyntax
+		}
+	}
+	if i >= len(args) {
+		return errMissingFilter
+	}
+	matchers, err := parseFilters(args[i+1:])
+	if err != nil {
+		return err
+	}
+	db := s.db(session.db)
+	reply := []interface{}{}
+	for _, key := range db.query(matchers) {
+		series := db.series[key]
+		reply = append(reply, []interface{}{key, labelsReply(series, withLabels, selected), lastReply(series)})
+	}
+	return reply
+}
+
+func tsInfo(s *Server, session *session, args []string) interface{} {
+	series, err := s.db(session.db).get(args[0])
+	if err != nil {
+		return err
+	}
+	first, last := int64(0), int64(0)
+	if len(series.samples) > 0 {
+		first, last = series.samples[0].ts, series.samples[len(series.samples)-1].ts
+	}
+	chunkType := "compressed"
+	if series.uncompressed {
+		chunkType = "uncompressed"
+	}
+	var duplicatePolicy interface{}
+	if series.duplicatePolicy != "" {
+		duplicatePolicy = series.duplicatePolicy
+	}
+	var sourceKey interface{}
+	if series.source != "" {
+		sourceKey = series.source
+	}
+	rules := make([]interface{}, len(series.rules))
+	for i, r := range series.rules {
+		rules[i] = []interface{}{r.dest, r.bucket, strings.ToUpper(r.agg)}
+	}
+	chunkCount := series.chunkCount()
+	return []interface{}{
+		"totalSamples", int64(len(series.samples)),
+		"memoryUsage", 256 + chunkCount*series.chunkSize,
+		"firstTimestamp", first,
+		"lastTimestamp", last,
+		"retentionTime", series.retention,
+		"chunkCount", chunkCount,
+		"chunkSize", series.chunkSize,
+		"chunkType", chunkType,
+		"duplicatePolicy", duplicatePolicy,
+		"labels", labelsReply(series, true, nil),
+		"sourceKey", sourceKey,
+		"rules", rules,
+	}
+}
+
+func tsQueryIndex(s *Server, session *session, args []string) interface{} {
+	matchers, err := parseFilters(args)
+	if err != nil {
+		return err
+	}
+	keys := s.db(session.db).query(matchers)
+	reply := make([]interface{}, len(keys))
+	for i, key := range keys {
+		reply[i] = key
+	}
+	return reply
+}
+
+func tsDel(s *Server, session *session, args []string) interface{} {
+	if s.moduleVersion < ModuleVersion16 {
+		return errorf("ERR unknown command `TS.DEL`, with args beginning with: ")
+	}
+	db := s.db(session.db)
+	series, err := db.get(args[0])
+	if err != nil {
+		return err
+	}
+	from, err := parseTimestamp(args[1], 0)
+	if err != nil {
+		return err
+	}
+	to, err := parseTimestamp(args[2], math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	kept := series.samples[:0]
+	for _, sample := range series.samples {
+		if sample.ts < from || sample.ts > to {
+			kept = append(kept, sample)
+		}
+	}
+	deleted := int64(len(series.samples) - len(kept))
+	series.samples = kept
+	db.touch(args[0])
+	return deleted
+}
+
+func tsCreateRule(s *Server, session *session, args []string) interface{} {
+	if len(args) != 5 || !strings.EqualFold(args[2], "AGGREGATION") {
+		return errSyntax
+	}
+	agg := strings.ToLower(args[3])
+	if !aggregators[agg] {
+		return errInvalidAgg
+	}
+	bucket, err := strconv.ParseInt(args[4], 10, 64)
+	if err != nil || bucket <= 0 {
+		return errInvalidBucket
+	}
+	if args[0] == args[1] {
+		return errRuleSameKey
+	}
+	db := s.db(session.db)
+	source, err := db.get(args[0])
+	if err != nil {
+		return err
+	}
+	dest, err := db.get(args[1])
+	if err != nil {
+		return err
+	}
+	if dest.source != "" || source.source != "" {
+		return errRuleExists
+	}
+	for _, r := range dest.rules {
+		if r.dest == args[0] {
+			return errRuleExists
+		}
+	}
+	source.rules = append(source.rules, &rule{dest: args[1], agg: agg, bucket: bucket})
+	dest.source = args[0]
+	db.touch(args[0])
+	db.touch(args[1])
+	return ok
+}
+
+func tsDeleteRule(s *Server, session *session, args []string) interface{} {
+	db := s.db(session.db)
+	source, err := db.get(args[0])
+	if err != nil {
+		return err
+	}
+	dest, err := db.get(args[1])
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, r := range source.rules {
+		found = found || r.dest == args[1]
+	}
+	if !found {
+		return errRuleNotFound
+	}
+	source.rules = removeRule(source.rules, args[1])
+	dest.source = ""
+	db.touch(args[0])
+	db.touch(args[1])
+	return ok
+}
@@ -0,0 +1,407 @@
+package tstest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// aggregators are the aggregation types of TS.RANGE and TS.CREATERULE, the reducers of GROUPBY being all but first and last
+var aggregators = map[string]bool{
+	"avg": true, "sum": true, "min": true, "max": true, "range": true, "count": true, "first": true, "last": true,
+	"std.p": true, "std.s": true, "var.p": true, "var.s": true,
+}
+
+// aggregate aggregates the values of samples, which are not empty
+func aggregate(agg string, samples []sample) float64 {
+	switch agg {
+	case "count":
+		return float64(len(samples))
+	case "first":
+		return samples[0].value
+	case "last":
+		return samples[len(samples)-1].value
+	}
+	sum, min, max := 0.0, math.Inf(1), math.Inf(-1)
+	for _, s := range samples {
+		sum += s.value
+		min = math.Min(min, s.value)
+		max = math.Max(max, s.value)
+	}
+	n := float64(len(samples))
+	switch agg {
+	case "sum":
+		return sum
+	case "min":
+		return min
+	case "max":
+		return max
+	case "range":
+		return max - min
+	case "avg":
+		return sum / n
+	}
+	mean, squares := sum/n, 0.0
+	for _, s := range samples {
+		squares += (s.value - mean) * (s.value - mean)
+	}
+	variance := squares / n
+	if strings.HasSuffix(agg, ".s") {
+		variance = 0
+		if n > 1 {
+			variance = squares / (n - 1)
+		}
+	}
+	if strings.HasPrefix(agg, "std") {
+		return math.Sqrt(variance)
+	}
+	return variance
+}
+
+// bucketStart returns the start of the bucket of duration bucket holding ts, the buckets being aligned to align
+func bucketStart(ts, bucket, align int64) int64 {
+	offset := (ts - align) % bucket
+	if offset < 0 {
+		offset += bucket
+	}
+	return ts - offset
+}
+
+// matcher is a label filter of TS.MRANGE, TS.MGET and TS.QUERYINDEX
+type matcher struct {
+	label  string
+	values []string
+	equal  bool
+}
+
+// parseFilters parses label=value, label!=value, label=, label!=, label=(v1,v2) and label!=(v1,v2) filters,
+// at least one of them having to match a value
+func parseFilters(filters []string) ([]matcher, error) {
+	if len(filters) == 0 {
+		return nil, errMissingFilter
+	}
+	matchers := make([]matcher, len(filters))
+	positive := false
+	for i, filter := range filters {
+		at := strings.Index(filter, "=")
+		if at <= 0 {
+			return nil, errInvalidFilter
+		}
+		m := matcher{label: filter[:at], equal: true}
+		if strings.HasSuffix(m.label, "!") {
+			m.label, m.equal = strings.TrimSuffix(m.label, "!"), false
+		}
+		value := filter[at+1:]
+		if strings.HasPrefix(value, "(") {
+			if !strings.HasSuffix(value, ")") {
+				return nil, errInvalidFilter
+			}
+			m.values = strings.Split(value[1:len(value)-1], ",")
+		} else {
+			m.values = []string{value}
+		}
+		if m.label == "" {
+			return nil, errInvalidFilter
+		}
+		if m.equal && value != "" {
+			positive = true
+		}
+		matchers[i] = m
+	}
+	if !positive {
+		return nil, errNoMatcher
+	}
+	return matchers, nil
+}
+
+func (m matcher) matches(s *series) bool {
+	value, found := s.label(m.label)
+	if len(m.values) == 1 && m.values[0] == "" {
+		return found != m.equal
+	}
+	in := false
+	for _, v := range m.values {
+		if found && v == value {
+			in = true
+		}
+	}
+	return in == m.equal
+}
+
+// query returns the keys of the series matching every matcher, in lexicographic order
+func (db *database) query(matchers []matcher) []string {
+	keys := []string{}
+	for key, s := range db.series {
+		matched := true
+		for _, m := range matchers {
+			if !m.matches(s) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rangeQuery holds the options of TS.RANGE, TS.REVRANGE, TS.MRANGE and TS.MREVRANGE
+type rangeQuery struct {
+	from, to int64
+	reverse  bool
+	// filterTs, when not nil, are the only timestamps returned
+	filterTs                 map[int64]bool
+	filterValue              bool
+	minValue, maxValue       float64
+	count                    int64
+	align                    string
+	agg                      string
+	bucket                   int64
+	withLabels               bool
+	selectedLabels           []string
+	filters                  []string
+	groupBy, reduce          string
+	hasSelectedLabels, multi bool
+}
+
+func parseTimestamp(arg string, dflt int64) (int64, error) {
+	if arg == "-" || arg == "+" {
+		return dflt, nil
+	}
+	ts, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || ts < 0 {
+		return 0, errInvalidTs
+	}
+	return ts, nil
+}
+
+// parseRangeQuery parses the arguments of a range command following the key, if any,
+// rejecting the options introduced after version
+func parseRangeQuery(args []string, reverse, multi bool, version int64) (*rangeQuery, error) {
+	if len(args) < 2 {
+		return nil, errSyntax
+	}
+	q := &rangeQuery{reverse: reverse, multi: multi, count: -1}
+	var err error
+	if q.from, err = parseTimestamp(args[0], 0); err != nil {
+		return nil, err
+	}
+	if q.to, err = parseTimestamp(args[1], math.MaxInt64); err != nil {
+		return nil, err
+	}
+	for i := 2; i < len(args); i++ {
+		keyword := strings.ToUpper(args[i])
+		if version < ModuleVersion16 && options16[keyword] {
+			return nil, errSyntax
+		}
+		switch keyword {
+		case "FILTER_BY_TS":
+			q.filterTs = map[int64]bool{}
+			for i+1 < len(args) {
+				ts, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil {
+					break
+				}
+				q.filterTs[ts] = true
+				i++
+			}
+		case "FILTER_BY_VALUE":
+			if i+2 >= len(args) {
+				return nil, errInvalidValueMin
+			}
+			min, minErr := strconv.ParseFloat(args[i+1], 64)
+			max, maxErr := strconv.ParseFloat(args[i+2], 64)
+			if minErr != nil || maxErr != nil {
+				return nil, errInvalidValueMin
+			}
+			q.filterValue, q.minValue, q.maxValue = true, min, max
+			i += 2
+		case "COUNT":
+			if i+1 >= len(args) {
+				return nil, errInvalidCount
+			}
+			if q.count, err = strconv.ParseInt(args[i+1], 10, 64); err != nil || q.count < 0 {
+				return nil, errInvalidCount
+			}
+			i++
+		case "ALIGN":
+			if i+1 >= len(args) {
+				return nil, errInvalidAlign
+			}
+			q.align = args[i+1]
+			i++
+		case "AGGREGATION":
+			if i+2 >= len(args) {
+				return nil, errSyntax
+			}
+			q.agg = strings.ToLower(args[i+1])
+			if !aggregators[q.agg] {
+				return nil, errInvalidAgg
+			}
+			if q.bucket, err = strconv.ParseInt(args[i+2], 10, 64); err != nil || q.bucket <= 0 {
+				return nil, errInvalidBucket
+			}
+			i += 2
+		case "WITHLABELS":
+			if !multi {
+				return nil, errSyntax
+			}
+			q.withLabels = true
+		case "SELECTED_LABELS":
+			if !multi {
+				return nil, errSyntax
+			}
+			q.hasSelectedLabels = true
+			for i+1 < len(args) && !isRangeKeyword(args[i+1]) {
+				q.selectedLabels = append(q.selectedLabels, args[i+1])
+				i++
+			}
+		case "FILTER":
+			if !multi {
+				return nil, errSyntax
+			}
+			for i+1 < len(args) && !strings.EqualFold(args[i+1], "GROUPBY") {
+				q.filters = append(q.filters, args[i+1])
+				i++
+			}
+		case "GROUPBY":
+			if !multi || i+3 >= len(args) || !strings.EqualFold(args[i+2], "REDUCE") {
+				return nil, errInvalidGroupBy
+			}
+			q.groupBy, q.reduce = args[i+1], strings.ToLower(args[i+3])
+			if !aggregators[q.reduce] || q.reduce == "first" || q.reduce == "last" {
+				return nil, errInvalidReducer
+			}
+			i += 3
+		default:
+			return nil, errSyntax
+		}
+	}
+	if q.align != "" && q.agg == "" {
+		return nil, errInvalidAlign
+	}
+	if _, err := q.alignment(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// options16 are the range options introduced by RedisTimeSeries 1.6
+var options16 = map[string]bool{"FILTER_BY_TS": true, "FILTER_BY_VALUE": true, "ALIGN": true, "SELECTED_LABELS": true, "GROUPBY": true}
+
+func isRangeKeyword(arg string) bool {
+	switch strings.ToUpper(arg) {
+	case "FILTER_BY_TS", "FILTER_BY_VALUE", "COUNT", "ALIGN", "AGGREGATION", "WITHLABELS", "SELECTED_LABELS", "FILTER", "GROUPBY":
+		return true
+	}
+	return false
+}
+
+// alignment returns the timestamp the buckets are aligned to
+func (q *rangeQuery) alignment() (int64, error) {
+	switch q.align {
+	case "":
+		return 0, nil
+	case "-", "start":
+		return q.from, nil
+	case "+", "end":
+		return q.to, nil
+	}
+	align, err := strconv.ParseInt(q.align, 10, 64)
+	if err != nil {
+		return 0, errInvalidAlign
+	}
+	return align, nil
+}
+
+// run returns the samples of s selected by the query, aggregated, in the order of the query
+func (q *rangeQuery) run(s *series) []sample {
+	selected := []sample{}
+	for _, sample := range s.between(q.from, q.to) {
+		if q.filterTs != nil && !q.filterTs[sample.ts] {
+			continue
+		}
+		if q.filterValue && (sample.value < q.minValue || sample.value > q.maxValue) {
+			continue
+		}
+		selected = append(selected, sample)
+	}
+	if q.agg != "" {
+		align, _ := q.alignment()
+		buckets := []sample{}
+		for start := 0; start < len(selected); {
+			bucket := bucketStart(selected[start].ts, q.bucket, align)
+			end := start
+			for end < len(selected) && selected[end].ts < bucket+q.bucket {
+				end++
+			}
+			buckets = append(buckets, sample{ts: bucket, value: aggregate(q.agg, selected[start:end])})
+			start = end
+		}
+		selected = buckets
+	}
+	if q.reverse {
+		for i, j := 0, len(selected)-1; i < j; i, j = i+1, j-1 {
+			selected[i], selected[j] = selected[j], selected[i]
+		}
+	}
+	if q.count >= 0 && int64(len(selected)) > q.count {
+		selected = selected[:q.count]
+	}
+	return selected
+}
+
+// group reduces the samples of the series sharing the same value of a label, per timestamp
+type group struct {
+	value   string
+	keys    []string
+	samples map[int64][]sample
+}
+
+// reduceGroups groups the samples of the series of keys by the value of the GROUPBY label, and reduces them
+func (q *rangeQuery) reduceGroups(db *database, keys []string, results map[string][]sample) []*group {
+	groups := map[string]*group{}
+	for _, key := range keys {
+		value, found := db.series[key].label(q.groupBy)
+		if !found {
+			continue
+		}
+		g, exists := groups[value]
+		if !exists {
+			g = &group{value: value, samples: map[int64][]sample{}}
+			groups[value] = g
+		}
+		g.keys = append(g.keys, key)
+		for _, s := range results[key] {
+			g.samples[s.ts] = append(g.samples[s.ts], s)
+		}
+	}
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].value < sorted[j].value })
+	return sorted
+}
+
+// reduced returns the samples of the group, reduced per timestamp, in the order of the query.
+// COUNT applies to the reduced samples, as the server replies at most COUNT samples per group.
+func (q *rangeQuery) reduced(g *group) []sample {
+	samples := make([]sample, 0, len(g.samples))
+	for ts, values := range g.samples {
+		samples = append(samples, sample{ts: ts, value: aggregate(q.reduce, values)})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		if q.reverse {
+			return samples[i].ts > samples[j].ts
+		}
+		return samples[i].ts < samples[j].ts
+	})
+	if q.count >= 0 && int64(len(samples)) > q.count {
+		samples = samples[:q.count]
+	}
+	return samples
+}
@@ -0,0 +1,105 @@
+package tstest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Status is replied as a RESP simple string, while plain strings are replied as bulk strings
+type Status string
+
+// replyError is replied as a RESP error
+type replyError string
+
+func (e replyError) Error() string {
+	return string(e)
+}
+
+// nilArray is replied as a RESP null array, such as by EXEC when a watched key changed
+type nilArray struct{}
+
+var ok = Status("OK")
+
+func errorf(format string, args ...interface{}) replyError {
+	return replyError(fmt.Sprintf(format, args...))
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// ReadCommand reads a command sent as a RESP array of bulk strings, or inline
+func ReadCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		return nil, errors.New("invalid multibulk length")
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, errors.New("expected a bulk string")
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, errors.New("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// WriteReply writes reply in RESP: nil as a null bulk string, a Status as a simple string, an error as an error,
+// an int or an int64 as an integer, a string as a bulk string, and a []interface{} as an array of such replies
+func WriteReply(w *bufio.Writer, reply interface{}) {
+	switch reply := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case nilArray:
+		w.WriteString("*-1\r\n")
+	case Status:
+		w.WriteString("+" + string(reply) + "\r\n")
+	case error:
+		w.WriteString("-" + reply.Error() + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(reply, 10) + "\r\n")
+	case int:
+		w.WriteString(":" + strconv.Itoa(reply) + "\r\n")
+	case string:
+		w.WriteString("$" + strconv.Itoa(len(reply)) + "\r\n" + reply + "\r\n")
+	case []interface{}:
+		w.WriteString("*" + strconv.Itoa(len(reply)) + "\r\n")
+		for _, element := range reply {
+			WriteReply(w, element)
+		}
+	default:
+		panic(fmt.Sprintf("tstest: unexpected reply type %T", reply))
+	}
+}
+
+// formatValue formats a sample value as the server does, the shortest representation parsing back to value
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
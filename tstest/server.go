@@ -0,0 +1,219 @@
+// Package tstest provides an in-process fake RedisTimeSeries server speaking RESP, so that clients can be tested
+// end-to-end without any external service, like net/http/httptest does for HTTP.
+//
+// The server keeps the series in memory, and implements TS.CREATE, TS.ALTER, TS.ADD, TS.MADD, TS.INCRBY, TS.DECRBY,
+// TS.RANGE, TS.REVRANGE, TS.MRANGE, TS.MREVRANGE, TS.GET, TS.MGET, TS.INFO, TS.QUERYINDEX, TS.DEL, TS.CREATERULE
+// and TS.DELETERULE, with retention, duplicate policies, label filters, aggregations, GROUPBY/REDUCE and compaction
+// rules, along with the generic commands clients rely on: PING, ECHO, AUTH, SELECT, CLIENT, DEL, EXISTS, TYPE,
+// GET, SET, FLUSHALL, FLUSHDB, MODULE LIST, MULTI, EXEC, DISCARD, WATCH and UNWATCH.
+// It replies with the error messages of RedisTimeSeries 1.4, whose version it reports in MODULE LIST by default.
+// The options introduced by RedisTimeSeries 1.6, FILTER_BY_TS, FILTER_BY_VALUE, ALIGN, GROUPBY/REDUCE and
+// SELECTED_LABELS, as well as TS.DEL, are only accepted once SetModuleVersion reports 1.6 or later.
+package tstest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModuleVersion is the RedisTimeSeries version reported by MODULE LIST by default, 1.4.10
+const ModuleVersion = 10410
+
+// ModuleVersion16 is the first RedisTimeSeries version accepting the options of 1.6, 1.6.0
+const ModuleVersion16 = 10600
+
+// Server is an in-process fake RedisTimeSeries server.
+// The commands are run one at a time, as by Redis, whatever the number of connections.
+type Server struct {
+	mu              sync.Mutex
+	ln              net.Listener
+	dbs             map[int]*database
+	clock           func() time.Time
+	password        string
+	duplicatePolicy string
+	moduleVersion   int64
+	conns           map[net.Conn]struct{}
+	wg              sync.WaitGroup
+	closed          bool
+}
+
+// NewServer starts a Server listening on a random port of the loopback interface.
+// It panics when it can not listen, as httptest.NewServer does.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("tstest: failed to listen on a port: %v", err))
+	}
+	s := &Server{
+		ln:              ln,
+		dbs:             map[int]*database{},
+		clock:           time.Now,
+		duplicatePolicy: "block",
+		moduleVersion:   ModuleVersion,
+		conns:           map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr returns the address the server listens on, as host:port
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Dial returns a connection to the server over an in-memory net.Pipe, bypassing the network
+func (s *Server) Dial() (net.Conn, error) {
+	client, server := net.Pipe()
+	if !s.track(server) {
+		client.Close()
+		server.Close()
+		return nil, fmt.Errorf("tstest: server closed")
+	}
+	go s.serveConn(server)
+	return client, nil
+}
+
+// Close stops listening, closes the connections, and waits for them to be released
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.ln.Close()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// SetClock sets the clock giving the timestamps of the samples added with the automatic timestamp "*"
+func (s *Server) SetClock(clock func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = clock
+}
+
+// RequirePass makes the connections authenticate with AUTH and password before issuing any other command.
+// An empty password disables the authentication.
+func (s *Server) RequirePass(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// SetDuplicatePolicy sets the duplicate policy of the series created without DUPLICATE_POLICY, "block" by default,
+// as the DUPLICATE_POLICY module argument does
+func (s *Server) SetDuplicatePolicy(policy string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.duplicatePolicy = strings.ToLower(policy)
+}
+
+// SetModuleVersion sets the RedisTimeSeries version reported by MODULE LIST, such as 10600 for 1.6.0.
+// The commands and options introduced by a later version are rejected.
+func (s *Server) SetModuleVersion(version int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.moduleVersion = version
+}
+
+// FlushAll deletes the keys of every database
+func (s *Server) FlushAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushAll()
+}
+
+// Keys returns the keys of database 0, in lexicographic order
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db(0).keys()
+}
+
+func (s *Server) flushAll() {
+	for _, db := range s.dbs {
+		db.flush()
+	}
+}
+
+// db returns the database of index, creating it on first use. The lock must be held.
+func (s *Server) db(index int) *database {
+	db, found := s.dbs[index]
+	if !found {
+		db = newDatabase()
+		s.dbs[index] = db
+	}
+	return db
+}
+
+// now returns the current time in milliseconds, according to the clock. The lock must be held.
+func (s *Server) now() int64 {
+	return s.clock().UnixNano() / int64(time.Millisecond)
+}
+
+// track registers conn, unless the server is closed
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	session := &session{}
+	for {
+		args, err := ReadCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		s.mu.Lock()
+		reply := s.execute(session, args)
+		s.mu.Unlock()
+		WriteReply(w, reply)
+		if r.Buffered() == 0 || session.quit {
+			if w.Flush() != nil || session.quit {
+				return
+			}
+		}
+	}
+}
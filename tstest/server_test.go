@@ -0,0 +1,393 @@
+package tstest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func dial(t *testing.T, s *Server) redis.Conn {
+	conn, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// run issues each command on conn, failing the test on any error
+func run(t *testing.T, conn redis.Conn, commands ...[]interface{}) {
+	for _, command := range commands {
+		if _, err := conn.Do(command[0].(string), command[1:]...); err != nil {
+			t.Fatalf("%v: %v", command, err)
+		}
+	}
+}
+
+func cmd(args ...interface{}) []interface{} {
+	return args
+}
+
+func TestServer_DuplicatePolicies(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	conn := dial(t, s)
+	defer conn.Close()
+	tests := []struct {
+		name    string
+		options []interface{}
+		want    string
+		wantErr string
+	}{
+		{"server default", nil, "", string(errBlocked)},
+		{"first", []interface{}{"DUPLICATE_POLICY", "FIRST"}, "1", ""},
+		{"last", []interface{}{"DUPLICATE_POLICY", "last"}, "2", ""},
+		{"min", []interface{}{"DUPLICATE_POLICY", "min"}, "1", ""},
+		{"max", []interface{}{"DUPLICATE_POLICY", "max"}, "2", ""},
+		{"sum", []interface{}{"DUPLICATE_POLICY", "sum"}, "3", ""},
+		{"on duplicate", []interface{}{"DUPLICATE_POLICY", "block", "ON_DUPLICATE", "max"}, "2", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			create := []interface{}{tt.name}
+			add := []interface{}{tt.name, 10, 2}
+			if len(tt.options) == 4 {
+				add = append(add, tt.options[2:]...)
+				create = append(create, tt.options[:2]...)
+			} else {
+				create = append(create, tt.options...)
+			}
+			run(t, conn, append(cmd("TS.CREATE"), create...), cmd("TS.ADD", tt.name, 10, 1))
+			_, err := conn.Do("TS.ADD", add...)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			values, err := redis.Values(conn.Do("TS.GET", tt.name))
+			assert.NoError(t, err)
+			assert.Equal(t, []interface{}{int64(10), []byte(tt.want)}, values)
+		})
+	}
+}
+
+func TestServer_Retention(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	conn := dial(t, s)
+	defer conn.Close()
+	run(t, conn, cmd("TS.CREATE", "retained", "RETENTION", 100),
+		cmd("TS.MADD", "retained", 1, 1, "retained", 50, 2, "retained", 150, 3))
+	_, err := conn.Do("TS.ADD", "retained", 10, 4)
+	assert.EqualError(t, err, string(errTooOld))
+	values, err := redis.Values(conn.Do("TS.RANGE", "retained", "-", "+"))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{int64(50), []byte("2")},
+		[]interface{}{int64(150), []byte("3")},
+	}, values)
+}
+
+func TestServer_Range(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetModuleVersion(ModuleVersion16)
+	conn := dial(t, s)
+	defer conn.Close()
+	run(t, conn, cmd("TS.CREATE", "ranged"))
+	for ts := 0; ts < 10; ts++ {
+		run(t, conn, cmd("TS.ADD", "ranged", ts*10+5, ts))
+	}
+	tests := []struct {
+		name string
+		args []interface{}
+		want []int64
+	}{
+		{"all", cmd("TS.RANGE", "ranged", "-", "+", "COUNT", 3), []int64{5, 0, 15, 1, 25, 2}},
+		{"reverse", cmd("TS.REVRANGE", "ranged", 20, 50), []int64{45, 4, 35, 3, 25, 2}},
+		{"filter by ts", cmd("TS.RANGE", "ranged", "-", "+", "FILTER_BY_TS", 15, 16, 45), []int64{15, 1, 45, 4}},
+		{"filter by value", cmd("TS.RANGE", "ranged", "-", "+", "FILTER_BY_VALUE", 7, 20), []int64{75, 7, 85, 8, 95, 9}},
+		{"aggregation", cmd("TS.RANGE", "ranged", 0, 39, "AGGREGATION", "sum", 20), []int64{0, 1, 20, 5}},
+		{"aligned to start", cmd("TS.RANGE", "ranged", 5, 44, "ALIGN", "-", "AGGREGATION", "count", 20),
+			[]int64{5, 2, 25, 2}},
+		{"aligned to a timestamp", cmd("TS.RANGE", "ranged", 15, 50, "ALIGN", 15, "AGGREGATION", "max", 20),
+			[]int64{15, 2, 35, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := redis.Values(conn.Do(tt.args[0].(string), tt.args[1:]...))
+			assert.NoError(t, err)
+			got := []int64{}
+			for _, value := range values {
+				point, err := redis.Int64s(value, nil)
+				assert.NoError(t, err)
+				got = append(got, point...)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServer_MultiRange(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetModuleVersion(ModuleVersion16)
+	conn := dial(t, s)
+	defer conn.Close()
+	run(t, conn,
+		cmd("TS.CREATE", "b", "LABELS", "host", "b", "region", "eu"),
+		cmd("TS.CREATE", "a", "LABELS", "host", "a", "region", "eu"),
+		cmd("TS.CREATE", "c", "LABELS", "host", "c", "region", "us"),
+		cmd("TS.CREATE", "unlabeled"),
+		cmd("TS.MADD", "a", 1, 1, "b", 1, 2, "c", 1, 4, "a", 2, 3))
+	tests := []struct {
+		name    string
+		args    []interface{}
+		want    []interface{}
+		wantErr string
+	}{
+		{"sorted by key", cmd("TS.MRANGE", "-", "+", "FILTER", "region=eu"), []interface{}{
+			[]interface{}{[]byte("a"), []interface{}{}, []interface{}{
+				[]interface{}{int64(1), []byte("1")}, []interface{}{int64(2), []byte("3")}}},
+			[]interface{}{[]byte("b"), []interface{}{}, []interface{}{[]interface{}{int64(1), []byte("2")}}},
+		}, ""},
+		{"selected labels", cmd("TS.MREVRANGE", "-", "+", "COUNT", 1, "SELECTED_LABELS", "host", "rack",
+			"FILTER", "region!=eu", "host=c"), []interface{}{
+			[]interface{}{[]byte("c"), []interface{}{
+				[]interface{}{[]byte("host"), []byte("c")}, []interface{}{[]byte("rack"), nil}},
+				[]interface{}{[]interface{}{int64(1), []byte("4")}}},
+		}, ""},
+		{"group by", cmd("TS.MRANGE", "-", "+", "FILTER", "host=(a,b,c)", "GROUPBY", "region", "REDUCE", "max"),
+			[]interface{}{
+				[]interface{}{[]byte("region=eu"), []interface{}{
+					[]interface{}{[]byte("region"), []byte("eu")},
+					[]interface{}{[]byte("__reducer__"), []byte("max")},
+					[]interface{}{[]byte("__source__"), []byte("a,b")}},
+					[]interface{}{[]interface{}{int64(1), []byte("2")}, []interface{}{int64(2), []byte("3")}}},
+				[]interface{}{[]byte("region=us"), []interface{}{
+					[]interface{}{[]byte("region"), []byte("us")},
+					[]interface{}{[]byte("__reducer__"), []byte("max")},
+					[]interface{}{[]byte("__source__"), []byte("c")}},
+					[]interface{}{[]interface{}{int64(1), []byte("4")}}},
+			}, ""},
+		{"group by count", cmd("TS.MREVRANGE", "-", "+", "COUNT", 1, "FILTER", "host=(a,b,c)", "GROUPBY", "region", "REDUCE", "max"),
+			[]interface{}{
+				[]interface{}{[]byte("region=eu"), []interface{}{
+					[]interface{}{[]byte("region"), []byte("eu")},
+					[]interface{}{[]byte("__reducer__"), []byte("max")},
+					[]interface{}{[]byte("__source__"), []byte("a,b")}},
+					[]interface{}{[]interface{}{int64(2), []byte("3")}}},
+				[]interface{}{[]byte("region=us"), []interface{}{
+					[]interface{}{[]byte("region"), []byte("us")},
+					[]interface{}{[]byte("__reducer__"), []byte("max")},
+					[]interface{}{[]byte("__source__"), []byte("c")}},
+					[]interface{}{[]interface{}{int64(1), []byte("4")}}},
+			}, ""},
+		{"missing filter", cmd("TS.MRANGE", "-", "+", "COUNT", 1), nil, string(errMissingFilter)},
+		{"no matcher", cmd("TS.MRANGE", "-", "+", "FILTER", "host!=a"), nil, string(errNoMatcher)},
+		{"unterminated list", cmd("TS.MRANGE", "-", "+", "FILTER", "host=(a"), nil, string(errInvalidFilter)},
+		{"invalid reducer", cmd("TS.MRANGE", "-", "+", "FILTER", "host=a", "GROUPBY", "region", "REDUCE", "first"),
+			nil, string(errInvalidReducer)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := redis.Values(conn.Do(tt.args[0].(string), tt.args[1:]...))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, values)
+		})
+	}
+}
+
+func TestServer_ModuleVersion(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	conn := dial(t, s)
+	defer conn.Close()
+	run(t, conn, cmd("TS.CREATE", "versioned", "LABELS", "host", "a"), cmd("TS.ADD", "versioned", 1, 1))
+	commands := [][]interface{}{
+		cmd("TS.RANGE", "versioned", "-", "+", "FILTER_BY_TS", 1),
+		cmd("TS.RANGE", "versioned", "-", "+", "FILTER_BY_VALUE", 0, 2),
+		cmd("TS.RANGE", "versioned", "-", "+", "ALIGN", "-", "AGGREGATION", "avg", 10),
+		cmd("TS.MRANGE", "-", "+", "SELECTED_LABELS", "host", "FILTER", "host=a"),
+		cmd("TS.MRANGE", "-", "+", "FILTER", "host=a", "GROUPBY", "host", "REDUCE", "max"),
+		cmd("TS.MGET", "SELECTED_LABELS", "host", "FILTER", "host=a"),
+		cmd("TS.DEL", "versioned", 0, 0),
+	}
+	tests := []struct {
+		name    string
+		version int64
+		wantErr bool
+	}{
+		{"1.4", ModuleVersion, true},
+		{"1.6", ModuleVersion16, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.SetModuleVersion(tt.version)
+			modules, err := redis.Values(conn.Do("MODULE", "LIST"))
+			assert.NoError(t, err)
+			module, err := redis.Values(modules[0], nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.version, module[3])
+			for _, command := range commands {
+				_, err := conn.Do(command[0].(string), command[1:]...)
+				assert.Equal(t, tt.wantErr, err != nil, "%v: %v", command, err)
+			}
+		})
+	}
+}
+
+func TestServer_Compaction(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	conn := dial(t, s)
+	defer conn.Close()
+	run(t, conn, cmd("TS.CREATE", "raw"), cmd("TS.CREATE", "avg"),
+		cmd("TS.CREATERULE", "raw", "avg", "AGGREGATION", "avg", 10),
+		cmd("TS.MADD", "raw", 1, 1, "raw", 5, 3, "raw", 12, 10))
+	_, err := conn.Do("TS.CREATERULE", "raw", "avg", "AGGREGATION", "avg", 10)
+	assert.EqualError(t, err, string(errRuleExists))
+
+	values, err := redis.Values(conn.Do("TS.RANGE", "avg", "-", "+"))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{int64(0), []byte("2")}}, values, "open bucket not written")
+
+	run(t, conn, cmd("TS.ADD", "raw", 25, 0), cmd("TS.ADD", "raw", 3, 8))
+	values, err = redis.Values(conn.Do("TS.RANGE", "avg", "-", "+"))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{int64(0), []byte("4")},
+		[]interface{}{int64(10), []byte("10")},
+	}, values, "late sample rewrites its bucket")
+
+	info, err := redis.Values(conn.Do("TS.INFO", "raw"))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{[]interface{}{[]byte("avg"), int64(10), []byte("AVG")}}, info[len(info)-1])
+
+	run(t, conn, cmd("TS.DELETERULE", "raw", "avg"))
+	_, err = conn.Do("TS.DELETERULE", "raw", "avg")
+	assert.EqualError(t, err, string(errRuleNotFound))
+}
+
+func TestServer_Info(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetModuleVersion(ModuleVersion16)
+	conn := dial(t, s)
+	defer conn.Close()
+	run(t, conn, cmd("TS.CREATE", "info", "RETENTION", 1000, "UNCOMPRESSED", "CHUNK_SIZE", 128, "LABELS", "a", "b"),
+		cmd("TS.MADD", "info", 1, 1, "info", 2, 2, "info", 3, 3, "info", 4, 4, "info", 5, 5, "info", 6, 6,
+			"info", 7, 7, "info", 8, 8, "info", 9, 9),
+		cmd("TS.ALTER", "info", "DUPLICATE_POLICY", "sum"))
+	info, err := redis.Values(conn.Do("TS.INFO", "info"))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		[]byte("totalSamples"), int64(9),
+		[]byte("memoryUsage"), int64(512),
+		[]byte("firstTimestamp"), int64(1),
+		[]byte("lastTimestamp"), int64(9),
+		[]byte("retentionTime"), int64(1000),
+		[]byte("chunkCount"), int64(2),
+		[]byte("chunkSize"), int64(128),
+		[]byte("chunkType"), []byte("uncompressed"),
+		[]byte("duplicatePolicy"), []byte("sum"),
+		[]byte("labels"), []interface{}{[]interface{}{[]byte("a"), []byte("b")}},
+		[]byte("sourceKey"), nil,
+		[]byte("rules"), []interface{}{},
+	}, info)
+
+	deleted, err := redis.Int(conn.Do("TS.DEL", "info", 2, 8))
+	assert.NoError(t, err)
+	assert.Equal(t, 7, deleted)
+	_, err = conn.Do("TS.INFO", "missing")
+	assert.EqualError(t, err, string(errKeyNotFound))
+}
+
+func TestServer_IncrBy(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	now := time.Unix(100, 0)
+	s.SetClock(func() time.Time { return now })
+	conn := dial(t, s)
+	defer conn.Close()
+	run(t, conn, cmd("TS.INCRBY", "counter", 5, "LABELS", "kind", "counter"), cmd("TS.DECRBY", "counter", 2))
+	values, err := redis.Values(conn.Do("TS.GET", "counter"))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(100000), []byte("3")}, values)
+	_, err = conn.Do("TS.INCRBY", "counter", 1, "TIMESTAMP", 99999)
+	assert.EqualError(t, err, string(errNotLatest))
+	keys, err := redis.Strings(conn.Do("TS.QUERYINDEX", "kind=counter"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"counter"}, keys)
+}
+
+func TestServer_Transactions(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	conn := dial(t, s)
+	defer conn.Close()
+	other := dial(t, s)
+	defer other.Close()
+
+	run(t, conn, cmd("MULTI"), cmd("TS.ADD", "tx", 1, 1), cmd("TS.ADD", "tx", 1, 2))
+	replies, err := redis.Values(conn.Do("EXEC"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), replies[0])
+	assert.Equal(t, redis.Error(errBlocked), replies[1])
+
+	run(t, conn, cmd("WATCH", "tx"), cmd("MULTI"), cmd("TS.ADD", "tx", 2, 1))
+	run(t, other, cmd("TS.ADD", "tx", 3, 1))
+	replies, err = redis.Values(conn.Do("EXEC"))
+	assert.Equal(t, redis.ErrNil, err)
+	assert.Nil(t, replies)
+
+	run(t, conn, cmd("MULTI"))
+	_, err = conn.Do("UNKNOWN")
+	assert.Error(t, err)
+	_, err = conn.Do("EXEC")
+	assert.EqualError(t, err, "EXECABORT Transaction discarded because of previous errors.")
+}
+
+func TestServer_Auth(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.RequirePass("secret")
+	conn, err := redis.Dial("tcp", s.Addr())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Do("TS.ADD", "auth", 1, 1)
+	assert.EqualError(t, err, "NOAUTH Authentication required.")
+	_, err = conn.Do("AUTH", "wrong")
+	assert.Error(t, err)
+
+	conn, err = redis.Dial("tcp", s.Addr(), redis.DialPassword("secret"), redis.DialDatabase(2))
+	assert.NoError(t, err)
+	defer conn.Close()
+	run(t, conn, cmd("TS.ADD", "auth", 1, 1))
+	assert.Empty(t, s.Keys(), "written to database 2")
+}
+
+func TestServer_Dial(t *testing.T) {
+	s := NewServer()
+	pipe, err := s.Dial()
+	assert.NoError(t, err)
+	conn := redis.NewConn(pipe, time.Second, time.Second)
+	defer conn.Close()
+	run(t, conn, cmd("SET", "string", "value"), cmd("TS.CREATE", "series"))
+	_, err = conn.Do("TS.ADD", "string", 1, 1)
+	assert.EqualError(t, err, string(errWrongType))
+	assert.Equal(t, []string{"series", "string"}, s.Keys())
+	s.FlushAll()
+	assert.Empty(t, s.Keys())
+
+	s.Close()
+	_, err = conn.Do("PING")
+	assert.Error(t, err)
+	_, err = s.Dial()
+	assert.Error(t, err)
+}
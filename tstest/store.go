@@ -0,0 +1,285 @@
+package tstest
+
+import (
+	"sort"
+)
+
+// Error messages of RedisTimeSeries 1.4
+const (
+	errKeyNotFound     = replyError("ERR TSDB: the key does not exist")
+	errKeyExists       = replyError("ERR TSDB: key already exists")
+	errWrongType       = replyError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errBlocked         = replyError("ERR TSDB: Error at upsert, update is not supported in BLOCK mode")
+	errTooOld          = replyError("ERR TSDB: Timestamp is older than retention")
+	errNotLatest       = replyError("ERR TSDB: timestamp must be equal to or higher than the maximum existing timestamp")
+	errRuleNotFound    = replyError("ERR TSDB: compaction rule does not exist")
+	errRuleExists      = replyError("ERR TSDB: the destination key already has a rule")
+	errRuleSameKey     = replyError("ERR TSDB: the source key and destination key should be different")
+	errSyntax          = replyError("ERR syntax error")
+	errInvalidTs       = replyError("ERR TSDB: invalid timestamp")
+	errInvalidValue    = replyError("ERR TSDB: invalid value")
+	errInvalidPolicy   = replyError("ERR TSDB: Unknown DUPLICATE_POLICY")
+	errInvalidLabels   = replyError("ERR TSDB: failed parsing labels")
+	errInvalidChunk    = replyError("ERR TSDB: CHUNK_SIZE value must be a multiple of 8 in the range [128 .. 1048576]")
+	errInvalidRetain   = replyError("ERR TSDB: Couldn't parse RETENTION")
+	errInvalidAgg      = replyError("ERR TSDB: Unknown aggregation type")
+	errInvalidBucket   = replyError("ERR TSDB: bucketDuration must be greater than zero")
+	errInvalidCount    = replyError("ERR TSDB: Couldn't parse COUNT")
+	errInvalidAlign    = replyError("ERR TSDB: unknown ALIGN parameter")
+	errInvalidFilter   = replyError("ERR TSDB: failed parsing labels")
+	errMissingFilter   = replyError("ERR TSDB: missing FILTER argument")
+	errNoMatcher       = replyError("ERR TSDB: please provide at least one matcher")
+	errInvalidReducer  = replyError("ERR TSDB: failed parsing reducer")
+	errInvalidGroupBy  = replyError("ERR TSDB: GROUPBY must be followed by a label and REDUCE")
+	errInvalidValueMin = replyError("ERR TSDB: Couldn't parse MIN or MAX of FILTER_BY_VALUE")
+)
+
+const (
+	defaultChunkSize = 4096
+	// uncompressedSampleSize is the size of a sample in an uncompressed chunk
+	uncompressedSampleSize = 16
+	// compressedSampleSize approximates the size of a sample in a compressed chunk, for the regular series of tests
+	compressedSampleSize = 1
+)
+
+type sample struct {
+	ts    int64
+	value float64
+}
+
+type label struct {
+	name, value string
+}
+
+// rule is a compaction rule, aggregating the samples of its source into dest, by bucket
+type rule struct {
+	dest   string
+	agg    string
+	bucket int64
+	// current is the start of the bucket being filled, valid once open
+	current int64
+	open    bool
+}
+
+type series struct {
+	retention    int64
+	chunkSize    int64
+	uncompressed bool
+	// duplicatePolicy is empty when the series was created without one, the server default then applying
+	duplicatePolicy string
+	labels          []label
+	samples         []sample
+	rules           []*rule
+	// source is the key of the series compacted into this one, if any
+	source string
+}
+
+func (s *series) label(name string) (string, bool) {
+	for _, l := range s.labels {
+		if l.name == name {
+			return l.value, true
+		}
+	}
+	return "", false
+}
+
+func (s *series) last() (sample, bool) {
+	if len(s.samples) == 0 {
+		return sample{}, false
+	}
+	return s.samples[len(s.samples)-1], true
+}
+
+// upsert adds a sample, resolving the conflicts with an existing sample of the same timestamp with policy
+func (s *series) upsert(ts int64, value float64, policy string) error {
+	if last, found := s.last(); found && s.retention > 0 && ts < last.ts-s.retention {
+		return errTooOld
+	}
+	i := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].ts >= ts })
+	if i < len(s.samples) && s.samples[i].ts == ts {
+		existing := &s.samples[i].value
+		switch policy {
+		case "block":
+			return errBlocked
+		case "first":
+		case "last":
+			*existing = value
+		case "min":
+			if value < *existing {
+				*existing = value
+			}
+		case "max":
+			if value > *existing {
+				*existing = value
+			}
+		case "sum":
+			*existing += value
+		}
+		return nil
+	}
+	s.samples = append(s.samples, sample{})
+	copy(s.samples[i+1:], s.samples[i:])
+	s.samples[i] = sample{ts: ts, value: value}
+	s.trim()
+	return nil
+}
+
+// trim drops the samples older than the retention, relatively to the last sample
+func (s *series) trim() {
+	last, found := s.last()
+	if !found || s.retention <= 0 {
+		return
+	}
+	i := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].ts >= last.ts-s.retention })
+	s.samples = s.samples[i:]
+}
+
+// between returns the samples with a timestamp from from to to, inclusive
+func (s *series) between(from, to int64) []sample {
+	i := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].ts >= from })
+	j := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].ts > to })
+	if i >= j {
+		return nil
+	}
+	return s.samples[i:j]
+}
+
+// chunkCount approximates the number of chunks holding the samples, a series always having one
+func (s *series) chunkCount() int64 {
+	sampleSize := int64(compressedSampleSize)
+	if s.uncompressed {
+		sampleSize = uncompressedSampleSize
+	}
+	perChunk := s.chunkSize / sampleSize
+	count := (int64(len(s.samples)) + perChunk - 1) / perChunk
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
+
+type database struct {
+	series  map[string]*series
+	strings map[string]string
+	// versions are bumped on each write of a key, for WATCH
+	versions map[string]uint64
+	version  uint64
+}
+
+func newDatabase() *database {
+	return &database{series: map[string]*series{}, strings: map[string]string{}, versions: map[string]uint64{}}
+}
+
+// touch records a write of key
+func (db *database) touch(key string) {
+	db.version++
+	db.versions[key] = db.version
+}
+
+func (db *database) exists(key string) bool {
+	_, isSeries := db.series[key]
+	_, isString := db.strings[key]
+	return isSeries || isString
+}
+
+func (db *database) keys() []string {
+	keys := make([]string, 0, len(db.series)+len(db.strings))
+	for key := range db.series {
+		keys = append(keys, key)
+	}
+	for key := range db.strings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (db *database) flush() {
+	for _, key := range db.keys() {
+		db.touch(key)
+	}
+	db.series = map[string]*series{}
+	db.strings = map[string]string{}
+}
+
+// get returns the series of key, errKeyNotFound when it does not exist and errWrongType when key is not a series
+func (db *database) get(key string) (*series, error) {
+	if s, found := db.series[key]; found {
+		return s, nil
+	}
+	if _, found := db.strings[key]; found {
+		return nil, errWrongType
+	}
+	return nil, errKeyNotFound
+}
+
+// delete deletes key, detaching the compaction rules of the series from their source and destinations
+func (db *database) delete(key string) bool {
+	if _, found := db.strings[key]; found {
+		delete(db.strings, key)
+		db.touch(key)
+		return true
+	}
+	s, found := db.series[key]
+	if !found {
+		return false
+	}
+	if source, found := db.series[s.source]; found {
+		source.rules = removeRule(source.rules, key)
+	}
+	for _, r := range s.rules {
+		if dest, found := db.series[r.dest]; found {
+			dest.source = ""
+		}
+	}
+	delete(db.series, key)
+	db.touch(key)
+	return true
+}
+
+func removeRule(rules []*rule, dest string) []*rule {
+	kept := rules[:0]
+	for _, r := range rules {
+		if r.dest != dest {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// add adds a sample to the series of key, and compacts it into the destinations of its rules
+func (db *database) add(key string, s *series, ts int64, value float64, policy string) error {
+	if err := s.upsert(ts, value, policy); err != nil {
+		return err
+	}
+	db.touch(key)
+	db.compact(s, ts)
+	return nil
+}
+
+// compact updates the destinations of the rules of s after a sample was added at ts.
+// A bucket is written once a sample of a later bucket is added, and written again when a sample is added to it later.
+func (db *database) compact(s *series, ts int64) {
+	for _, r := range s.rules {
+		bucket := bucketStart(ts, r.bucket, 0)
+		switch {
+		case !r.open:
+			r.current, r.open = bucket, true
+		case bucket > r.current:
+			db.writeBucket(s, r, r.current)
+			r.current = bucket
+		case bucket < r.current:
+			db.writeBucket(s, r, bucket)
+		}
+	}
+}
+
+// writeBucket writes the aggregation of the samples of s in the bucket starting at start into the destination of r
+func (db *database) writeBucket(s *series, r *rule, start int64) {
+	dest, found := db.series[r.dest]
+	samples := s.between(start, start+r.bucket-1)
+	if !found || len(samples) == 0 {
+		return
+	}
+	db.add(r.dest, dest, start, aggregate(r.agg, samples), "last") //nolint:errcheck
+}
@@ -0,0 +1,236 @@
+package redis_timeseries_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrTxAborted is returned by Tx.Exec when a watched key was modified before EXEC, and no queued command was executed
+var ErrTxAborted = errors.New("transaction aborted: a watched key was modified")
+
+// ErrTxClosed is returned when using a transaction which was already executed or discarded
+var ErrTxClosed = errors.New("transaction already executed or discarded")
+
+// TxError reports the queued command that made a transaction fail
+type TxError struct {
+	// Index is the position of the failing command in queuing order
+	Index   int
+	Command string
+	Err     error
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("transaction command #%d (%s) failed: %v", e.Index, e.Command, e.Err)
+}
+
+// Unwrap returns the error reported for the failing command
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// Tx queues TimeSeries commands to be executed atomically within MULTI/EXEC.
+// A Tx holds a dedicated connection from the moment it is created until it is executed or discarded.
+type Tx struct {
+	commandQueue
+	client *Client
+	conn   redis.Conn
+}
+
+// Tx starts a transaction on a dedicated connection, WATCHing the given keys.
+// If any watched key is modified before Exec, the transaction is aborted with ErrTxAborted.
+// On a ShardedConnPool the transaction runs on the node owning the first watched key,
+// so every key it touches must live on that node.
+func (client *Client) Tx(watchKeys ...string) (*Tx, error) {
+	return client.TxCtx(context.Background(), watchKeys...)
+}
+
+// TxCtx is like Tx, honoring the deadline and cancellation of ctx while borrowing the connection and watching the keys
+func (client *Client) TxCtx(ctx context.Context, watchKeys ...string) (*Tx, error) {
+	key := ""
+	if len(watchKeys) > 0 {
+		key = client.namespace().key(watchKeys[0])
+	}
+	conn, err := client.getConn(ctx, key, "MULTI")
+	if err != nil {
+		return nil, err
+	}
+	tx := &Tx{commandQueue: commandQueue{ns: client.namespace()}, client: client, conn: conn}
+	if len(watchKeys) > 0 {
+		if err = tx.WatchCtx(ctx, watchKeys...); err != nil {
+			tx.Discard()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// Watch adds keys to the set of keys watched by the transaction
+func (tx *Tx) Watch(keys ...string) error {
+	return tx.WatchCtx(context.Background(), keys...)
+}
+
+// WatchCtx is like Watch, honoring the deadline of ctx
+func (tx *Tx) WatchCtx(ctx context.Context, keys ...string) error {
+	if tx.conn == nil {
+		return ErrTxClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, tx.ns.key(key))
+	}
+	_, err := doWithDeadline(ctx, tx.conn, "WATCH", args...)
+	return err
+}
+
+// Discard drops the queued commands, unwatches every key and releases the connection
+func (tx *Tx) Discard() error {
+	if tx.conn == nil {
+		return ErrTxClosed
+	}
+	tx.cmds = nil
+	conn := tx.conn
+	tx.conn = nil
+	// closing a pooled connection issues UNWATCH when keys are being watched
+	return conn.Close()
+}
+
+// Exec runs all the queued commands atomically within MULTI/EXEC, and returns their results in queuing order.
+// When a command can not be queued, or fails at execution, a *TxError naming it is returned.
+// When a watched key was modified, ErrTxAborted is returned and no command was executed.
+// The connection is released once Exec returns, so the transaction can not be reused.
+func (tx *Tx) Exec() ([]CommandResult, error) {
+	return tx.ExecCtx(context.Background())
+}
+
+// ExecCtx is like Exec, honoring the deadline and cancellation of ctx
+func (tx *Tx) ExecCtx(ctx context.Context) (results []CommandResult, err error) {
+	if tx.conn == nil {
+		return nil, ErrTxClosed
+	}
+	cmds := tx.cmds
+	if err = tx.client.checkQueued(ctx, cmds); err != nil {
+		tx.Discard()
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		if cmd.err != nil {
+			tx.Discard()
+			return nil, &TxError{Index: i, Command: cmd.name, Err: cmd.err}
+		}
+	}
+	conn := tx.conn
+	tx.conn = nil
+	tx.cmds = nil
+	results = make([]CommandResult, len(cmds))
+	for i, cmd := range cmds {
+		results[i] = CommandResult{Command: cmd.name, Value: cmd.value}
+	}
+	var txErr error
+	err = runWithContext(ctx, conn, func(conn redis.Conn) (err error) {
+		txErr, err = execQueued(ctx, conn, tx.client.Hooks, cmds, results)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	if txErr == ErrTxAborted {
+		return nil, txErr
+	}
+	return results, txErr
+}
+
+// execQueued wraps the sendable commands within MULTI/EXEC, and stores each parsed reply into the matching result.
+// The first error is the transaction outcome, while the second one reports a connection failure.
+// The hooks are invoked around each queued command, the ones left without a reply completing with the outcome.
+func execQueued(ctx context.Context, conn redis.Conn, hooks []Hook, cmds []queuedCommand, results []CommandResult) (txErr error, err error) {
+	hooked := make([]*hookedCommand, len(cmds))
+	defer func() {
+		outcome := err
+		if outcome == nil {
+			outcome = txErr
+		}
+		for _, c := range hooked {
+			c.after(nil, outcome)
+		}
+	}()
+	sent := make([]int, 0, len(cmds))
+	if err := conn.Send("MULTI"); err != nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		if cmd.skip {
+			continue
+		}
+		hooked[i] = beforeCommand(ctx, hooks, "", cmd.key, cmd.name, len(cmd.args))
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			return nil, err
+		}
+		sent = append(sent, i)
+	}
+	if err := conn.Send("EXEC"); err != nil {
+		return nil, err
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := receiveWithDeadline(ctx, conn); err != nil {
+		return nil, err
+	}
+	// each queued command is acknowledged with QUEUED, or with an error which makes EXEC abort
+	var queueErr *TxError
+	for _, i := range sent {
+		_, err := receiveWithDeadline(ctx, conn)
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				return nil, err
+			}
+			if queueErr == nil {
+				queueErr = &TxError{Index: i, Command: cmds[i].name, Err: classifyError(err)}
+			}
+			hooked[i].after(nil, classifyError(err))
+		}
+	}
+	reply, err := receiveWithDeadline(ctx, conn)
+	if err != nil {
+		if _, ok := err.(redis.Error); !ok {
+			return nil, err
+		}
+		if queueErr != nil {
+			return queueErr, nil
+		}
+		return classifyError(err), nil
+	}
+	if reply == nil {
+		return ErrTxAborted, nil
+	}
+	replies, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(replies) != len(sent) {
+		return nil, fmt.Errorf("EXEC returned %d replies for %d queued commands", len(replies), len(sent))
+	}
+	var execErr *TxError
+	for n, i := range sent {
+		if rerr, ok := replies[n].(redis.Error); ok {
+			results[i].Err = classifyError(rerr)
+			hooked[i].after(nil, results[i].Err)
+			if execErr == nil {
+				execErr = &TxError{Index: i, Command: cmds[i].name, Err: results[i].Err}
+			}
+			continue
+		}
+		hooked[i].after(replies[n], nil)
+		results[i].Value, results[i].Err = cmds[i].parse(replies[n])
+	}
+	if execErr != nil {
+		return execErr, nil
+	}
+	return nil, nil
+}
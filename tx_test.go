@@ -0,0 +1,88 @@
+package redis_timeseries_go
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// txHandler emulates MULTI/EXEC, replying QUEUED to each command and answering EXEC with the given replies
+func txHandler(execReply interface{}, queueErrors map[string]redis.Error) func(cmd string, args ...interface{}) (interface{}, error) {
+	return func(cmd string, args ...interface{}) (interface{}, error) {
+		switch cmd {
+		case "MULTI", "WATCH":
+			return "OK", nil
+		case "EXEC":
+			if len(queueErrors) > 0 {
+				return nil, redis.Error("EXECABORT Transaction discarded because of previous errors.")
+			}
+			if err, ok := execReply.(redis.Error); ok {
+				return nil, err
+			}
+			return execReply, nil
+		}
+		if err, ok := queueErrors[cmd]; ok {
+			return nil, err
+		}
+		return "QUEUED", nil
+	}
+}
+
+func TestTx_Exec(t *testing.T) {
+	ruleErr := redis.Error("ERR TSDB: the destination key already has a rule")
+	tests := []struct {
+		name        string
+		execReply   interface{}
+		queueErrors map[string]redis.Error
+		wantErr     error
+		wantIndex   int
+	}{
+		{"success", []interface{}{"OK", "OK", int64(10)}, nil, nil, -1},
+		{"aborted by watch", nil, nil, ErrTxAborted, -1},
+		{"execution error", []interface{}{"OK", ruleErr, int64(10)}, nil, ErrRuleExists, 1},
+		{"queueing error", nil, map[string]redis.Error{ADD_CMD: redis.Error("ERR wrong number of arguments")}, redis.Error("ERR wrong number of arguments"), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{Pool: &stubPool{handler: txHandler(tt.execReply, tt.queueErrors)}, Name: "test"}
+			tx, err := c.Tx("source")
+			assert.Nil(t, err)
+			tx.CreateKeyWithOptions("dest", DefaultCreateOptions)
+			tx.CreateRule("source", AvgAggregation, 60, "dest")
+			tx.Add("source", 10, 1)
+			results, err := tx.Exec()
+			if tt.wantErr == nil {
+				assert.Nil(t, err)
+				ts, err := results[2].Int64()
+				assert.Nil(t, err)
+				assert.Equal(t, int64(10), ts)
+			} else if tt.wantIndex < 0 {
+				assert.Equal(t, tt.wantErr, err)
+				assert.Nil(t, results)
+			} else {
+				var txErr *TxError
+				assert.True(t, errors.As(err, &txErr))
+				assert.Equal(t, tt.wantIndex, txErr.Index)
+				assert.True(t, errors.Is(txErr.Err, tt.wantErr))
+			}
+			_, err = tx.Exec()
+			assert.Equal(t, ErrTxClosed, err)
+		})
+	}
+}
+
+func TestTx_ExecSerializationError(t *testing.T) {
+	c := &Client{Pool: &stubPool{handler: txHandler([]interface{}{}, nil)}, Name: "test"}
+	tx, err := c.Tx()
+	assert.Nil(t, err)
+	tx.Add("a", 1, 1)
+	tx.CreateKeyWithOptions("b", CreateOptions{RetentionMSecs: 1})
+	_, err = tx.Exec()
+	var txErr *TxError
+	assert.True(t, errors.As(err, &txErr))
+	assert.Equal(t, 1, txErr.Index)
+	assert.Equal(t, CREATE_CMD, txErr.Command)
+	assert.Equal(t, ErrTxClosed, tx.Discard())
+}